@@ -1,10 +1,12 @@
 package bootstrap
 
 import (
-	"os"
+	"context"
 
 	"test-wallet/config"
 	"test-wallet/db"
+	"test-wallet/middleware"
+	"test-wallet/services"
 	"test-wallet/utils"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +14,41 @@ import (
 
 // InitializeApp performs all necessary initializations
 func InitializeApp() error {
+	if err := initCore(); err != nil {
+		return err
+	}
+
+	// Load the JWT signing and verification keys
+	if err := middleware.InitJWTKeys(); err != nil {
+		return err
+	}
+
+	// Select the SMS sender for phone verification codes
+	if err := services.InitSMSSender(); err != nil {
+		return err
+	}
+
+	// Connect to the configured chains
+	if err := services.InitChains(); err != nil {
+		return err
+	}
+
+	// Seed the token registry; an RPC endpoint being unavailable should not block startup
+	if err := services.SeedTokenRegistry(context.Background(), db.GetDB(), services.DefaultChainRegistry()); err != nil {
+		utils.LogError(err, "Failed to seed token registry", nil)
+	}
+
+	// Set Gin mode
+	if config.AppConfig.ServerConfig.Environment == "production" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	return nil
+}
+
+// initCore sets up the logger, configuration, database and key provider shared by the
+// server and the admin commands
+func initCore() error {
 	// Initialize logger
 	utils.InitLogger()
 	utils.LogInfo("Starting application", nil)
@@ -26,10 +63,6 @@ func InitializeApp() error {
 		return err
 	}
 
-	// Set Gin mode
-	if os.Getenv("ENV") == "production" {
-		gin.SetMode(gin.ReleaseMode)
-	}
-
-	return nil
+	// Initialize the master key provider used for wallet encryption
+	return services.InitKeyProvider()
 }
@@ -0,0 +1,122 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"test-wallet/config"
+	"test-wallet/db"
+	"test-wallet/services"
+	"test-wallet/utils"
+)
+
+// RunCommand runs an admin command given on the command line instead of the server
+func RunCommand(args []string) error {
+	switch args[0] {
+	case "rotate-master-key":
+		return rotateMasterKey()
+	case "sms-stub":
+		return runSMSStub(args[1:])
+	case "migrate":
+		return runMigrate(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// rotateMasterKey rewraps every wallet and TOTP data key with the master key named by
+// MASTER_KEY_ID. Keep the previous master key configured until the command reports no failures.
+func rotateMasterKey() error {
+	if err := initCore(); err != nil {
+		return err
+	}
+
+	wallets, err := services.RewrapWalletKeys(context.Background(), db.GetDB())
+	if err != nil {
+		return err
+	}
+	factors, err := services.RewrapTOTPSecrets(context.Background(), db.GetDB())
+	if err != nil {
+		return err
+	}
+
+	utils.LogInfo("Data keys rewrapped", map[string]interface{}{
+		"wallets":      wallets.Rewrapped,
+		"totp_secrets": factors.Rewrapped,
+		"failed":       wallets.Failed + factors.Failed,
+	})
+	if failed := wallets.Failed + factors.Failed; failed > 0 {
+		return fmt.Errorf("%d data keys could not be rewrapped", failed)
+	}
+	return nil
+}
+
+// runMigrate applies pending migrations ("up", the default), reverts the latest ones
+// ("down [steps]", one step by default) or prints which migrations are applied ("status")
+func runMigrate(args []string) error {
+	utils.InitLogger()
+	if err := config.LoadConfig(); err != nil {
+		return err
+	}
+	if err := db.Connect(); err != nil {
+		return err
+	}
+
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		return db.Migrate(db.GetDB())
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		return db.Rollback(db.GetDB(), steps)
+	case "status":
+		statuses, err := db.Status(db.GetDB())
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate action %q, expected up, down or status", action)
+	}
+}
+
+// runSMSStub serves a local stand-in for an SMS provider that logs every message, so the
+// http SMS provider can be used in development. The listen address defaults to :9090.
+func runSMSStub(args []string) error {
+	utils.InitLogger()
+
+	addr := ":9090"
+	if len(args) > 0 {
+		addr = args[0]
+	}
+
+	utils.LogInfo("SMS stub listening", map[string]interface{}{
+		"addr": addr,
+	})
+	return http.ListenAndServe(addr, services.SMSStubHandler())
+}
@@ -2,7 +2,11 @@ package bootstrap
 
 import (
 	"os"
+	"test-wallet/config"
+	"test-wallet/db"
 	"test-wallet/routes"
+	"test-wallet/services"
+	"test-wallet/utils"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -13,6 +17,11 @@ import (
 func SetupRouter() *gin.Engine {
 	router := gin.New()
 
+	// Only the configured proxies may set the client IP through X-Forwarded-For
+	if err := router.SetTrustedProxies(config.AppConfig.ServerConfig.TrustedProxies); err != nil {
+		utils.LogFatal(err, "Invalid TRUSTED_PROXIES", nil)
+	}
+
 	// Add middleware
 	router.Use(gin.Recovery())
 	if os.Getenv("ENV") == "development" {
@@ -30,7 +39,7 @@ func SetupRouter() *gin.Engine {
 	}))
 
 	// Register routes
-	routes.RegisterRoutes(router)
+	routes.RegisterRoutes(router, db.GetDB(), services.DefaultChainRegistry())
 
 	return router
 }
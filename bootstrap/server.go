@@ -49,5 +49,7 @@ func WaitForShutdown(srv *http.Server) {
 		utils.LogError(err, "Server forced to shutdown", nil)
 	}
 
+	stopWorkers(ctx)
+
 	utils.LogInfo("Server exiting", nil)
 }
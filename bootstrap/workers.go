@@ -0,0 +1,52 @@
+package bootstrap
+
+import (
+	"context"
+
+	"test-wallet/db"
+	"test-wallet/services"
+	"test-wallet/utils"
+)
+
+var (
+	txTracker     *services.TxTracker
+	healthMonitor *services.ChainHealthMonitor
+)
+
+// StartWorkers starts the background workers
+func StartWorkers() error {
+	// Pick up sends made outside this service while it was down
+	if err := services.ResyncNonces(context.Background(), db.GetDB(), services.DefaultChainRegistry()); err != nil {
+		utils.LogError(err, "Failed to resync nonces", nil)
+	}
+
+	monitor, err := services.NewChainHealthMonitor(services.DefaultChainRegistry())
+	if err != nil {
+		return err
+	}
+	monitor.Start()
+	healthMonitor = monitor
+
+	tracker, err := services.NewTxTracker(db.GetDB(), services.DefaultChainRegistry())
+	if err != nil {
+		return err
+	}
+	tracker.Start()
+	txTracker = tracker
+
+	return nil
+}
+
+// stopWorkers stops the background workers, giving up when ctx expires
+func stopWorkers(ctx context.Context) {
+	if txTracker != nil {
+		if err := txTracker.Stop(ctx); err != nil {
+			utils.LogError(err, "Failed to stop transaction tracker", nil)
+		}
+	}
+	if healthMonitor != nil {
+		if err := healthMonitor.Stop(ctx); err != nil {
+			utils.LogError(err, "Failed to stop chain health monitor", nil)
+		}
+	}
+}
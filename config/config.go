@@ -1,47 +1,175 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DBConfig     DBConfig
-	ServerConfig ServerConfig
-	JWTConfig    JWTConfig
-	EthConfig    EthConfig
+	DBConfig       DBConfig
+	ServerConfig   ServerConfig
+	JWTConfig      JWTConfig
+	EthConfig      EthConfig
+	SecurityConfig SecurityConfig
+	KeyConfig      KeyConfig
+	SMSConfig      SMSConfig
 }
 
 type DBConfig struct {
+	// Driver is "mysql", "postgres" or "sqlite"
+	Driver   string
 	Host     string
 	Port     string
 	User     string
 	Password string
 	Name     string
+	// SSLMode is the PostgreSQL sslmode, e.g. "disable" or "require"
+	SSLMode string
+	// Path is the SQLite database file, or ":memory:"
+	Path string
 }
 
 type ServerConfig struct {
+	// Environment is "production" or anything else for development
+	Environment  string
 	Port         string
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
+	// TrustedProxies are the proxy IPs or CIDRs whose X-Forwarded-For header is believed when
+	// resolving the client IP; empty means the peer address is always the client IP
+	TrustedProxies []string
 }
 
 type JWTConfig struct {
-	Secret     string
+	// Secret signs HS256 tokens; it is unused when Algorithm is asymmetric
+	Secret string
+	// Algorithm is HS256, RS256 or EdDSA
+	Algorithm string
+	// SigningKeyID is the kid of the key in KeyFiles that signs new tokens
+	SigningKeyID string
+	// KeyFiles is a comma-separated list of KID:PATH pairs of PEM keys. Private keys can
+	// sign and verify; public keys only verify, for keys being retired.
+	KeyFiles string
+	// Expiration is the lifetime of access tokens
 	Expiration time.Duration
+	// RefreshExpiration is the lifetime of a session; each refresh token rotation keeps it
+	RefreshExpiration time.Duration
 }
 
 type EthConfig struct {
-	InfuraURL        string
-	USDCContractAddr string
+	// Chains lists the networks the wallet can use
+	Chains []ChainConfig
+	// DefaultChain is the name of the chain used when a request does not select one
+	DefaultChain string
+	// Confirmations is the number of blocks a receipt needs before a transaction is final
+	Confirmations uint64
+	// ReceiptPollInterval is how often pending transactions are checked for receipts
+	ReceiptPollInterval time.Duration
+	// RPCTimeout bounds a single call to one RPC endpoint
+	RPCTimeout time.Duration
+	// RPCMaxRetries is how many more endpoints a read is tried on after a transient error
+	RPCMaxRetries int
+	// RPCRetryBackoff is the delay before the first retry; it doubles with each further retry
+	RPCRetryBackoff time.Duration
+	// RPCHealthInterval is how often every endpoint is probed for latency and block height
+	RPCHealthInterval time.Duration
+}
+
+// ChainConfig describes an EVM network. Accounts are derived the same way on every chain,
+// so each user has the same addresses on all of them.
+type ChainConfig struct {
+	ID uint64
+	// Name selects the chain in API requests, e.g. "base"
+	Name         string
+	RPCURLs      []string
+	NativeSymbol string
+	ExplorerURL  string
+	// RPCRateLimit caps the requests per second sent to each RPC endpoint; 0 means no limit
+	RPCRateLimit float64
+	// EIP1559 enables type-2 transactions; without it legacy gas price transactions are sent
+	EIP1559 bool
+	// Tokens seeds the token registry with ERC-20 contracts on this chain
+	Tokens []TokenSeed
+}
+
+type SecurityConfig struct {
+	// ExportRateLimit is the number of key exports a user may request per ExportRateWindow
+	ExportRateLimit  int
+	ExportRateWindow time.Duration
+	// PinMaxAttempts is the number of consecutive failed PINs that locks a user out
+	PinMaxAttempts int
+	// IPPinMaxAttempts is the number of consecutive failed PINs that locks a client IP out
+	IPPinMaxAttempts int
+	// PinBackoff is the delay after the first failure; it doubles with each further failure
+	PinBackoff time.Duration
+	// PinLockout is how long a user or IP stays locked after too many failures
+	PinLockout time.Duration
+	// OTPExpiration is how long a phone verification code stays valid
+	OTPExpiration time.Duration
+	// OTPMaxAttempts is the number of wrong codes that invalidates a verification code
+	OTPMaxAttempts int
+	// OTPResendInterval is the minimum time between two codes sent to the same number
+	OTPResendInterval time.Duration
+	// TOTPEthThreshold and TOTPTokenThreshold are the default transfer amounts (in ETH and
+	// whole token units) above which users with an authenticator must give a TOTP code
+	TOTPEthThreshold   string
+	TOTPTokenThreshold string
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer string
+	// SIWEDomain is the domain (host[:port]) Sign-In with Ethereum messages must be bound to
+	SIWEDomain string
+	// SIWEURI is the URI returned with nonces for clients to put in their messages
+	SIWEURI string
+	// SIWENonceExpiration is how long a SIWE nonce can be used
+	SIWENonceExpiration time.Duration
+	// AdminAPIKey authorizes the admin endpoints through the X-Admin-Key header; empty disables them
+	AdminAPIKey string
+}
+
+// KeyConfig selects the KeyProvider holding the master keys that wrap per-wallet data keys
+type KeyConfig struct {
+	// Provider is "local" (keys from MasterKeys or MasterKeyFile) or "pkcs11"
+	Provider string
+	// MasterKeyID is the ID (PKCS#11 label) of the key used for new wraps
+	MasterKeyID string
+	// MasterKeys is a comma-separated list of ID:BASE64KEY pairs of 32-byte AES keys
+	MasterKeys string
+	// MasterKeyFile holds ID:BASE64KEY pairs, one per line, in addition to MasterKeys
+	MasterKeyFile string
+	// PKCS11Module is the path of the PKCS#11 library, e.g. libsofthsm2.so
+	PKCS11Module     string
+	PKCS11TokenLabel string
+	PKCS11Pin        string
+}
+
+// SMSConfig selects the SMSSender that delivers verification codes
+type SMSConfig struct {
+	// Provider is "console" (log only), "file" (append to FilePath) or "http"
+	Provider string
+	FilePath string
+	// HTTPURL receives a JSON POST of {"from", "to", "message"} for each SMS
+	HTTPURL   string
+	HTTPToken string // Sent as a bearer token when set
+	From      string
+}
+
+// TokenSeed is a token registry entry provided through configuration
+type TokenSeed struct {
+	Symbol  string
+	Address string
 }
 
 var AppConfig Config
 
+// DefaultJWTSecret is the placeholder HS256 secret, refused in production
+const DefaultJWTSecret = "your-secret-key"
+
 func LoadConfig() error {
 	// Load .env file
 	if err := godotenv.Load(); err != nil {
@@ -49,37 +177,207 @@ func LoadConfig() error {
 	}
 
 	// Database configuration
+	driver := strings.ToLower(getEnv("DB_DRIVER", "mysql"))
+	defaultPort, defaultUser := "3306", "root"
+	if driver == "postgres" {
+		defaultPort, defaultUser = "5432", "postgres"
+	}
 	AppConfig.DBConfig = DBConfig{
-		Host:     getEnv("MYSQL_DB_HOST", "localhost"),
-		Port:     getEnv("MYSQL_DB_PORT", "3306"),
-		User:     getEnv("MYSQL_DB_USER", "root"),
-		Password: getEnv("MYSQL_DB_PASS", ""),
-		Name:     getEnv("MYSQL_DB_NAME", "test_wallet"),
+		Driver:   driver,
+		Host:     getDBEnv("HOST", "localhost"),
+		Port:     getDBEnv("PORT", defaultPort),
+		User:     getDBEnv("USER", defaultUser),
+		Password: getDBEnv("PASS", ""),
+		Name:     getDBEnv("NAME", "test_wallet"),
+		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+		Path:     getEnv("DB_PATH", "test_wallet.db"),
 	}
 
 	AppConfig.ServerConfig = ServerConfig{
-		Port: getEnv("SERVER_PORT", "8080"),
+		Environment: getEnv("ENV", "development"),
+		Port:        getEnv("SERVER_PORT", "8080"),
+		// Without trusted proxies a client could pick its IP, and with it the per-IP PIN limit
+		TrustedProxies: splitList(getEnv("TRUSTED_PROXIES", "")),
 	}
 
 	// JWT configuration
-	jwtExpiration, _ := strconv.Atoi(getEnv("JWT_EXPIRATION_HOURS", "24"))
+	accessMinutes, _ := strconv.Atoi(getEnv("JWT_ACCESS_TOKEN_MINUTES", "15"))
+	refreshDays, _ := strconv.Atoi(getEnv("JWT_REFRESH_TOKEN_DAYS", "30"))
 	AppConfig.JWTConfig = JWTConfig{
-		Secret:     getEnv("JWT_SECRET", "your-secret-key"),
-		Expiration: time.Duration(jwtExpiration) * time.Hour,
+		Secret:            getEnv("JWT_SECRET", DefaultJWTSecret),
+		Algorithm:         getEnv("JWT_ALGORITHM", "HS256"),
+		SigningKeyID:      getEnv("JWT_SIGNING_KEY_ID", ""),
+		KeyFiles:          getEnv("JWT_KEY_FILES", ""),
+		Expiration:        time.Duration(accessMinutes) * time.Minute,
+		RefreshExpiration: time.Duration(refreshDays) * 24 * time.Hour,
 	}
 
 	// Ethereum configuration
+	chains, err := loadChains()
+	if err != nil {
+		return err
+	}
 	AppConfig.EthConfig = EthConfig{
-		InfuraURL:        getEnv("INFURA_URL", ""),
-		USDCContractAddr: getEnv("USDC_CONTRACT_ADDRESS", ""),
+		Chains:       chains,
+		DefaultChain: getEnv("DEFAULT_CHAIN", chains[0].Name),
+	}
+	AppConfig.EthConfig.Confirmations, _ = strconv.ParseUint(getEnv("ETH_CONFIRMATIONS", "3"), 10, 64)
+	pollSeconds, _ := strconv.Atoi(getEnv("RECEIPT_POLL_INTERVAL_SECONDS", "15"))
+	AppConfig.EthConfig.ReceiptPollInterval = time.Duration(pollSeconds) * time.Second
+	rpcTimeoutSeconds, _ := strconv.Atoi(getEnv("RPC_TIMEOUT_SECONDS", "10"))
+	AppConfig.EthConfig.RPCTimeout = time.Duration(rpcTimeoutSeconds) * time.Second
+	AppConfig.EthConfig.RPCMaxRetries, _ = strconv.Atoi(getEnv("RPC_MAX_RETRIES", "2"))
+	rpcBackoffMillis, _ := strconv.Atoi(getEnv("RPC_RETRY_BACKOFF_MS", "200"))
+	AppConfig.EthConfig.RPCRetryBackoff = time.Duration(rpcBackoffMillis) * time.Millisecond
+	healthSeconds, _ := strconv.Atoi(getEnv("RPC_HEALTH_INTERVAL_SECONDS", "30"))
+	AppConfig.EthConfig.RPCHealthInterval = time.Duration(healthSeconds) * time.Second
+
+	// Security configuration
+	AppConfig.SecurityConfig.ExportRateLimit, _ = strconv.Atoi(getEnv("EXPORT_RATE_LIMIT", "3"))
+	exportWindowMinutes, _ := strconv.Atoi(getEnv("EXPORT_RATE_WINDOW_MINUTES", "60"))
+	AppConfig.SecurityConfig.ExportRateWindow = time.Duration(exportWindowMinutes) * time.Minute
+	AppConfig.SecurityConfig.PinMaxAttempts, _ = strconv.Atoi(getEnv("PIN_MAX_ATTEMPTS", "5"))
+	AppConfig.SecurityConfig.IPPinMaxAttempts, _ = strconv.Atoi(getEnv("IP_PIN_MAX_ATTEMPTS", "20"))
+	backoffSeconds, _ := strconv.Atoi(getEnv("PIN_BACKOFF_SECONDS", "1"))
+	AppConfig.SecurityConfig.PinBackoff = time.Duration(backoffSeconds) * time.Second
+	lockoutMinutes, _ := strconv.Atoi(getEnv("PIN_LOCKOUT_MINUTES", "15"))
+	AppConfig.SecurityConfig.PinLockout = time.Duration(lockoutMinutes) * time.Minute
+	otpMinutes, _ := strconv.Atoi(getEnv("OTP_EXPIRATION_MINUTES", "10"))
+	AppConfig.SecurityConfig.OTPExpiration = time.Duration(otpMinutes) * time.Minute
+	AppConfig.SecurityConfig.OTPMaxAttempts, _ = strconv.Atoi(getEnv("OTP_MAX_ATTEMPTS", "5"))
+	resendSeconds, _ := strconv.Atoi(getEnv("OTP_RESEND_SECONDS", "60"))
+	AppConfig.SecurityConfig.OTPResendInterval = time.Duration(resendSeconds) * time.Second
+	AppConfig.SecurityConfig.TOTPEthThreshold = getEnv("TOTP_ETH_THRESHOLD", "0.1")
+	AppConfig.SecurityConfig.TOTPTokenThreshold = getEnv("TOTP_TOKEN_THRESHOLD", "100")
+	AppConfig.SecurityConfig.TOTPIssuer = getEnv("TOTP_ISSUER", "Test Wallet")
+	AppConfig.SecurityConfig.SIWEDomain = getEnv("SIWE_DOMAIN", "localhost:8080")
+	AppConfig.SecurityConfig.SIWEURI = getEnv("SIWE_URI", "http://localhost:8080")
+	siweMinutes, _ := strconv.Atoi(getEnv("SIWE_NONCE_MINUTES", "10"))
+	AppConfig.SecurityConfig.SIWENonceExpiration = time.Duration(siweMinutes) * time.Minute
+	AppConfig.SecurityConfig.AdminAPIKey = getEnv("ADMIN_API_KEY", "")
+
+	// Master key configuration
+	AppConfig.KeyConfig = KeyConfig{
+		Provider:         getEnv("KEY_PROVIDER", "local"),
+		MasterKeyID:      getEnv("MASTER_KEY_ID", ""),
+		MasterKeys:       getEnv("MASTER_KEYS", ""),
+		MasterKeyFile:    getEnv("MASTER_KEY_FILE", ""),
+		PKCS11Module:     getEnv("PKCS11_MODULE", ""),
+		PKCS11TokenLabel: getEnv("PKCS11_TOKEN_LABEL", ""),
+		PKCS11Pin:        getEnv("PKCS11_PIN", ""),
+	}
+
+	// SMS configuration
+	AppConfig.SMSConfig = SMSConfig{
+		Provider:  getEnv("SMS_PROVIDER", "console"),
+		FilePath:  getEnv("SMS_FILE_PATH", "sms.log"),
+		HTTPURL:   getEnv("SMS_HTTP_URL", ""),
+		HTTPToken: getEnv("SMS_HTTP_TOKEN", ""),
+		From:      getEnv("SMS_FROM", "TestWallet"),
 	}
 
 	return nil
 }
 
+// knownChains holds the settings of common networks, so only their RPC URLs need configuring
+var knownChains = map[string]ChainConfig{
+	"mainnet":  {ID: 1, NativeSymbol: "ETH", ExplorerURL: "https://etherscan.io", EIP1559: true},
+	"sepolia":  {ID: 11155111, NativeSymbol: "ETH", ExplorerURL: "https://sepolia.etherscan.io", EIP1559: true},
+	"polygon":  {ID: 137, NativeSymbol: "POL", ExplorerURL: "https://polygonscan.com", EIP1559: true},
+	"base":     {ID: 8453, NativeSymbol: "ETH", ExplorerURL: "https://basescan.org", EIP1559: true},
+	"arbitrum": {ID: 42161, NativeSymbol: "ETH", ExplorerURL: "https://arbiscan.io", EIP1559: true},
+	"optimism": {ID: 10, NativeSymbol: "ETH", ExplorerURL: "https://optimistic.etherscan.io", EIP1559: true},
+}
+
+// loadChains reads the chains named in CHAINS. Each chain is configured with CHAIN_<NAME>_*
+// variables on top of the knownChains defaults. The first chain also takes the older
+// single-chain variables (INFURA_URL, TOKENS, USDC_CONTRACT_ADDRESS and ETH_LEGACY_TX).
+func loadChains() ([]ChainConfig, error) {
+	var chains []ChainConfig
+	for i, name := range splitList(getEnv("CHAINS", "sepolia")) {
+		name = strings.ToLower(name)
+		prefix := "CHAIN_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		chain, known := knownChains[name]
+		if !known {
+			chain = ChainConfig{NativeSymbol: "ETH", EIP1559: true}
+		}
+		chain.Name = name
+		if id := getEnv(prefix+"ID", ""); id != "" {
+			parsed, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %sID: %w", prefix, err)
+			}
+			chain.ID = parsed
+		}
+		if chain.ID == 0 {
+			return nil, fmt.Errorf("chain %q needs %sID", name, prefix)
+		}
+
+		rpcURLs, tokens, eip1559 := "", "", strconv.FormatBool(chain.EIP1559)
+		if i == 0 {
+			legacy, _ := strconv.ParseBool(getEnv("ETH_LEGACY_TX", "false"))
+			rpcURLs, tokens, eip1559 = getEnv("INFURA_URL", ""), getEnv("TOKENS", ""), strconv.FormatBool(!legacy)
+		}
+		chain.RPCURLs = splitList(getEnv(prefix+"RPC_URLS", rpcURLs))
+		if len(chain.RPCURLs) == 0 {
+			return nil, fmt.Errorf("chain %q needs %sRPC_URLS", name, prefix)
+		}
+		chain.NativeSymbol = getEnv(prefix+"NATIVE_SYMBOL", chain.NativeSymbol)
+		chain.ExplorerURL = getEnv(prefix+"EXPLORER_URL", chain.ExplorerURL)
+		chain.RPCRateLimit, _ = strconv.ParseFloat(getEnv(prefix+"RPC_RATE_LIMIT", "0"), 64)
+		chain.EIP1559, _ = strconv.ParseBool(getEnv(prefix+"EIP1559", eip1559))
+		chain.Tokens = parseTokenSeeds(getEnv(prefix+"TOKENS", tokens))
+		if i == 0 {
+			if usdc := getEnv("USDC_CONTRACT_ADDRESS", ""); usdc != "" {
+				chain.Tokens = append(chain.Tokens, TokenSeed{Symbol: "USDC", Address: usdc})
+			}
+		}
+
+		chains = append(chains, chain)
+	}
+
+	if len(chains) == 0 {
+		return nil, fmt.Errorf("CHAINS names no chains")
+	}
+	return chains, nil
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTokenSeeds parses a comma-separated list of SYMBOL:ADDRESS pairs
+func parseTokenSeeds(value string) []TokenSeed {
+	var seeds []TokenSeed
+	for _, entry := range strings.Split(value, ",") {
+		symbol, address, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || symbol == "" || address == "" {
+			continue
+		}
+		seeds = append(seeds, TokenSeed{Symbol: strings.ToUpper(symbol), Address: address})
+	}
+	return seeds
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
 	return defaultValue
 }
+
+// getDBEnv reads DB_<name>, falling back to the older MYSQL_DB_<name>
+func getDBEnv(name, defaultValue string) string {
+	if value, exists := os.LookupEnv("DB_" + name); exists {
+		return value
+	}
+	return getEnv("MYSQL_DB_"+name, defaultValue)
+}
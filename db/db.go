@@ -0,0 +1,127 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"test-wallet/config"
+	"test-wallet/utils"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var DB *gorm.DB
+
+// InitDB connects to the database and brings its schema up to date. In production the
+// schema is only checked: migrations are applied with the migrate command before deploying.
+func InitDB() error {
+	if err := Connect(); err != nil {
+		return err
+	}
+
+	if config.AppConfig.ServerConfig.Environment == "production" {
+		return CheckSchema(DB)
+	}
+	return Migrate(DB)
+}
+
+// Connect opens the connection of the configured driver without touching the schema
+func Connect() error {
+	cfg := config.AppConfig.DBConfig
+	dialector, err := openDialector(cfg)
+	if err != nil {
+		return err
+	}
+
+	// TranslateError maps each driver's unique violation to gorm.ErrDuplicatedKey
+	DB, err = gorm.Open(dialector, &gorm.Config{
+		Logger:         logger.Default.LogMode(logger.Info),
+		TranslateError: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if cfg.Driver == "sqlite" && cfg.Path == ":memory:" {
+		// Every connection to an in-memory database would get its own empty database
+		sqlDB, err := DB.DB()
+		if err != nil {
+			return fmt.Errorf("failed to get database handle: %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	utils.LogInfo("Database connection established", map[string]interface{}{
+		"driver": cfg.Driver,
+	})
+	return nil
+}
+
+// openDialector returns the GORM dialector for the configured driver
+func openDialector(cfg config.DBConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "", "mysql":
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Name)
+		return mysql.Open(dsn), nil
+	case "postgres":
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(cfg.User, cfg.Password),
+			Host:     net.JoinHostPort(cfg.Host, cfg.Port),
+			Path:     "/" + cfg.Name,
+			RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+		}
+		return postgres.Open(dsn.String()), nil
+	case "sqlite":
+		return sqlite.Open(sqliteDSN(cfg.Path)), nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q, expected mysql, postgres or sqlite", cfg.Driver)
+	}
+}
+
+// sqliteDSN returns the connection string for a SQLite database file. SQLite has no row
+// locks, so transactions take the write lock when they begin (_txlock=immediate); that
+// serializes the read-modify-write sections the other drivers guard with SELECT ... FOR UPDATE.
+func sqliteDSN(path string) string {
+	if path == ":memory:" {
+		return "file::memory:?cache=shared&_foreign_keys=on&_txlock=immediate"
+	}
+	return "file:" + path + "?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on&_txlock=immediate"
+}
+
+// BeginTransaction starts a new transaction on the given connection
+func BeginTransaction(conn *gorm.DB) (*gorm.DB, error) {
+	tx := conn.Begin()
+	if tx.Error != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", tx.Error)
+	}
+	return tx, nil
+}
+
+// EndTransaction commits or rolls back a transaction
+func EndTransaction(tx *gorm.DB, shouldCommit bool) error {
+	if tx == nil {
+		return fmt.Errorf("transaction is nil")
+	}
+
+	if shouldCommit {
+		if err := tx.Commit().Error; err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	} else {
+		if err := tx.Rollback().Error; err != nil {
+			return fmt.Errorf("failed to rollback transaction: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetDB returns the database connection
+func GetDB() *gorm.DB {
+	return DB
+}
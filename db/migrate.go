@@ -0,0 +1,619 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"test-wallet/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrations live in migrations/<driver>/<version>_<name>.up.sql with a matching .down.sql.
+// Statements end with a semicolon at the end of a line. Migration 1 is the users and wallets
+// schema AutoMigrate created at first; later ones add to it.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var (
+	// ErrSchemaOutdated is returned when the database has migrations that have not been applied
+	ErrSchemaOutdated = errors.New("database schema is out of date")
+	// ErrUnknownSchema is returned when a database created by AutoMigrate has the tables and
+	// columns of no migration version, so it can't be adopted
+	ErrUnknownSchema = errors.New("existing database schema matches no migration version")
+)
+
+// identifier matches a quoted table or column name in the migration scripts
+const identifier = "[`\"](\\w+)[`\"]"
+
+var (
+	migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+	statementEnd      = regexp.MustCompile(`;\s*(\n|$)`)
+
+	createTableStatement = regexp.MustCompile(`(?is)^CREATE TABLE ` + identifier + `\s*\((.*)\)$`)
+	dropTableStatement   = regexp.MustCompile(`(?i)^DROP TABLE ` + identifier)
+	alterTableStatement  = regexp.MustCompile(`(?i)^ALTER TABLE ` + identifier)
+	columnDefinition     = regexp.MustCompile(`(?m)^\s*` + identifier + `\s`)
+	addColumnClause      = regexp.MustCompile(`(?i)ADD COLUMN ` + identifier)
+	dropColumnClause     = regexp.MustCompile(`(?i)DROP COLUMN ` + identifier)
+)
+
+// Migration is one versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied to the database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// dialect holds the SQL that differs between drivers
+type dialect struct {
+	createTable string
+	hasTable    string
+	// columns lists the table and column names of the database
+	columns string
+	// lock and unlock guard against concurrent migrators; empty when begin already does.
+	// lock returns 1 once the lock is held.
+	lock   string
+	unlock string
+	// begin starts a migration's transaction; empty when DDL can't be rolled back
+	begin string
+}
+
+var dialects = map[string]dialect{
+	"mysql": {
+		createTable: "CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL, name varchar(255) NOT NULL, applied_at datetime(3) NOT NULL, PRIMARY KEY (version))",
+		hasTable:    "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?",
+		columns:     "SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = DATABASE()",
+		lock:        "SELECT GET_LOCK(CONCAT(DATABASE(), '.schema_migrations'), 60)",
+		unlock:      "SELECT RELEASE_LOCK(CONCAT(DATABASE(), '.schema_migrations'))",
+	},
+	"postgres": {
+		createTable: "CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL, name varchar(255) NOT NULL, applied_at timestamptz NOT NULL, PRIMARY KEY (version))",
+		hasTable:    "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = ?",
+		columns:     "SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = CURRENT_SCHEMA()",
+		lock:        "SELECT 1 FROM pg_advisory_lock(hashtext(current_database() || '.schema_migrations'))",
+		unlock:      "SELECT pg_advisory_unlock(hashtext(current_database() || '.schema_migrations'))",
+		begin:       "BEGIN",
+	},
+	"sqlite": {
+		createTable: "CREATE TABLE IF NOT EXISTS schema_migrations (version integer NOT NULL, name varchar(255) NOT NULL, applied_at datetime NOT NULL, PRIMARY KEY (version))",
+		hasTable:    "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?",
+		columns:     "SELECT t.name, c.name FROM sqlite_master t JOIN pragma_table_info(t.name) c WHERE t.type = 'table' AND t.name NOT LIKE 'sqlite_%'",
+		// The write lock taken by BEGIN IMMEDIATE is held for the whole migration
+		begin: "BEGIN IMMEDIATE",
+	},
+}
+
+// LoadMigrations returns the embedded migrations of a driver ordered by version
+func LoadMigrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q: %w", driver, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrate applies every pending migration in order
+func Migrate(conn *gorm.DB) error {
+	return withMigrator(conn, func(m *migrator) error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 {
+			legacy, err := m.hasTable("users")
+			if err != nil {
+				return err
+			}
+			if legacy {
+				if applied, err = m.adoptExisting(); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, migration := range m.migrations {
+			if applied[migration.Version] {
+				continue
+			}
+			if err := m.run(migration, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Rollback reverts the latest steps applied migrations, newest first
+func Rollback(conn *gorm.DB, steps int) error {
+	return withMigrator(conn, func(m *migrator) error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if !applied[migration.Version] {
+				continue
+			}
+			if err := m.run(migration, false); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration with the time it was applied, if it was
+func Status(conn *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(conn.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Version   int
+		AppliedAt time.Time
+	}
+	if conn.Migrator().HasTable("schema_migrations") {
+		if err := conn.Raw("SELECT version, applied_at FROM schema_migrations").Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+	}
+	applied := make(map[int]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// CheckSchema returns ErrSchemaOutdated when any migration has not been applied
+func CheckSchema(conn *gorm.DB) error {
+	statuses, err := Status(conn)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%d_%s", status.Version, status.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending migrations %s, run the migrate command", ErrSchemaOutdated, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// migrator runs migrations on one pinned connection, so the session lock it holds covers them
+type migrator struct {
+	ctx        context.Context
+	conn       *sql.Conn
+	driver     string
+	dialect    dialect
+	migrations []Migration
+}
+
+// withMigrator takes the migration lock, ensures schema_migrations exists and calls fn
+func withMigrator(conn *gorm.DB, fn func(m *migrator) error) error {
+	driver := conn.Dialector.Name()
+	d, ok := dialects[driver]
+	if !ok {
+		return fmt.Errorf("migrations are not supported for driver %q", driver)
+	}
+	migrations, err := LoadMigrations(driver)
+	if err != nil {
+		return err
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database handle: %w", err)
+	}
+	ctx := context.Background()
+	pinned, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer pinned.Close()
+
+	m := &migrator{ctx: ctx, conn: pinned, driver: driver, dialect: d, migrations: migrations}
+
+	if d.lock != "" {
+		// GET_LOCK gives up after 60 seconds with 0; pg_advisory_lock waits as long as needed
+		var acquired sql.NullInt64
+		if err := pinned.QueryRowContext(ctx, d.lock).Scan(&acquired); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if acquired.Int64 != 1 {
+			return errors.New("failed to acquire migration lock: another migration is running")
+		}
+		defer func() {
+			if _, err := pinned.ExecContext(ctx, d.unlock); err != nil {
+				utils.LogError(err, "Failed to release migration lock", nil)
+			}
+		}()
+	}
+
+	if _, err := pinned.ExecContext(ctx, d.createTable); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(m)
+}
+
+// applied returns the set of applied versions
+func (m *migrator) applied() (map[int]bool, error) {
+	rows, err := m.conn.QueryContext(m.ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+func (m *migrator) hasTable(name string) (bool, error) {
+	var count int
+	if err := m.conn.QueryRowContext(m.ctx, m.rebind(m.dialect.hasTable), name).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %w", name, err)
+	}
+	return count > 0, nil
+}
+
+// adoptExisting records the migrations a database created by AutoMigrate already has. Its
+// tables and columns are compared with the schema after each migration, and the first exact
+// match is recorded along with the migrations before it; later migrations that only change
+// column types still run. A database matching no version is refused, since the remaining
+// migrations would fail on it or leave it incomplete.
+func (m *migrator) adoptExisting() (map[int]bool, error) {
+	actual, err := m.shape()
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make(schemaShape)
+	var closest []string
+	for i, migration := range m.migrations {
+		expected.apply(migration.Up)
+		differences := expected.diff(actual)
+		if closest == nil || len(differences) < len(closest) {
+			closest = differences
+		}
+		if len(differences) > 0 {
+			continue
+		}
+
+		adopted := m.migrations[:i+1]
+		err := m.inTransaction(func(exec execer) error {
+			for _, migration := range adopted {
+				if err := m.record(exec, migration, true); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		utils.LogInfo("Existing schema recorded as migrated", map[string]interface{}{
+			"version": migration.Version,
+			"name":    migration.Name,
+		})
+
+		applied := make(map[int]bool, len(adopted))
+		for _, migration := range adopted {
+			applied[migration.Version] = true
+		}
+		return applied, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownSchema, strings.Join(closest, ", "))
+}
+
+// schemaShape holds the column names of each table
+type schemaShape map[string]map[string]bool
+
+// shape reads the tables and columns of the database, leaving out schema_migrations
+func (m *migrator) shape() (schemaShape, error) {
+	rows, err := m.conn.QueryContext(m.ctx, m.dialect.columns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database columns: %w", err)
+	}
+	defer rows.Close()
+
+	shape := make(schemaShape)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, fmt.Errorf("failed to read database columns: %w", err)
+		}
+		if table == "schema_migrations" {
+			continue
+		}
+		if shape[table] == nil {
+			shape[table] = make(map[string]bool)
+		}
+		shape[table][column] = true
+	}
+	return shape, rows.Err()
+}
+
+// apply adds the tables and columns a migration script creates and removes those it drops
+func (s schemaShape) apply(script string) {
+	for _, statement := range splitStatements(script) {
+		statement = stripComments(statement)
+
+		if match := createTableStatement.FindStringSubmatch(statement); match != nil {
+			columns := make(map[string]bool)
+			for _, column := range columnDefinition.FindAllStringSubmatch(match[2], -1) {
+				columns[column[1]] = true
+			}
+			s[match[1]] = columns
+		} else if match := dropTableStatement.FindStringSubmatch(statement); match != nil {
+			delete(s, match[1])
+		} else if match := alterTableStatement.FindStringSubmatch(statement); match != nil && s[match[1]] != nil {
+			for _, column := range addColumnClause.FindAllStringSubmatch(statement, -1) {
+				s[match[1]][column[1]] = true
+			}
+			for _, column := range dropColumnClause.FindAllStringSubmatch(statement, -1) {
+				delete(s[match[1]], column[1])
+			}
+		}
+	}
+}
+
+// diff lists the tables and columns that are missing from actual or only found there
+func (s schemaShape) diff(actual schemaShape) []string {
+	differences := []string{}
+	for table, columns := range s {
+		actualColumns, ok := actual[table]
+		if !ok {
+			differences = append(differences, "missing table "+table)
+			continue
+		}
+		for column := range columns {
+			if !actualColumns[column] {
+				differences = append(differences, "missing column "+table+"."+column)
+			}
+		}
+		for column := range actualColumns {
+			if !columns[column] {
+				differences = append(differences, "unexpected column "+table+"."+column)
+			}
+		}
+	}
+	for table := range actual {
+		if _, ok := s[table]; !ok {
+			differences = append(differences, "unexpected table "+table)
+		}
+	}
+	sort.Strings(differences)
+	return differences
+}
+
+// run applies (up) or reverts a migration and records it. On drivers with transactional DDL
+// a failed migration leaves nothing behind; on MySQL the statements before the failing one stay.
+func (m *migrator) run(migration Migration, up bool) error {
+	script, direction := migration.Up, "up"
+	if !up {
+		script, direction = migration.Down, "down"
+	}
+
+	skipped := false
+	err := m.inTransaction(func(exec execer) error {
+		// Without a lock another migrator may have run it since applied() was read
+		recorded, err := m.isRecorded(migration.Version)
+		if err != nil {
+			return err
+		}
+		if recorded == up {
+			skipped = true
+			return nil
+		}
+
+		for _, statement := range splitStatements(script) {
+			if _, err := exec.ExecContext(m.ctx, statement); err != nil {
+				return fmt.Errorf("migration %d_%s %s failed: %w", migration.Version, migration.Name, direction, err)
+			}
+		}
+		return m.record(exec, migration, up)
+	})
+	if err != nil {
+		utils.LogError(err, "Migration failed", map[string]interface{}{
+			"version":   migration.Version,
+			"name":      migration.Name,
+			"direction": direction,
+		})
+		return err
+	}
+	if skipped {
+		return nil
+	}
+
+	message := "Migration applied"
+	if !up {
+		message = "Migration reverted"
+	}
+	utils.LogInfo(message, map[string]interface{}{
+		"version": migration.Version,
+		"name":    migration.Name,
+	})
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// inTransaction runs fn in the dialect's transaction, or directly when DDL isn't transactional
+func (m *migrator) inTransaction(fn func(exec execer) error) error {
+	if m.dialect.begin == "" {
+		return fn(m.conn)
+	}
+
+	if _, err := m.conn.ExecContext(m.ctx, m.dialect.begin); err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	if err := fn(m.conn); err != nil {
+		if _, rollbackErr := m.conn.ExecContext(m.ctx, "ROLLBACK"); rollbackErr != nil {
+			utils.LogError(rollbackErr, "Failed to roll back migration", nil)
+		}
+		return err
+	}
+	if _, err := m.conn.ExecContext(m.ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("failed to commit migration: %w", err)
+	}
+	return nil
+}
+
+func (m *migrator) isRecorded(version int) (bool, error) {
+	var count int
+	err := m.conn.QueryRowContext(m.ctx, m.rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), version).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (m *migrator) record(exec execer, migration Migration, up bool) error {
+	var err error
+	if up {
+		_, err = exec.ExecContext(m.ctx, m.rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			migration.Version, migration.Name, time.Now().UTC())
+	} else {
+		_, err = exec.ExecContext(m.ctx, m.rebind("DELETE FROM schema_migrations WHERE version = ?"), migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// rebind turns ? placeholders into PostgreSQL's numbered ones
+func (m *migrator) rebind(query string) string {
+	if m.driver != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// splitStatements splits a migration script on semicolons that end a line
+func splitStatements(script string) []string {
+	var statements []string
+	for _, part := range statementEnd.Split(script, -1) {
+		if hasSQL(part) {
+			statements = append(statements, strings.TrimSpace(part))
+		}
+	}
+	return statements
+}
+
+// stripComments removes the -- comment lines of a statement
+func stripComments(statement string) string {
+	var lines []string
+	for _, line := range strings.Split(statement, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// hasSQL reports whether a chunk contains anything besides blank lines and -- comments
+func hasSQL(chunk string) bool {
+	for _, line := range strings.Split(chunk, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,273 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"test-wallet/models"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// baselineUser and baselineWallet are the models of the first release, whose AutoMigrate
+// created the schema of migration 1
+type baselineUser struct {
+	Id          string         `gorm:"type:char(36);primaryKey"`
+	Name        string         `gorm:"type:text;not null"`
+	PhoneNumber string         `gorm:"type:text;not null"`
+	Pin         string         `gorm:"type:text;not null"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+	Salt        string         `gorm:"type:text;not null"`
+	Wallet      baselineWallet `gorm:"foreignKey:UserId;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineWallet struct {
+	Id        string    `gorm:"type:char(36);primaryKey"`
+	UserId    string    `gorm:"type:char(36);not null"`
+	Address   string    `gorm:"type:text;not null"`
+	Mnemonic  string    `gorm:"type:text;not null"`
+	QRCode    string    `gorm:"type:text"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (baselineWallet) TableName() string { return "wallets" }
+
+var allModels = []interface{}{&models.User{}, &models.Wallet{}, &models.Account{}, &models.Token{}, &models.Transaction{}, &models.NonceState{}, &models.PinAttempt{}, &models.AuditEvent{}, &models.Session{}, &models.OtpChallenge{}, &models.TotpFactor{}, &models.TotpBackupCode{}, &models.SiweNonce{}, &models.ContractAbi{}}
+
+func openSQLite(t *testing.T, dsn string) *gorm.DB {
+	t.Helper()
+	conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return conn
+}
+
+func openMemory(t *testing.T) *gorm.DB {
+	t.Helper()
+	conn := openSQLite(t, "file:"+strings.ReplaceAll(t.Name(), "/", "_")+"?mode=memory&cache=shared")
+	sqlDB, _ := conn.DB()
+	sqlDB.SetMaxOpenConns(1)
+	return conn
+}
+
+func TestMigrateAndRollback(t *testing.T) {
+	conn := openMemory(t)
+
+	if err := CheckSchema(conn); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("empty database: got %v, want ErrSchemaOutdated", err)
+	}
+	if err := Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(conn); err != nil {
+		t.Fatalf("second run should be a no-op: %v", err)
+	}
+	if err := CheckSchema(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Rollback(conn, 1); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Migrator().HasTable("contract_abis") {
+		t.Fatal("contract_abis table survived rolling back its migration")
+	}
+	if err := CheckSchema(conn); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("after rollback: got %v, want ErrSchemaOutdated", err)
+	}
+
+	// Every down migration, back to an empty database
+	migrations, err := LoadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Rollback(conn, len(migrations)); err != nil {
+		t.Fatal(err)
+	}
+	if conn.Migrator().HasTable("users") {
+		t.Fatal("users table survived rolling back the baseline")
+	}
+
+	if err := Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := Status(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %d_%s is pending", status.Version, status.Name)
+		}
+	}
+}
+
+// The SQL migrations must create every column and index the models declare
+func TestMigrationsMatchModels(t *testing.T) {
+	conn := openMemory(t)
+	if err := Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	checkModels(t, conn)
+}
+
+// checkModels fails the test for every table, column or index of the models the database lacks
+func checkModels(t *testing.T, conn *gorm.DB) {
+	t.Helper()
+
+	for _, model := range allModels {
+		stmt := &gorm.Statement{DB: conn}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		table := stmt.Schema.Table
+		if !conn.Migrator().HasTable(table) {
+			t.Errorf("table %s is missing", table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !conn.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("column %s.%s is missing", table, field.DBName)
+			}
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			if !conn.Migrator().HasIndex(model, index.Name) {
+				t.Errorf("index %s on %s is missing", index.Name, table)
+			}
+		}
+	}
+}
+
+// A database created by the first release is recorded at the baseline and migrated from there
+func TestMigrateUpgradesBaselineSchema(t *testing.T) {
+	conn := openMemory(t)
+	if err := conn.AutoMigrate(&baselineUser{}, &baselineWallet{}); err != nil {
+		t.Fatal(err)
+	}
+	user := baselineUser{Id: "user-1", Name: "Test", PhoneNumber: "+15550000001", Pin: "pin", Salt: "salt"}
+	user.Wallet = baselineWallet{Id: "wallet-1", UserId: user.Id, Address: "0x0", Mnemonic: "sealed"}
+	if err := conn.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSchema(conn); err != nil {
+		t.Fatal(err)
+	}
+	checkModels(t, conn)
+
+	// Existing rows get the defaults of the added columns
+	var migrated models.User
+	if err := conn.Preload("Wallet").First(&migrated, "id = ?", "user-1").Error; err != nil {
+		t.Fatal(err)
+	}
+	if migrated.Status != models.UserStatusActive || migrated.Wallet.Type != models.WalletTypeMnemonic || migrated.Wallet.Mnemonic != "sealed" {
+		t.Fatalf("migrated user %+v", migrated)
+	}
+}
+
+// A database created by AutoMigrate just before versioned migrations has every migration
+func TestMigrateAdoptsAutoMigratedSchema(t *testing.T) {
+	conn := openMemory(t)
+	if err := conn.AutoMigrate(allModels...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSchema(conn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A schema between versions is refused rather than half migrated
+func TestMigrateRefusesUnknownSchema(t *testing.T) {
+	conn := openMemory(t)
+	if err := conn.AutoMigrate(&baselineUser{}, &baselineWallet{}, &models.Token{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Migrate(conn)
+	if !errors.Is(err, ErrUnknownSchema) {
+		t.Fatalf("got %v, want ErrUnknownSchema", err)
+	}
+	if !strings.Contains(err.Error(), "unexpected table tokens") {
+		t.Fatalf("error does not name the difference: %v", err)
+	}
+	if conn.Migrator().HasTable("transactions") {
+		t.Fatal("migrations ran on an unknown schema")
+	}
+	if err := CheckSchema(conn); !errors.Is(err, ErrSchemaOutdated) {
+		t.Fatalf("got %v, want ErrSchemaOutdated", err)
+	}
+}
+
+func TestConcurrentMigrators(t *testing.T) {
+	dsn := sqliteDSN(filepath.Join(t.TempDir(), "wallet.db"))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		conn := openSQLite(t, dsn)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- Migrate(conn)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Every driver must have the same migrations creating the same tables and columns, which
+// adoption relies on
+func TestMigrationsAgreeAcrossDrivers(t *testing.T) {
+	reference, err := LoadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, driver := range []string{"mysql", "postgres"} {
+		migrations, err := LoadMigrations(driver)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(migrations) != len(reference) {
+			t.Fatalf("%s has %d migrations, sqlite %d", driver, len(migrations), len(reference))
+		}
+
+		want, got := make(schemaShape), make(schemaShape)
+		for i, migration := range migrations {
+			if migration.Version != reference[i].Version || migration.Name != reference[i].Name {
+				t.Fatalf("%s migration %d_%s, sqlite %d_%s", driver, migration.Version, migration.Name, reference[i].Version, reference[i].Name)
+			}
+			want.apply(reference[i].Up)
+			got.apply(migration.Up)
+			if differences := want.diff(got); len(differences) > 0 {
+				t.Errorf("%s after %d_%s: %s", driver, migration.Version, migration.Name, strings.Join(differences, ", "))
+			}
+		}
+	}
+}
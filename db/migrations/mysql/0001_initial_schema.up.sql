@@ -0,0 +1,22 @@
+-- Baseline: the users and wallets tables AutoMigrate created before versioned migrations
+
+CREATE TABLE `users` (
+    `id` char(36),
+    `name` text NOT NULL,
+    `phone_number` text NOT NULL,
+    `pin` text NOT NULL,
+    `created_at` datetime(3) NULL,
+    `salt` text NOT NULL,
+    PRIMARY KEY (`id`)
+);
+
+CREATE TABLE `wallets` (
+    `id` char(36),
+    `user_id` char(36) NOT NULL,
+    `address` text NOT NULL,
+    `mnemonic` text NOT NULL,
+    `qr_code` text,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    CONSTRAINT `fk_users_wallet` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
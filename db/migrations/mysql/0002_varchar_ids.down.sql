@@ -0,0 +1,4 @@
+ALTER TABLE `wallets` DROP FOREIGN KEY `fk_users_wallet`;
+ALTER TABLE `users` MODIFY `id` char(36) NOT NULL;
+ALTER TABLE `wallets` MODIFY `id` char(36) NOT NULL, MODIFY `user_id` char(36) NOT NULL;
+ALTER TABLE `wallets` ADD CONSTRAINT `fk_users_wallet` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,7 @@
+-- IDs are varchar(36) instead of char(36) since PostgreSQL and SQLite are supported. The
+-- foreign key is dropped while both of its sides change.
+
+ALTER TABLE `wallets` DROP FOREIGN KEY `fk_users_wallet`;
+ALTER TABLE `users` MODIFY `id` varchar(36) NOT NULL;
+ALTER TABLE `wallets` MODIFY `id` varchar(36) NOT NULL, MODIFY `user_id` varchar(36) NOT NULL;
+ALTER TABLE `wallets` ADD CONSTRAINT `fk_users_wallet` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE;
@@ -0,0 +1,3 @@
+DROP TABLE `nonce_states`;
+DROP TABLE `transactions`;
+DROP TABLE `tokens`;
@@ -0,0 +1,52 @@
+-- Token registry, transaction history and the nonces allocated per sending address
+
+CREATE TABLE `tokens` (
+    `id` varchar(36),
+    `chain_id` bigint unsigned NOT NULL,
+    `address` varchar(42) NOT NULL,
+    `symbol` varchar(32) NOT NULL,
+    `decimals` tinyint unsigned NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_tokens_chain_address` (`chain_id`,`address`),
+    INDEX `idx_tokens_symbol` (`symbol`)
+);
+
+CREATE TABLE `transactions` (
+    `id` varchar(36),
+    `user_id` varchar(36) NOT NULL,
+    `chain_id` bigint unsigned NOT NULL,
+    `hash` varchar(66) NOT NULL,
+    `from_address` varchar(42) NOT NULL,
+    `to_address` varchar(42) NOT NULL,
+    `value` varchar(78) NOT NULL,
+    `token_symbol` varchar(32) NOT NULL,
+    `token_address` varchar(42),
+    `nonce` bigint unsigned NOT NULL,
+    `gas_limit` bigint unsigned NOT NULL,
+    `gas_price` varchar(78),
+    `max_fee_per_gas` varchar(78),
+    `max_priority_fee_per_gas` varchar(78),
+    `type` tinyint unsigned NOT NULL,
+    `data` text,
+    `status` varchar(16) NOT NULL,
+    `error` text,
+    `block_number` bigint unsigned,
+    `replaces_hash` varchar(66),
+    `replaced_by_hash` varchar(66),
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_transactions_user_id` (`user_id`),
+    UNIQUE INDEX `idx_transactions_hash` (`hash`),
+    INDEX `idx_transactions_from_address` (`from_address`),
+    INDEX `idx_transactions_status` (`status`)
+);
+
+CREATE TABLE `nonce_states` (
+    `chain_id` bigint unsigned,
+    `address` varchar(42),
+    `next_nonce` bigint unsigned NOT NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`chain_id`,`address`)
+);
@@ -0,0 +1,2 @@
+DROP TABLE `accounts`;
+ALTER TABLE `wallets` DROP COLUMN `type`;
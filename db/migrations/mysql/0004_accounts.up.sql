@@ -0,0 +1,19 @@
+-- Wallet types for imported keys, and named HD accounts derived from a wallet
+
+ALTER TABLE `wallets` ADD COLUMN `type` varchar(16) NOT NULL DEFAULT 'mnemonic';
+
+CREATE TABLE `accounts` (
+    `id` varchar(36),
+    `wallet_id` varchar(36) NOT NULL,
+    `user_id` varchar(36) NOT NULL,
+    `name` varchar(64) NOT NULL,
+    `address_index` int unsigned NOT NULL,
+    `address` varchar(42) NOT NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_accounts_wallet_name` (`wallet_id`,`name`),
+    INDEX `idx_accounts_user_id` (`user_id`),
+    INDEX `idx_accounts_address` (`address`),
+    UNIQUE INDEX `idx_accounts_wallet_index` (`wallet_id`,`address_index`),
+    CONSTRAINT `fk_wallets_accounts` FOREIGN KEY (`wallet_id`) REFERENCES `wallets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
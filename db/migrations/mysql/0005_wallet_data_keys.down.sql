@@ -0,0 +1,3 @@
+DROP INDEX `idx_wallets_key_id` ON `wallets`;
+ALTER TABLE `wallets` DROP COLUMN `key_id`;
+ALTER TABLE `wallets` DROP COLUMN `data_key`;
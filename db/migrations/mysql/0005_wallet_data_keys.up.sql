@@ -0,0 +1,5 @@
+-- Per-wallet data keys wrapped by a master key, and the ID of that key
+
+ALTER TABLE `wallets` ADD COLUMN `data_key` text;
+ALTER TABLE `wallets` ADD COLUMN `key_id` varchar(64);
+CREATE INDEX `idx_wallets_key_id` ON `wallets`(`key_id`);
@@ -0,0 +1,23 @@
+-- PIN attempt counters per user and IP, and the audit log
+
+CREATE TABLE `pin_attempts` (
+    `attempt_key` varchar(128),
+    `failures` bigint NOT NULL DEFAULT 0,
+    `blocked_until` datetime(3) NULL,
+    `locked_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`attempt_key`)
+);
+
+CREATE TABLE `audit_events` (
+    `id` varchar(36),
+    `type` varchar(32) NOT NULL,
+    `user_id` varchar(36),
+    `ip` varchar(64),
+    `details` text,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_audit_events_created_at` (`created_at`),
+    INDEX `idx_audit_events_type` (`type`),
+    INDEX `idx_audit_events_user_id` (`user_id`)
+);
@@ -0,0 +1,18 @@
+-- Login sessions with rotating refresh tokens
+
+CREATE TABLE `sessions` (
+    `id` varchar(36),
+    `user_id` varchar(36) NOT NULL,
+    `refresh_token_hash` varchar(64) NOT NULL,
+    `previous_refresh_hash` varchar(64),
+    `user_agent` varchar(255),
+    `ip` varchar(64),
+    `created_at` datetime(3) NULL,
+    `last_used_at` datetime(3) NULL,
+    `expires_at` datetime(3) NOT NULL,
+    `revoked_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_sessions_user_id` (`user_id`),
+    UNIQUE INDEX `idx_sessions_refresh_token_hash` (`refresh_token_hash`),
+    INDEX `idx_sessions_previous_refresh_hash` (`previous_refresh_hash`)
+);
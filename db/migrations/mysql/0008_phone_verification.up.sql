@@ -0,0 +1,17 @@
+-- Account status until the phone number is verified, and SMS one-time codes
+
+ALTER TABLE `users` ADD COLUMN `status` varchar(16) NOT NULL DEFAULT 'active';
+ALTER TABLE `users` ADD COLUMN `phone_verified_at` datetime(3) NULL;
+
+CREATE TABLE `otp_challenges` (
+    `id` varchar(36),
+    `phone_number` varchar(20) NOT NULL,
+    `purpose` varchar(32) NOT NULL,
+    `code_hash` varchar(64) NOT NULL,
+    `attempts` bigint NOT NULL DEFAULT 0,
+    `expires_at` datetime(3) NOT NULL,
+    `consumed_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_otp_challenges_phone_number` (`phone_number`)
+);
@@ -0,0 +1,28 @@
+-- Authenticator app second factor with its backup codes
+
+CREATE TABLE `totp_factors` (
+    `user_id` varchar(36),
+    `secret` text NOT NULL,
+    `data_key` text NOT NULL,
+    `key_id` varchar(64),
+    `confirmed_at` datetime(3) NULL,
+    `last_used_step` bigint NOT NULL DEFAULT 0,
+    `eth_threshold` varchar(78),
+    `token_threshold` varchar(78),
+    `new_recipients` boolean NOT NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`user_id`),
+    INDEX `idx_totp_factors_key_id` (`key_id`)
+);
+
+CREATE TABLE `totp_backup_codes` (
+    `id` varchar(36),
+    `user_id` varchar(36) NOT NULL,
+    `code_hash` varchar(64) NOT NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    INDEX `idx_totp_backup_codes_user_id` (`user_id`),
+    UNIQUE INDEX `idx_totp_backup_codes_code_hash` (`code_hash`)
+);
@@ -0,0 +1,10 @@
+-- Single-use nonces for Sign-In with Ethereum
+
+CREATE TABLE `siwe_nonces` (
+    `nonce` varchar(64),
+    `expires_at` datetime(3) NOT NULL,
+    `used_at` datetime(3) NULL,
+    `created_at` datetime(3) NULL,
+    PRIMARY KEY (`nonce`),
+    INDEX `idx_siwe_nonces_expires_at` (`expires_at`)
+);
@@ -0,0 +1,14 @@
+-- Contract ABIs saved by users
+
+CREATE TABLE `contract_abis` (
+    `id` varchar(36),
+    `user_id` varchar(36) NOT NULL,
+    `chain_id` bigint unsigned NOT NULL,
+    `name` varchar(64) NOT NULL,
+    `address` varchar(42),
+    `abi` text NOT NULL,
+    `created_at` datetime(3) NULL,
+    `updated_at` datetime(3) NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE INDEX `idx_contract_abis_user_chain_name` (`user_id`,`chain_id`,`name`)
+);
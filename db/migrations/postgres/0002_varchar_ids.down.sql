@@ -0,0 +1,4 @@
+ALTER TABLE "wallets" DROP CONSTRAINT "fk_users_wallet";
+ALTER TABLE "users" ALTER COLUMN "id" TYPE char(36);
+ALTER TABLE "wallets" ALTER COLUMN "id" TYPE char(36), ALTER COLUMN "user_id" TYPE char(36);
+ALTER TABLE "wallets" ADD CONSTRAINT "fk_users_wallet" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
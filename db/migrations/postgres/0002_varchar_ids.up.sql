@@ -0,0 +1,7 @@
+-- IDs are varchar(36) instead of char(36), which PostgreSQL pads with blanks. The foreign
+-- key is dropped while both of its sides change.
+
+ALTER TABLE "wallets" DROP CONSTRAINT "fk_users_wallet";
+ALTER TABLE "users" ALTER COLUMN "id" TYPE varchar(36);
+ALTER TABLE "wallets" ALTER COLUMN "id" TYPE varchar(36), ALTER COLUMN "user_id" TYPE varchar(36);
+ALTER TABLE "wallets" ADD CONSTRAINT "fk_users_wallet" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE;
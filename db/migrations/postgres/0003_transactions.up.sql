@@ -0,0 +1,52 @@
+-- Token registry, transaction history and the nonces allocated per sending address
+
+CREATE TABLE "tokens" (
+    "id" varchar(36),
+    "chain_id" bigint NOT NULL,
+    "address" varchar(42) NOT NULL,
+    "symbol" varchar(32) NOT NULL,
+    "decimals" smallint NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_tokens_symbol" ON "tokens" ("symbol");
+CREATE UNIQUE INDEX "idx_tokens_chain_address" ON "tokens" ("chain_id","address");
+
+CREATE TABLE "transactions" (
+    "id" varchar(36),
+    "user_id" varchar(36) NOT NULL,
+    "chain_id" bigint NOT NULL,
+    "hash" varchar(66) NOT NULL,
+    "from_address" varchar(42) NOT NULL,
+    "to_address" varchar(42) NOT NULL,
+    "value" varchar(78) NOT NULL,
+    "token_symbol" varchar(32) NOT NULL,
+    "token_address" varchar(42),
+    "nonce" bigint NOT NULL,
+    "gas_limit" bigint NOT NULL,
+    "gas_price" varchar(78),
+    "max_fee_per_gas" varchar(78),
+    "max_priority_fee_per_gas" varchar(78),
+    "type" smallint NOT NULL,
+    "data" text,
+    "status" varchar(16) NOT NULL,
+    "error" text,
+    "block_number" bigint,
+    "replaces_hash" varchar(66),
+    "replaced_by_hash" varchar(66),
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_transactions_status" ON "transactions" ("status");
+CREATE INDEX "idx_transactions_from_address" ON "transactions" ("from_address");
+CREATE UNIQUE INDEX "idx_transactions_hash" ON "transactions" ("hash");
+CREATE INDEX "idx_transactions_user_id" ON "transactions" ("user_id");
+
+CREATE TABLE "nonce_states" (
+    "chain_id" bigint,
+    "address" varchar(42),
+    "next_nonce" bigint NOT NULL,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("chain_id","address")
+);
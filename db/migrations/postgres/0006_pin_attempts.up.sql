@@ -0,0 +1,23 @@
+-- PIN attempt counters per user and IP, and the audit log
+
+CREATE TABLE "pin_attempts" (
+    "attempt_key" varchar(128),
+    "failures" bigint NOT NULL DEFAULT 0,
+    "blocked_until" timestamptz,
+    "locked_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("attempt_key")
+);
+
+CREATE TABLE "audit_events" (
+    "id" varchar(36),
+    "type" varchar(32) NOT NULL,
+    "user_id" varchar(36),
+    "ip" varchar(64),
+    "details" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_audit_events_user_id" ON "audit_events" ("user_id");
+CREATE INDEX "idx_audit_events_type" ON "audit_events" ("type");
+CREATE INDEX "idx_audit_events_created_at" ON "audit_events" ("created_at");
@@ -0,0 +1,18 @@
+-- Login sessions with rotating refresh tokens
+
+CREATE TABLE "sessions" (
+    "id" varchar(36),
+    "user_id" varchar(36) NOT NULL,
+    "refresh_token_hash" varchar(64) NOT NULL,
+    "previous_refresh_hash" varchar(64),
+    "user_agent" varchar(255),
+    "ip" varchar(64),
+    "created_at" timestamptz,
+    "last_used_at" timestamptz,
+    "expires_at" timestamptz NOT NULL,
+    "revoked_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_sessions_previous_refresh_hash" ON "sessions" ("previous_refresh_hash");
+CREATE UNIQUE INDEX "idx_sessions_refresh_token_hash" ON "sessions" ("refresh_token_hash");
+CREATE INDEX "idx_sessions_user_id" ON "sessions" ("user_id");
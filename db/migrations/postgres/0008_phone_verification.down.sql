@@ -0,0 +1,3 @@
+DROP TABLE "otp_challenges";
+ALTER TABLE "users" DROP COLUMN "phone_verified_at";
+ALTER TABLE "users" DROP COLUMN "status";
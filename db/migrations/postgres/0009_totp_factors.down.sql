@@ -0,0 +1,2 @@
+DROP TABLE "totp_backup_codes";
+DROP TABLE "totp_factors";
@@ -0,0 +1,14 @@
+-- Contract ABIs saved by users
+
+CREATE TABLE "contract_abis" (
+    "id" varchar(36),
+    "user_id" varchar(36) NOT NULL,
+    "chain_id" bigint NOT NULL,
+    "name" varchar(64) NOT NULL,
+    "address" varchar(42),
+    "abi" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX "idx_contract_abis_user_chain_name" ON "contract_abis" ("user_id","chain_id","name");
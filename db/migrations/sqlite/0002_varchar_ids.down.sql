@@ -0,0 +1,2 @@
+-- IDs are varchar(36) on MySQL and PostgreSQL. SQLite keeps the char(36) columns of the
+-- baseline: both have text affinity, and changing a column type means rebuilding the table.
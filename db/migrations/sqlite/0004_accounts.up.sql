@@ -0,0 +1,19 @@
+-- Wallet types for imported keys, and named HD accounts derived from a wallet
+
+ALTER TABLE `wallets` ADD COLUMN `type` varchar(16) NOT NULL DEFAULT 'mnemonic';
+
+CREATE TABLE `accounts` (
+    `id` text,
+    `wallet_id` text NOT NULL,
+    `user_id` text NOT NULL,
+    `name` varchar(64) NOT NULL,
+    `address_index` integer NOT NULL,
+    `address` varchar(42) NOT NULL,
+    `created_at` datetime,
+    PRIMARY KEY (`id`),
+    CONSTRAINT `fk_wallets_accounts` FOREIGN KEY (`wallet_id`) REFERENCES `wallets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX `idx_accounts_wallet_name` ON `accounts`(`wallet_id`,`name`);
+CREATE UNIQUE INDEX `idx_accounts_wallet_index` ON `accounts`(`wallet_id`,`address_index`);
+CREATE INDEX `idx_accounts_address` ON `accounts`(`address`);
+CREATE INDEX `idx_accounts_user_id` ON `accounts`(`user_id`);
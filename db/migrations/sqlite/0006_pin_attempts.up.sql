@@ -0,0 +1,23 @@
+-- PIN attempt counters per user and IP, and the audit log
+
+CREATE TABLE `pin_attempts` (
+    `attempt_key` varchar(128),
+    `failures` integer NOT NULL DEFAULT 0,
+    `blocked_until` datetime,
+    `locked_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`attempt_key`)
+);
+
+CREATE TABLE `audit_events` (
+    `id` text,
+    `type` varchar(32) NOT NULL,
+    `user_id` text,
+    `ip` varchar(64),
+    `details` text,
+    `created_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX `idx_audit_events_user_id` ON `audit_events`(`user_id`);
+CREATE INDEX `idx_audit_events_type` ON `audit_events`(`type`);
+CREATE INDEX `idx_audit_events_created_at` ON `audit_events`(`created_at`);
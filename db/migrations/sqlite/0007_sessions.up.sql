@@ -0,0 +1,18 @@
+-- Login sessions with rotating refresh tokens
+
+CREATE TABLE `sessions` (
+    `id` text,
+    `user_id` text NOT NULL,
+    `refresh_token_hash` text NOT NULL,
+    `previous_refresh_hash` text,
+    `user_agent` varchar(255),
+    `ip` varchar(64),
+    `created_at` datetime,
+    `last_used_at` datetime,
+    `expires_at` datetime NOT NULL,
+    `revoked_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX `idx_sessions_refresh_token_hash` ON `sessions`(`refresh_token_hash`);
+CREATE INDEX `idx_sessions_user_id` ON `sessions`(`user_id`);
+CREATE INDEX `idx_sessions_previous_refresh_hash` ON `sessions`(`previous_refresh_hash`);
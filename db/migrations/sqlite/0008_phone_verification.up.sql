@@ -0,0 +1,17 @@
+-- Account status until the phone number is verified, and SMS one-time codes
+
+ALTER TABLE `users` ADD COLUMN `status` varchar(16) NOT NULL DEFAULT 'active';
+ALTER TABLE `users` ADD COLUMN `phone_verified_at` datetime;
+
+CREATE TABLE `otp_challenges` (
+    `id` text,
+    `phone_number` varchar(20) NOT NULL,
+    `purpose` varchar(32) NOT NULL,
+    `code_hash` text NOT NULL,
+    `attempts` integer NOT NULL DEFAULT 0,
+    `expires_at` datetime NOT NULL,
+    `consumed_at` datetime,
+    `created_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE INDEX `idx_otp_challenges_phone_number` ON `otp_challenges`(`phone_number`);
@@ -0,0 +1,28 @@
+-- Authenticator app second factor with its backup codes
+
+CREATE TABLE `totp_factors` (
+    `user_id` text,
+    `secret` text NOT NULL,
+    `data_key` text NOT NULL,
+    `key_id` varchar(64),
+    `confirmed_at` datetime,
+    `last_used_step` integer NOT NULL DEFAULT 0,
+    `eth_threshold` varchar(78),
+    `token_threshold` varchar(78),
+    `new_recipients` numeric NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`user_id`)
+);
+CREATE INDEX `idx_totp_factors_key_id` ON `totp_factors`(`key_id`);
+
+CREATE TABLE `totp_backup_codes` (
+    `id` text,
+    `user_id` text NOT NULL,
+    `code_hash` text NOT NULL,
+    `used_at` datetime,
+    `created_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX `idx_totp_backup_codes_code_hash` ON `totp_backup_codes`(`code_hash`);
+CREATE INDEX `idx_totp_backup_codes_user_id` ON `totp_backup_codes`(`user_id`);
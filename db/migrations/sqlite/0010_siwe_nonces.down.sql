@@ -0,0 +1 @@
+DROP TABLE `siwe_nonces`;
@@ -0,0 +1,10 @@
+-- Single-use nonces for Sign-In with Ethereum
+
+CREATE TABLE `siwe_nonces` (
+    `nonce` varchar(64),
+    `expires_at` datetime NOT NULL,
+    `used_at` datetime,
+    `created_at` datetime,
+    PRIMARY KEY (`nonce`)
+);
+CREATE INDEX `idx_siwe_nonces_expires_at` ON `siwe_nonces`(`expires_at`);
@@ -0,0 +1,14 @@
+-- Contract ABIs saved by users
+
+CREATE TABLE `contract_abis` (
+    `id` text,
+    `user_id` text NOT NULL,
+    `chain_id` integer NOT NULL,
+    `name` varchar(64) NOT NULL,
+    `address` varchar(42),
+    `abi` text NOT NULL,
+    `created_at` datetime,
+    `updated_at` datetime,
+    PRIMARY KEY (`id`)
+);
+CREATE UNIQUE INDEX `idx_contract_abis_user_chain_name` ON `contract_abis`(`user_id`,`chain_id`,`name`);
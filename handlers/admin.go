@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"test-wallet/models"
+	"test-wallet/services"
+	"test-wallet/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AdminHandler struct {
+	pinGuard *services.PinGuard
+	chains   *services.ChainRegistry
+}
+
+func NewAdminHandler(conn *gorm.DB, chains *services.ChainRegistry) *AdminHandler {
+	return &AdminHandler{
+		pinGuard: services.NewPinGuard(conn),
+		chains:   chains,
+	}
+}
+
+// UnlockUser clears the PIN backoff or lockout of a user
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	userID := c.Param("id")
+
+	cleared, err := h.pinGuard.UnlockUser(userID)
+	if err != nil {
+		utils.LogError(err, "Failed to unlock user", map[string]interface{}{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to unlock user"})
+		return
+	}
+	if !cleared {
+		c.JSON(http.StatusOK, models.MessageResponse{Message: "User was not locked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "User unlocked"})
+}
+
+// UnlockIP clears the PIN backoff or lockout of a client IP
+func (h *AdminHandler) UnlockIP(c *gin.Context) {
+	ip := c.Param("ip")
+
+	cleared, err := h.pinGuard.UnlockIP(ip)
+	if err != nil {
+		utils.LogError(err, "Failed to unlock IP", map[string]interface{}{
+			"ip": ip,
+		})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to unlock IP"})
+		return
+	}
+	if !cleared {
+		c.JSON(http.StatusOK, models.MessageResponse{Message: "IP was not locked"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "IP unlocked"})
+}
+
+// ChainsHealth reports the health of every chain's RPC endpoints
+func (h *AdminHandler) ChainsHealth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"chains": h.chains.Health()})
+}
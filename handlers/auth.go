@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"test-wallet/models"
@@ -8,19 +9,22 @@ import (
 	"test-wallet/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
-	userService *services.UserService
+	userService    *services.UserService
+	sessionService *services.SessionService
 }
 
-func NewAuthHandler() (*AuthHandler, error) {
-	userService, err := services.NewUserService()
+func NewAuthHandler(users services.UserStore, conn *gorm.DB, chains *services.ChainRegistry) (*AuthHandler, error) {
+	userService, err := services.NewUserService(users, conn, chains)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user service: %w", err)
 	}
 	return &AuthHandler{
-		userService: userService,
+		userService:    userService,
+		sessionService: services.NewSessionService(conn),
 	}, nil
 }
 
@@ -38,6 +42,10 @@ func (h *AuthHandler) RegisterUser(c *gin.Context) {
 		utils.LogError(err, "Failed to register user", map[string]interface{}{
 			"phone_number": req.PhoneNumber,
 		})
+		if errors.Is(err, services.ErrInvalidWalletImport) || errors.Is(err, services.ErrInvalidPhoneNumber) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -48,9 +56,10 @@ func (h *AuthHandler) RegisterUser(c *gin.Context) {
 	})
 
 	c.JSON(http.StatusCreated, models.RegisterResponse{
-		Message:       "User registered successfully",
+		Message:       "User registered successfully; verify the phone number with the code sent by SMS",
 		UserID:        user.Id,
 		WalletAddress: user.Wallet.Address,
+		Status:        user.Status,
 	})
 }
 
@@ -63,12 +72,19 @@ func (h *AuthHandler) LoginUser(c *gin.Context) {
 		return
 	}
 
-	token, user, err := h.userService.LoginUser(&req)
+	tokens, user, err := h.userService.LoginUser(&req, clientInfo(c))
 	if err != nil {
 		utils.LogError(err, "Failed to login user", map[string]interface{}{
 			"phone_number": req.PhoneNumber,
 		})
-		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		switch {
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrPhoneNotVerified):
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		}
 		return
 	}
 
@@ -76,8 +92,216 @@ func (h *AuthHandler) LoginUser(c *gin.Context) {
 		"user_id": user.Id,
 	})
 
-	c.JSON(http.StatusOK, models.LoginResponse{
-		Token: token,
+	c.JSON(http.StatusOK, loginResponse(tokens, user))
+}
+
+// VerifyPhone activates a pending account with the code sent to its phone number
+func (h *AuthHandler) VerifyPhone(c *gin.Context) {
+	var req models.VerifyPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	if err := h.userService.VerifyPhone(&req); err != nil {
+		utils.LogError(err, "Failed to verify phone number", map[string]interface{}{
+			"phone_number": req.PhoneNumber,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidPhoneNumber):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidOTP):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to verify phone number"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Phone number verified"})
+}
+
+// ResendOTP sends a new verification code to a pending account
+func (h *AuthHandler) ResendOTP(c *gin.Context) {
+	var req models.ResendOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	if err := h.userService.ResendVerification(&req); err != nil {
+		utils.LogError(err, "Failed to resend verification code", map[string]interface{}{
+			"phone_number": req.PhoneNumber,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidPhoneNumber):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrOTPRecentlySent):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to send verification code"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "If the number is awaiting verification, a new code has been sent"})
+}
+
+// ResetPin handles changing a forgotten or known PIN
+func (h *AuthHandler) ResetPin(c *gin.Context) {
+	var req models.ResetPinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	if (req.OldPin == "") == (req.Mnemonic == "") {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Provide either old_pin or mnemonic"})
+		return
+	}
+
+	if err := h.userService.ResetPin(&req); err != nil {
+		utils.LogError(err, "Failed to reset PIN", map[string]interface{}{
+			"phone_number": req.PhoneNumber,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to reset PIN"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "PIN reset successfully"})
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	tokens, err := h.sessionService.Refresh(req.RefreshToken, clientInfo(c))
+	if err != nil {
+		utils.LogError(err, "Failed to refresh token", nil)
+		if errors.Is(err, services.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the session of the calling access token
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sessionID := c.GetString("session_id")
+
+	if err := h.sessionService.RevokeSession(userID, sessionID); err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+		utils.LogError(err, "Failed to log out", map[string]interface{}{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Logged out successfully"})
+}
+
+// ListSessions returns the authenticated user's active sessions
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	sessions, err := h.sessionService.ListSessions(userID, c.GetString("session_id"))
+	if err != nil {
+		utils.LogError(err, "Failed to list sessions", map[string]interface{}{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession revokes one of the authenticated user's sessions
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sessionID := c.Param("id")
+
+	if err := h.sessionService.RevokeSession(userID, sessionID); err != nil {
+		utils.LogError(err, "Failed to revoke session", map[string]interface{}{
+			"user_id":    userID,
+			"session_id": sessionID,
+		})
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Session revoked"})
+}
+
+// SIWENonce issues a nonce for a Sign-In with Ethereum message
+func (h *AuthHandler) SIWENonce(c *gin.Context) {
+	nonce, err := h.userService.SIWENonce()
+	if err != nil {
+		utils.LogError(err, "Failed to issue SIWE nonce", nil)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to issue nonce"})
+		return
+	}
+
+	c.JSON(http.StatusOK, nonce)
+}
+
+// SIWEVerify signs a user in with a signed Sign-In with Ethereum message
+func (h *AuthHandler) SIWEVerify(c *gin.Context) {
+	var req models.SiweVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	tokens, user, err := h.userService.LoginWithSIWE(&req, clientInfo(c))
+	if err != nil {
+		utils.LogError(err, "Failed to sign in with Ethereum", nil)
+		switch {
+		case errors.Is(err, services.ErrInvalidSIWE):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrPhoneNotVerified):
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to sign in"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, loginResponse(tokens, user))
+}
+
+// loginResponse builds the response of a successful login
+func loginResponse(tokens *models.TokenPair, user *models.User) models.LoginResponse {
+	return models.LoginResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		SessionId:    tokens.SessionId,
 		User: struct {
 			ID          string `json:"id"`
 			Name        string `json:"name"`
@@ -95,5 +319,13 @@ func (h *AuthHandler) LoginUser(c *gin.Context) {
 				Address: user.Wallet.Address,
 			},
 		},
-	})
+	}
+}
+
+// clientInfo describes the calling device for session metadata
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
 }
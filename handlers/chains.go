@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+	"test-wallet/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListChains lists the networks accepted by the chain parameter of the wallet APIs
+func ListChains(chains *services.ChainRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"chains": chains.Info()})
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"test-wallet/models"
+	"test-wallet/services"
+	"test-wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CallContract runs a read-only contract call and returns the decoded outputs
+func (h *WalletHandler) CallContract(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var request models.ContractCallRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	result, err := h.walletService.CallContract(c, userID, &request)
+	if err != nil {
+		utils.LogError(err, "Failed to call contract", map[string]interface{}{
+			"contract": request.Contract,
+			"method":   request.Method,
+		})
+		respondContractError(c, err, "Failed to call contract")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// TransactContract signs and sends a transaction calling a contract method
+func (h *WalletHandler) TransactContract(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var request models.ContractTransactRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	result, err := h.walletService.TransactContract(userID, &request)
+	if err != nil {
+		utils.LogError(err, "Failed to send contract transaction", map[string]interface{}{
+			"contract": request.Contract,
+			"method":   request.Method,
+		})
+		respondContractError(c, err, "Failed to send contract transaction")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListContractAbis lists the user's stored ABIs for the selected chain
+func (h *WalletHandler) ListContractAbis(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	abis, err := h.walletService.ListContractAbis(userID, c.Query("chain"))
+	if err != nil {
+		utils.LogError(err, "Failed to list contract ABIs", map[string]interface{}{
+			"user_id": userID,
+		})
+		respondContractError(c, err, "Failed to list contract ABIs")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"abis": abis})
+}
+
+// SaveContractAbi stores an ABI for the selected chain
+func (h *WalletHandler) SaveContractAbi(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var request models.SaveContractAbiRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	contractAbi, err := h.walletService.SaveContractAbi(userID, &request)
+	if err != nil {
+		utils.LogError(err, "Failed to save contract ABI", map[string]interface{}{
+			"user_id": userID,
+			"name":    request.Name,
+		})
+		respondContractError(c, err, "Failed to save contract ABI")
+		return
+	}
+
+	c.JSON(http.StatusOK, contractAbi)
+}
+
+// DeleteContractAbi removes a stored ABI
+func (h *WalletHandler) DeleteContractAbi(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	if err := h.walletService.DeleteContractAbi(userID, c.Param("id"), c.Query("chain")); err != nil {
+		utils.LogError(err, "Failed to delete contract ABI", map[string]interface{}{
+			"user_id": userID,
+		})
+		respondContractError(c, err, "Failed to delete contract ABI")
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Contract ABI deleted"})
+}
+
+// respondContractError maps contract errors to HTTP responses
+func respondContractError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidContractCall), errors.Is(err, services.ErrUnsupportedChain):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrContractReverted):
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrContractAbiNotFound), errors.Is(err, services.ErrAccountNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrInvalidPin):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrTooManyAttempts):
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrInvalidTOTP):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrTOTPRequired):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: fallback})
+	}
+}
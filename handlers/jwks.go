@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+	"test-wallet/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys that verify access tokens
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, middleware.PublicJWKS())
+}
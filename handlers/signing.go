@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"test-wallet/models"
+	"test-wallet/services"
+	"test-wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SignMessage signs a personal_sign message with one of the user's accounts
+func (h *WalletHandler) SignMessage(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var request models.SignMessageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	signature, err := h.walletService.SignMessage(userID, &request)
+	if err != nil {
+		utils.LogError(err, "Failed to sign message", map[string]interface{}{
+			"user_id": userID,
+		})
+		respondSigningError(c, err, "Failed to sign message")
+		return
+	}
+
+	c.JSON(http.StatusOK, signature)
+}
+
+// SignTypedData signs EIP-712 typed data with one of the user's accounts
+func (h *WalletHandler) SignTypedData(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var request models.SignTypedDataRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	signature, err := h.walletService.SignTypedData(userID, &request)
+	if err != nil {
+		utils.LogError(err, "Failed to sign typed data", map[string]interface{}{
+			"user_id": userID,
+		})
+		respondSigningError(c, err, "Failed to sign typed data")
+		return
+	}
+
+	c.JSON(http.StatusOK, signature)
+}
+
+// respondSigningError maps signing errors to HTTP responses
+func respondSigningError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPin):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrTooManyAttempts):
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrInvalidTOTP):
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrTOTPRequired):
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrInvalidTypedData), errors.Is(err, services.ErrUnsupportedChain):
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, services.ErrAccountNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: fallback})
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"test-wallet/models"
+	"test-wallet/services"
+	"test-wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTOTPStatus returns the authenticated user's TOTP factor and transfer policy
+func (h *WalletHandler) GetTOTPStatus(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	status, err := h.walletService.GetTOTPStatus(userID)
+	if err != nil {
+		utils.LogError(err, "Failed to get TOTP status", map[string]interface{}{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to get TOTP status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollTOTP starts enrolling an authenticator app
+func (h *WalletHandler) EnrollTOTP(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var request models.EnrollTOTPRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	enrollment, err := h.walletService.EnrollTOTP(userID, &request)
+	if err != nil {
+		utils.LogError(err, "Failed to enroll TOTP", map[string]interface{}{
+			"user_id": userID,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidPin):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTOTPAlreadyEnabled):
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to enroll TOTP"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP activates the enrolled authenticator and returns the backup codes
+func (h *WalletHandler) ConfirmTOTP(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var request models.ConfirmTOTPRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	confirmation, err := h.walletService.ConfirmTOTP(userID, &request)
+	if err != nil {
+		utils.LogError(err, "Failed to confirm TOTP", map[string]interface{}{
+			"user_id": userID,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidTOTP):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTOTPNotEnrolled):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTOTPAlreadyEnabled):
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to confirm TOTP"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, confirmation)
+}
+
+// DisableTOTP removes the authenticated user's authenticator
+func (h *WalletHandler) DisableTOTP(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var request models.DisableTOTPRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	if err := h.walletService.DisableTOTP(userID, &request); err != nil {
+		utils.LogError(err, "Failed to disable TOTP", map[string]interface{}{
+			"user_id": userID,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidPin), errors.Is(err, services.ErrInvalidTOTP):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTOTPNotEnrolled):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to disable TOTP"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "TOTP disabled"})
+}
+
+// UpdateTOTPPolicy changes when transfers need a TOTP code
+func (h *WalletHandler) UpdateTOTPPolicy(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var request models.TOTPPolicyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	status, err := h.walletService.UpdateTOTPPolicy(userID, &request)
+	if err != nil {
+		utils.LogError(err, "Failed to update TOTP policy", map[string]interface{}{
+			"user_id": userID,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidTOTP):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTOTPNotEnrolled):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidThreshold):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to update TOTP policy"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"test-wallet/models"
 	"test-wallet/services"
 	"test-wallet/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type WalletHandler struct {
@@ -14,26 +16,30 @@ type WalletHandler struct {
 	qrService     *services.QRService
 }
 
-func NewWalletHandler() (*WalletHandler, error) {
-	walletService, err := services.NewWalletService()
+func NewWalletHandler(users services.UserStore, conn *gorm.DB, chains *services.ChainRegistry) (*WalletHandler, error) {
+	walletService, err := services.NewWalletService(users, conn, chains)
 	if err != nil {
 		return nil, err
 	}
 
 	return &WalletHandler{
 		walletService: walletService,
-		qrService:     services.NewQRService(),
+		qrService:     services.NewQRService(users),
 	}, nil
 }
 
-// GetBalance handles fetching the ETH balance of a given address
+// GetBalance handles fetching the native balance of a given address on the selected chain
 func (h *WalletHandler) GetBalance(c *gin.Context) {
 	address := c.Param("address")
-	balance, err := h.walletService.GetBalance(c, address)
+	balance, err := h.walletService.GetBalance(c, address, c.Query("chain"))
 	if err != nil {
 		utils.LogError(err, "Failed to get balance", map[string]interface{}{
 			"address": address,
 		})
+		if errors.Is(err, services.ErrUnsupportedChain) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to get balance"})
 		return
 	}
@@ -69,7 +75,20 @@ func (h *WalletHandler) SendETH(c *gin.Context) {
 			"to":     request.ToAddress,
 			"amount": request.AmountInETH,
 		})
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to send ETH"})
+		switch {
+		case errors.Is(err, services.ErrInvalidPin):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidTOTP):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTOTPRequired):
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrUnsupportedChain):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to send ETH"})
+		}
 		return
 	}
 
@@ -103,23 +122,266 @@ func (h *WalletHandler) SendERC20Token(c *gin.Context) {
 	if err != nil {
 		utils.LogError(err, "Failed to send ERC20 token", map[string]interface{}{
 			"to":     request.ToAddress,
-			"amount": request.AmountInUSD,
+			"token":  request.Token,
+			"amount": request.Amount,
 		})
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to send ERC20 token"})
+		switch {
+		case errors.Is(err, services.ErrInvalidPin):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidTOTP):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTOTPRequired):
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrUnsupportedChain):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to send ERC20 token"})
+		}
 		return
 	}
 
 	utils.LogInfo("ERC20 token sent successfully", map[string]interface{}{
 		"to":      request.ToAddress,
-		"amount":  request.AmountInUSD,
+		"token":   request.Token,
+		"amount":  request.Amount,
 		"tx_hash": txHash,
 	})
 
 	c.JSON(http.StatusOK, gin.H{"transaction_hash": txHash})
 }
 
-// RecoverWalletHandler handles wallet recovery
+// ListTokens lists the supported tokens with the authenticated user's balances
+func (h *WalletHandler) ListTokens(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError(nil, "User not authenticated", nil)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "user not authenticated"})
+		return
+	}
+
+	tokens, err := h.walletService.ListTokenBalances(c, userID.(string), c.Query("account"), c.Query("chain"))
+	if err != nil {
+		utils.LogError(err, "Failed to list tokens", map[string]interface{}{
+			"user_id": userID,
+		})
+		if errors.Is(err, services.ErrUnsupportedChain) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to list tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// ListAccounts lists the accounts derived from the authenticated user's wallet
+func (h *WalletHandler) ListAccounts(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError(nil, "User not authenticated", nil)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "user not authenticated"})
+		return
+	}
+
+	accounts, err := h.walletService.ListAccounts(userID.(string))
+	if err != nil {
+		utils.LogError(err, "Failed to list accounts", map[string]interface{}{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to list accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accounts": accounts})
+}
+
+// CreateAccount derives the next account from the authenticated user's mnemonic
+func (h *WalletHandler) CreateAccount(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError(nil, "User not authenticated", nil)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "user not authenticated"})
+		return
+	}
+
+	var request models.CreateAccountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	account, err := h.walletService.CreateAccount(userID.(string), &request)
+	if err != nil {
+		utils.LogError(err, "Failed to create account", map[string]interface{}{
+			"user_id": userID,
+			"name":    request.Name,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidPin):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrAccountExists):
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrNotHDWallet):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to create account"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+// ImportWallet replaces the authenticated user's wallet with an imported mnemonic, private key or keystore
+func (h *WalletHandler) ImportWallet(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError(nil, "User not authenticated", nil)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "user not authenticated"})
+		return
+	}
+
+	var request models.ImportWalletRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	wallet, err := h.walletService.ImportWallet(userID.(string), &request)
+	if err != nil {
+		utils.LogError(err, "Failed to import wallet", map[string]interface{}{
+			"user_id": userID,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidPin):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidWalletImport):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to import wallet"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ImportWalletResponse{
+		Message: "Wallet imported successfully",
+		Type:    wallet.Type,
+		Address: wallet.Address,
+	})
+}
+
+// ListTransactions returns the authenticated user's transaction history
+func (h *WalletHandler) ListTransactions(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError(nil, "User not authenticated", nil)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "user not authenticated"})
+		return
+	}
+
+	var filter models.TransactionFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		utils.LogError(err, "Invalid query parameters", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid query parameters"})
+		return
+	}
+	filter.UserId = userID.(string)
+
+	transactions, err := h.walletService.ListTransactions(&filter)
+	if err != nil {
+		utils.LogError(err, "Failed to list transactions", map[string]interface{}{
+			"user_id": userID,
+		})
+		if errors.Is(err, services.ErrUnsupportedChain) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to list transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
+// SpeedUpTransaction re-sends a pending transaction at the same nonce with higher fees
+func (h *WalletHandler) SpeedUpTransaction(c *gin.Context) {
+	h.replaceTransaction(c, false)
+}
+
+// CancelTransaction replaces a pending transaction with a 0-value transfer to the sender
+func (h *WalletHandler) CancelTransaction(c *gin.Context) {
+	h.replaceTransaction(c, true)
+}
+
+func (h *WalletHandler) replaceTransaction(c *gin.Context, cancel bool) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError(nil, "User not authenticated", nil)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "user not authenticated"})
+		return
+	}
+
+	var request models.ReplaceTransactionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	hash := c.Param("hash")
+	txHash, err := h.walletService.ReplaceTransaction(userID.(string), hash, &request, cancel)
+	if err != nil {
+		utils.LogError(err, "Failed to replace transaction", map[string]interface{}{
+			"tx_hash": hash,
+			"cancel":  cancel,
+		})
+		switch {
+		case errors.Is(err, services.ErrTransactionNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTransactionNotPending):
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidPin):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to replace transaction"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transaction_hash": txHash,
+		"replaces_hash":    hash,
+	})
+}
+
+// RecoverWalletHandler re-attaches a wallet to the authenticated user from its mnemonic.
+// Only the address is returned; keys are exported through ExportWallet.
 func (h *WalletHandler) RecoverWalletHandler(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError(nil, "User not authenticated", nil)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "user not authenticated"})
+		return
+	}
+
 	var req models.RecoverWalletRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.LogError(err, "Invalid request payload", nil)
@@ -127,23 +389,82 @@ func (h *WalletHandler) RecoverWalletHandler(c *gin.Context) {
 		return
 	}
 
-	address, privateKey, err := services.RecoverWalletFromMnemonic(req.Mnemonic, req.DerivationPath)
+	wallet, err := h.walletService.RecoverWallet(userID.(string), &req)
 	if err != nil {
-		utils.LogError(err, "Failed to recover wallet", nil)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to recover wallet"})
+		utils.LogError(err, "Failed to recover wallet", map[string]interface{}{
+			"user_id": userID,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidPin):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidWalletImport):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrWalletExists):
+			c.JSON(http.StatusConflict, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to recover wallet"})
+		}
 		return
 	}
 
 	utils.LogInfo("Wallet recovered successfully", map[string]interface{}{
-		"address": address,
+		"user_id": userID,
+		"address": wallet.Address,
 	})
 
-	c.JSON(http.StatusOK, gin.H{
-		"address":     address,
-		"private_key": privateKey,
+	c.JSON(http.StatusOK, models.ImportWalletResponse{
+		Message: "Wallet recovered successfully",
+		Type:    wallet.Type,
+		Address: wallet.Address,
 	})
 }
 
+// ExportWallet returns an account's private key as a password-encrypted Keystore V3 file
+func (h *WalletHandler) ExportWallet(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError(nil, "User not authenticated", nil)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: "user not authenticated"})
+		return
+	}
+
+	var req models.ExportWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError(err, "Invalid request payload", nil)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "Invalid request payload"})
+		return
+	}
+
+	export, err := h.walletService.ExportKeystore(userID.(string), &req)
+	if err != nil {
+		utils.LogError(err, "Failed to export wallet", map[string]interface{}{
+			"user_id": userID,
+		})
+		switch {
+		case errors.Is(err, services.ErrInvalidPin):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrInvalidTOTP):
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrTOTPRequired):
+			c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrExportNotConfirmed):
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, services.ErrAccountNotFound):
+			c.JSON(http.StatusNotFound, models.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Failed to export wallet"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
 // GetWalletQR generates a QR code for the user's wallet address
 func (h *WalletHandler) GetWalletQR(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
@@ -1,16 +1,31 @@
 package main
 
 import (
+	"os"
+
 	"test-wallet/bootstrap"
 	"test-wallet/utils"
 )
 
 func main() {
+	// Run an admin command, e.g. rotate-master-key, instead of the server
+	if len(os.Args) > 1 {
+		if err := bootstrap.RunCommand(os.Args[1:]); err != nil {
+			utils.LogFatal(err, "Command failed", nil)
+		}
+		return
+	}
+
 	// Initialize application
 	if err := bootstrap.InitializeApp(); err != nil {
 		utils.LogFatal(err, "Failed to initialize application", nil)
 	}
 
+	// Start background workers
+	if err := bootstrap.StartWorkers(); err != nil {
+		utils.LogFatal(err, "Failed to start background workers", nil)
+	}
+
 	// Setup router and server
 	router := bootstrap.SetupRouter()
 	srv := bootstrap.SetupServer(router)
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"test-wallet/config"
+	"test-wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware only lets requests through that carry the configured ADMIN_API_KEY in
+// the X-Admin-Key header. Admin endpoints are disabled while no key is configured.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminKey := config.AppConfig.SecurityConfig.AdminAPIKey
+		if adminKey == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin API is disabled"})
+			c.Abort()
+			return
+		}
+
+		provided := c.GetHeader("X-Admin-Key")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(adminKey)) != 1 {
+			utils.LogError(nil, "Invalid admin key", map[string]interface{}{
+				"path": c.Request.URL.Path,
+				"ip":   c.ClientIP(),
+			})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin key"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -6,22 +6,26 @@ import (
 	"time"
 
 	"test-wallet/config"
+	"test-wallet/repository"
 	"test-wallet/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 // JWTClaims represents the claims in the JWT token
 type JWTClaims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a new JWT token for the given user ID
-func GenerateToken(userID string) (string, error) {
+// GenerateToken generates a short-lived access token for the given user and session
+func GenerateToken(userID, sessionID string) (string, error) {
 	claims := JWTClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(config.AppConfig.JWTConfig.Expiration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -29,12 +33,14 @@ func GenerateToken(userID string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.AppConfig.JWTConfig.Secret))
+	return signToken(claims)
 }
 
-// AuthMiddleware is a middleware that checks for a valid JWT token
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware is a middleware that checks for a valid JWT token whose session has not
+// been revoked
+func AuthMiddleware(conn *gorm.DB) gin.HandlerFunc {
+	sessionRepo := repository.NewSessionRepository(conn)
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -59,9 +65,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		tokenString := parts[1]
 		claims := &JWTClaims{}
 
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.AppConfig.JWTConfig.Secret), nil
-		})
+		token, err := jwt.ParseWithClaims(tokenString, claims, verificationKey,
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
 
 		if err != nil || !token.Valid {
 			utils.LogError(err, "Invalid token", map[string]interface{}{
@@ -72,8 +77,21 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Set the user ID in the context for use in handlers
+		// Tokens of logged-out or revoked sessions are rejected before they expire
+		active, err := sessionRepo.IsSessionActive(claims.SessionID)
+		if err != nil || !active {
+			utils.LogError(err, "Session revoked or expired", map[string]interface{}{
+				"path":       c.Request.URL.Path,
+				"session_id": claims.SessionID,
+			})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session revoked or expired"})
+			c.Abort()
+			return
+		}
+
+		// Set the user and session IDs in the context for use in handlers
 		c.Set("user_id", claims.UserID)
+		c.Set("session_id", claims.SessionID)
 		utils.LogDebug("User authenticated", map[string]interface{}{
 			"user_id": claims.UserID,
 			"path":    c.Request.URL.Path,
@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"test-wallet/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// hmacKeyID is the kid of HS256 tokens signed with JWT_SECRET
+const hmacKeyID = "hs256"
+
+// jwtKey is a key that verifies tokens and, if it has a private part, signs them
+type jwtKey struct {
+	method    jwt.SigningMethod
+	signKey   interface{} // nil for verify-only keys
+	verifyKey interface{}
+}
+
+// jwtKeyring holds the keys loaded by InitJWTKeys
+type jwtKeyring struct {
+	signingKeyID string
+	keys         map[string]*jwtKey
+}
+
+var keyring *jwtKeyring
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// InitJWTKeys loads the signing and verification keys for the configured algorithm.
+// In production it refuses to start with the default HS256 secret.
+func InitJWTKeys() error {
+	cfg := config.AppConfig.JWTConfig
+
+	switch cfg.Algorithm {
+	case "", jwt.SigningMethodHS256.Alg():
+		if cfg.Secret == "" {
+			return errors.New("JWT_SECRET must be set")
+		}
+		if cfg.Secret == config.DefaultJWTSecret && config.AppConfig.ServerConfig.Environment == "production" {
+			return errors.New("JWT_SECRET must not be the default value in production")
+		}
+		keyring = &jwtKeyring{
+			signingKeyID: hmacKeyID,
+			keys: map[string]*jwtKey{hmacKeyID: {
+				method:    jwt.SigningMethodHS256,
+				signKey:   []byte(cfg.Secret),
+				verifyKey: []byte(cfg.Secret),
+			}},
+		}
+		return nil
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+	default:
+		return fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+
+	ring := &jwtKeyring{signingKeyID: cfg.SigningKeyID, keys: make(map[string]*jwtKey)}
+	for _, entry := range strings.Split(cfg.KeyFiles, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(entry, ":")
+		if !ok || kid == "" || path == "" {
+			return errors.New("JWT_KEY_FILES must be KID:PATH pairs")
+		}
+
+		key, err := loadJWTKey(path)
+		if err != nil {
+			return fmt.Errorf("failed to load JWT key %q: %w", kid, err)
+		}
+		if key.method.Alg() != cfg.Algorithm {
+			return fmt.Errorf("JWT key %q is %s, expected %s", kid, key.method.Alg(), cfg.Algorithm)
+		}
+		ring.keys[kid] = key
+	}
+
+	signing, ok := ring.keys[ring.signingKeyID]
+	if !ok || signing.signKey == nil {
+		return fmt.Errorf("JWT signing key %q must be a private key in JWT_KEY_FILES", ring.signingKeyID)
+	}
+
+	keyring = ring
+	return nil
+}
+
+// loadJWTKey parses an RSA or Ed25519 key from a PEM file. PKCS#1 and PKCS#8 private
+// keys and PKIX public keys are accepted.
+func loadJWTKey(path string) (*jwtKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &jwtKey{method: jwt.SigningMethodRS256, signKey: key, verifyKey: key.Public()}, nil
+	case *rsa.PublicKey:
+		return &jwtKey{method: jwt.SigningMethodRS256, verifyKey: key}, nil
+	case ed25519.PrivateKey:
+		return &jwtKey{method: jwt.SigningMethodEdDSA, signKey: key, verifyKey: key.Public()}, nil
+	case ed25519.PublicKey:
+		return &jwtKey{method: jwt.SigningMethodEdDSA, verifyKey: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+}
+
+// signToken signs claims with the current signing key and sets its kid header
+func signToken(claims jwt.Claims) (string, error) {
+	if keyring == nil {
+		return "", errors.New("JWT keys are not initialized")
+	}
+	key := keyring.keys[keyring.signingKeyID]
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = keyring.signingKeyID
+	return token.SignedString(key.signKey)
+}
+
+// verificationKey selects the key named by the token's kid and checks that the token's
+// algorithm matches it. Tokens without a kid are HS256 tokens issued before key IDs.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if keyring == nil {
+		return nil, errors.New("JWT keys are not initialized")
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = hmacKeyID
+	}
+	key, ok := keyring.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
+
+// PublicJWKS returns the public keys that verify tokens, for downstream services.
+// HS256 secrets are never published.
+func PublicJWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	if keyring == nil {
+		return jwks
+	}
+
+	for kid, key := range keyring.keys {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
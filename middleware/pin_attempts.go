@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"test-wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PinAttemptLimiter tracks failed PIN attempts per client IP
+type PinAttemptLimiter interface {
+	// CheckIP returns an error while the IP is in a backoff or lockout
+	CheckIP(ip string) error
+	// RecordIPFailure counts a failed attempt from the IP
+	RecordIPFailure(ip string)
+}
+
+// PinAttemptLimit rejects requests from client IPs that are blocked for failed PINs, and
+// counts a failure whenever the wrapped handler answers 401. It is meant for routes whose
+// only 401 response is a wrong PIN or credentials, so it must run after AuthMiddleware.
+func PinAttemptLimit(limiter PinAttemptLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if err := limiter.CheckIP(ip); err != nil {
+			utils.LogInfo("PIN attempts blocked for IP", map[string]interface{}{
+				"ip":   ip,
+				"path": c.FullPath(),
+			})
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+
+		if c.Writer.Status() == http.StatusUnauthorized {
+			limiter.RecordIPFailure(ip)
+		}
+	}
+}
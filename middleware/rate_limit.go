@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"test-wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateWindow counts the requests of one user within a fixed window
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// UserRateLimit allows each authenticated user at most limit requests per window.
+// Counters are kept in memory, so the limit applies per server instance.
+// It must run after AuthMiddleware, which sets user_id.
+func UserRateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	var mu sync.Mutex
+	windows := make(map[string]*rateWindow)
+
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		if userID == "" || limit <= 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		mu.Lock()
+		// Drop expired windows so the map does not grow with every user seen
+		for id, w := range windows {
+			if now.Sub(w.start) >= window {
+				delete(windows, id)
+			}
+		}
+		w, ok := windows[userID]
+		if !ok {
+			w = &rateWindow{start: now}
+			windows[userID] = w
+		}
+		w.count++
+		count, retryAfter := w.count, window-now.Sub(w.start)
+		mu.Unlock()
+
+		if count > limit {
+			utils.LogInfo("Rate limit exceeded", map[string]interface{}{
+				"user_id": userID,
+				"path":    c.FullPath(),
+			})
+			c.Header("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
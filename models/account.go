@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// DefaultAccountName is the name of the account at address index 0 created with every wallet
+const DefaultAccountName = "default"
+
+// Account is an address derived from a wallet's mnemonic at m/44'/60'/0'/0/<Index>
+type Account struct {
+	Id        string    `gorm:"size:36;primaryKey" json:"id"`
+	WalletId  string    `gorm:"size:36;not null;uniqueIndex:idx_accounts_wallet_index;uniqueIndex:idx_accounts_wallet_name" json:"wallet_id"`
+	UserId    string    `gorm:"size:36;not null;index" json:"user_id"`
+	Name      string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_accounts_wallet_name" json:"name"`
+	Index     uint32    `gorm:"column:address_index;not null;uniqueIndex:idx_accounts_wallet_index" json:"index"`
+	Address   string    `gorm:"type:varchar(42);not null;index" json:"address"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// CreateAccountRequest derives the next account from the user's mnemonic
+type CreateAccountRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+	Pin  string `json:"pin" binding:"required"` // User's PIN for decrypting mnemonic
+}
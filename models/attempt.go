@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// PinAttempt tracks consecutive failed PIN attempts for a user or a client IP
+type PinAttempt struct {
+	Key          string     `gorm:"column:attempt_key;type:varchar(128);primaryKey" json:"key"` // "user:<id>" or "ip:<address>"
+	Failures     int        `gorm:"not null;default:0" json:"failures"`
+	BlockedUntil *time.Time `json:"blocked_until"` // No attempts are accepted before this time
+	LockedAt     *time.Time `json:"locked_at"`     // Set while the key is in a lockout rather than a backoff
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Audit event types
+const (
+	AuditPinLockout = "pin_lockout" // Too many failed PIN attempts locked a user or IP
+	AuditPinUnlock  = "pin_unlock"  // An admin cleared a lockout
+
+	AuditRefreshTokenReuse = "refresh_token_reuse" // A rotated refresh token was presented again
+
+	AuditTOTPEnabled       = "totp_enabled"        // A TOTP authenticator was enrolled
+	AuditTOTPDisabled      = "totp_disabled"       // A TOTP authenticator was removed
+	AuditTOTPPolicyChanged = "totp_policy_changed" // The transfer policy for TOTP codes changed
+	AuditBackupCodeUsed    = "backup_code_used"    // A TOTP backup code was consumed
+	AuditMessageSigned     = "message_signed"      // A personal_sign message was signed
+	AuditTypedDataSigned   = "typed_data_signed"   // EIP-712 typed data was signed
+)
+
+// AuditEvent is an append-only record of a security-relevant action
+type AuditEvent struct {
+	Id        string    `gorm:"size:36;primaryKey" json:"id"`
+	Type      string    `gorm:"type:varchar(32);not null;index" json:"type"`
+	UserId    string    `gorm:"size:36;index" json:"user_id,omitempty"`
+	IP        string    `gorm:"type:varchar(64)" json:"ip,omitempty"`
+	Details   string    `gorm:"type:text" json:"details,omitempty"` // JSON object
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
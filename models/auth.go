@@ -6,6 +6,8 @@ type RegisterUserRequest struct {
 	Name        string `json:"name"`
 	PhoneNumber string `json:"phone_number"`
 	Pin         string `json:"pin"`
+	// Optional existing wallet; a new one is generated when empty
+	WalletImport
 }
 
 type LoginRequest struct {
@@ -13,13 +15,42 @@ type LoginRequest struct {
 	Pin         string `json:"pin" binding:"required"`
 }
 
+// User statuses. Pending users registered but have not verified their phone number.
+const (
+	UserStatusPending = "pending"
+	UserStatusActive  = "active"
+)
+
 type User struct {
-	Id          string    `gorm:"type:char(36);primaryKey" json:"id"`
+	Id          string    `gorm:"size:36;primaryKey" json:"id"`
 	Name        string    `gorm:"type:text;not null" json:"name"`
 	PhoneNumber string    `gorm:"type:text;not null" json:"phone_number"`
 	Pin         string    `gorm:"type:text;not null" json:"pin"`
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
 	Salt        string    `gorm:"type:text;not null" json:"salt"`
+	// Status is UserStatusPending until the phone number is verified
+	Status          string     `gorm:"type:varchar(16);not null;default:active" json:"status"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
 	// Has One relationship (no foreignKey tag here)
 	Wallet Wallet `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"wallet"`
 }
+
+// ResetPinRequest changes a user's PIN. Either the current PIN (change flow)
+// or the wallet mnemonic (forgotten PIN flow) must be supplied as proof of ownership.
+type ResetPinRequest struct {
+	PhoneNumber string `json:"phone_number" binding:"required"`
+	OldPin      string `json:"old_pin"`  // Current PIN, for the change-PIN flow
+	Mnemonic    string `json:"mnemonic"` // Wallet mnemonic (or private key of imported key wallets), for the forgotten-PIN flow
+	NewPin      string `json:"new_pin" binding:"required"`
+}
+
+// VerifyPhoneRequest activates a pending account with the code sent to its phone number
+type VerifyPhoneRequest struct {
+	PhoneNumber string `json:"phone_number" binding:"required"`
+	Code        string `json:"code" binding:"required"`
+}
+
+// ResendOTPRequest asks for a new verification code for a pending account
+type ResendOTPRequest struct {
+	PhoneNumber string `json:"phone_number" binding:"required"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// ChainInfo describes a network the wallet can use
+type ChainInfo struct {
+	ChainId      uint64 `json:"chain_id"`
+	Name         string `json:"name"` // Value for the chain parameter of the wallet APIs
+	NativeSymbol string `json:"native_symbol"`
+	ExplorerURL  string `json:"explorer_url,omitempty"`
+	EIP1559      bool   `json:"eip1559"`
+	Default      bool   `json:"default"` // Used when a request does not select a chain
+}
+
+// ChainHealth reports the RPC endpoints of a chain
+type ChainHealth struct {
+	Name      string           `json:"name"`
+	ChainId   uint64           `json:"chain_id"`
+	Endpoints []EndpointStatus `json:"endpoints"`
+}
+
+// EndpointStatus describes the health of one RPC endpoint
+type EndpointStatus struct {
+	Host      string `json:"host"` // URL paths are left out as they often contain API keys
+	Healthy   bool   `json:"healthy"`
+	Disabled  bool   `json:"disabled"` // The endpoint serves another chain
+	Lagging   bool   `json:"lagging"`  // The endpoint trails the highest head seen
+	LatencyMs int64  `json:"latency_ms"`
+	Failures  int    `json:"failures"` // Consecutive transient failures
+	Head      uint64 `json:"head"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ContractAbi is an ABI saved by a user for calling a contract on a given chain
+type ContractAbi struct {
+	Id        string          `gorm:"size:36;primaryKey" json:"id"`
+	UserId    string          `gorm:"size:36;not null;uniqueIndex:idx_contract_abis_user_chain_name" json:"-"`
+	ChainId   uint64          `gorm:"not null;uniqueIndex:idx_contract_abis_user_chain_name" json:"chain_id"`
+	Name      string          `gorm:"type:varchar(64);not null;uniqueIndex:idx_contract_abis_user_chain_name" json:"name"`
+	Address   string          `gorm:"type:varchar(42)" json:"address,omitempty"` // Default contract for calls using this ABI
+	Abi       json.RawMessage `gorm:"type:text;not null" json:"abi"`
+	CreatedAt time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// SaveContractAbiRequest stores an ABI under a name, replacing any ABI with the same name on the chain
+type SaveContractAbiRequest struct {
+	Name    string          `json:"name" binding:"required,max=64"`
+	Address string          `json:"address"` // Optional default contract address
+	Abi     json.RawMessage `json:"abi" binding:"required"`
+	Chain   string          `json:"chain"` // Chain name or ID, defaults to DEFAULT_CHAIN
+}
+
+// ContractCallRequest calls a contract method described by an inline or stored ABI
+type ContractCallRequest struct {
+	Contract    string            `json:"contract"`                  // Defaults to the stored ABI's address
+	Abi         json.RawMessage   `json:"abi"`                       // ABI JSON array or a single method fragment
+	AbiId       string            `json:"abi_id"`                    // Stored ABI to use instead of abi
+	Method      string            `json:"method" binding:"required"` // Name or signature, e.g. transfer(address,uint256)
+	Args        []json.RawMessage `json:"args"`                      // One JSON value per input, see readme for the encoding
+	FromAccount string            `json:"from_account"`              // Account name or address, defaults to the first account
+	Chain       string            `json:"chain"`                     // Chain name or ID, defaults to DEFAULT_CHAIN
+}
+
+// ContractTransactRequest sends a signed transaction calling a contract method
+type ContractTransactRequest struct {
+	ContractCallRequest
+	Value    string `json:"value"`                                               // ETH sent with the call, e.g. "0.01"
+	Pin      string `json:"pin" binding:"required"`                              // User's PIN for decrypting mnemonic
+	FeeTier  string `json:"fee_tier" binding:"omitempty,oneof=slow normal fast"` // Defaults to normal
+	TotpCode string `json:"totp_code"`                                           // Required by the user's TOTP policy
+}
+
+// ContractValue is a decoded method output
+type ContractValue struct {
+	Name  string      `json:"name,omitempty"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"` // Integers and bytes as strings, tuples as objects
+}
+
+// ContractCallResponse holds the decoded outputs of a read-only call
+type ContractCallResponse struct {
+	Method  string          `json:"method"`
+	Outputs []ContractValue `json:"outputs"`
+}
+
+// ContractTransactResponse identifies the transaction sent for a contract call
+type ContractTransactResponse struct {
+	Method          string `json:"method"`
+	TransactionHash string `json:"transaction_hash"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// NonceState tracks the next nonce to hand out for an address on a chain
+type NonceState struct {
+	ChainId   uint64    `gorm:"primaryKey;autoIncrement:false" json:"chain_id"`
+	Address   string    `gorm:"type:varchar(42);primaryKey" json:"address"`
+	NextNonce uint64    `gorm:"not null" json:"next_nonce"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// OTP purposes
+const (
+	OTPPurposeVerifyPhone = "verify_phone"
+)
+
+// OtpChallenge is a one-time code sent to a phone number. Only a SHA-256 hash of the
+// code is stored; a new challenge for the same number and purpose supersedes older ones.
+type OtpChallenge struct {
+	Id          string     `gorm:"size:36;primaryKey" json:"id"`
+	PhoneNumber string     `gorm:"type:varchar(20);not null;index" json:"phone_number"` // E.164
+	Purpose     string     `gorm:"type:varchar(32);not null" json:"purpose"`
+	CodeHash    string     `gorm:"size:64;not null" json:"-"`
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	ConsumedAt  *time.Time `json:"consumed_at"` // Set when verified or superseded
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -1,8 +1,11 @@
 package models
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Access token lifetime in seconds
+	SessionId    string `json:"session_id"`
+	User         struct {
 		ID          string `json:"id"`
 		Name        string `json:"name"`
 		PhoneNumber string `json:"phone_number"`
@@ -16,6 +19,7 @@ type RegisterResponse struct {
 	Message       string `json:"message"`
 	UserID        string `json:"user_id"`
 	WalletAddress string `json:"wallet_address"`
+	Status        string `json:"status"`
 }
 
 type ErrorResponse struct {
@@ -26,3 +30,7 @@ type QRCodeResponse struct {
 	QRCode  string `json:"qr_code"`
 	Address string `json:"address"`
 }
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
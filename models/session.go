@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Session is a login on one device. Its refresh token rotates on every use; only
+// SHA-256 hashes of the current and previous token are stored.
+type Session struct {
+	Id                  string     `gorm:"size:36;primaryKey" json:"id"`
+	UserId              string     `gorm:"size:36;not null;index" json:"-"`
+	RefreshTokenHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	PreviousRefreshHash string     `gorm:"size:64;index" json:"-"` // Presenting this again means the token was stolen
+	UserAgent           string     `gorm:"type:varchar(255)" json:"user_agent"`
+	IP                  string     `gorm:"type:varchar(64)" json:"ip"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	LastUsedAt          time.Time  `json:"last_used_at"`
+	ExpiresAt           time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt           *time.Time `json:"revoked_at,omitempty"`
+	Current             bool       `gorm:"-" json:"current"` // Set when listing: the session of the calling token
+}
+
+// ClientInfo describes the device a session was created from
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// TokenPair is issued at login and on every refresh
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Access token lifetime in seconds
+	SessionId    string `json:"session_id"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "encoding/json"
+
+// SignMessageRequest signs a message with personal_sign (EIP-191)
+type SignMessageRequest struct {
+	Message     string `json:"message" binding:"required"` // UTF-8 text, or 0x-prefixed hex for raw bytes
+	Pin         string `json:"pin" binding:"required"`     // User's PIN for decrypting mnemonic
+	FromAccount string `json:"from_account"`               // Account name or address, defaults to the first account
+}
+
+// SignTypedDataRequest signs EIP-712 typed data as eth_signTypedData_v4 does
+type SignTypedDataRequest struct {
+	TypedData   json.RawMessage `json:"typed_data" binding:"required"` // Object with types, primaryType, domain and message
+	Pin         string          `json:"pin" binding:"required"`
+	FromAccount string          `json:"from_account"`
+	Chain       string          `json:"chain"`     // Chain the domain's chainId must match, defaults to DEFAULT_CHAIN
+	TotpCode    string          `json:"totp_code"` // TOTP or backup code, required once the user has an authenticator
+}
+
+// SignatureResponse is a signature with a readable preview of what was signed
+type SignatureResponse struct {
+	Address   string      `json:"address"`
+	Signature string      `json:"signature"` // 65 bytes, hex, with v as 27 or 28
+	Hash      string      `json:"hash"`      // Digest that was signed
+	Preview   interface{} `json:"preview"`
+}
+
+// MessagePreview describes a personal_sign message
+type MessagePreview struct {
+	Text   string `json:"text,omitempty"` // Set when the message is valid UTF-8
+	Hex    string `json:"hex"`
+	Length int    `json:"length"`
+}
+
+// TypedDataPreview describes EIP-712 typed data
+type TypedDataPreview struct {
+	Domain      TypedDataDomainPreview `json:"domain"`
+	PrimaryType string                 `json:"primary_type"`
+	Message     interface{}            `json:"message"` // Field names, types and values, nested for structs
+}
+
+// TypedDataDomainPreview lists the EIP-712 domain fields that identify the requesting dApp
+type TypedDataDomainPreview struct {
+	Name              string `json:"name,omitempty"`
+	Version           string `json:"version,omitempty"`
+	ChainId           string `json:"chain_id,omitempty"`
+	VerifyingContract string `json:"verifying_contract,omitempty"`
+	Salt              string `json:"salt,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// SiweNonce is a single-use nonce for a Sign-In with Ethereum (EIP-4361) message
+type SiweNonce struct {
+	Nonce     string     `gorm:"type:varchar(64);primaryKey" json:"nonce"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// SiweNonceResponse holds a nonce to embed in the message and the fields the server expects
+type SiweNonceResponse struct {
+	Nonce     string    `json:"nonce"`
+	Domain    string    `json:"domain"`
+	URI       string    `json:"uri"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SiweVerifyRequest signs in with an EIP-4361 message signed by one of the user's addresses
+type SiweVerifyRequest struct {
+	Message   string `json:"message" binding:"required"`
+	Signature string `json:"signature" binding:"required"` // 65-byte personal_sign signature, hex
+}
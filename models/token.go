@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Token is an ERC-20 token supported by the wallet on a given chain
+type Token struct {
+	Id        string    `gorm:"size:36;primaryKey" json:"id"`
+	ChainId   uint64    `gorm:"not null;uniqueIndex:idx_tokens_chain_address" json:"chain_id"`
+	Address   string    `gorm:"type:varchar(42);not null;uniqueIndex:idx_tokens_chain_address" json:"address"`
+	Symbol    string    `gorm:"type:varchar(32);not null;index" json:"symbol"`
+	Decimals  uint8     `gorm:"not null" json:"decimals"` // Read from the contract's decimals()
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TokenBalance is a supported token together with the user's balance of it
+type TokenBalance struct {
+	Symbol   string `json:"symbol"`
+	Address  string `json:"address"`
+	ChainId  uint64 `json:"chain_id"`
+	Decimals uint8  `json:"decimals"`
+	Balance  string `json:"balance"` // Human-readable amount, already scaled by decimals
+}
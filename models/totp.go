@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// TotpFactor is a user's RFC 6238 authenticator. The secret is encrypted under a data key
+// wrapped by the master key. Until ConfirmedAt is set the enrollment is pending and the
+// factor is not enforced.
+type TotpFactor struct {
+	UserId       string     `gorm:"size:36;primaryKey" json:"-"`
+	Secret       string     `gorm:"type:text;not null" json:"-"`
+	DataKey      string     `gorm:"type:text;not null" json:"-"`
+	KeyId        string     `gorm:"type:varchar(64);index" json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"` // Time step of the last accepted code, against replays
+	// Transfer policy: a code is required above these amounts (empty uses the configured
+	// default) and, if NewRecipients is set, for recipients never sent to before
+	EthThreshold   string    `gorm:"type:varchar(78)" json:"eth_threshold"`   // In ETH
+	TokenThreshold string    `gorm:"type:varchar(78)" json:"token_threshold"` // In whole token units
+	NewRecipients  bool      `gorm:"not null" json:"new_recipients"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TotpBackupCode is a single-use recovery code for a TOTP factor; only its hash is stored
+type TotpBackupCode struct {
+	Id        string     `gorm:"size:36;primaryKey" json:"id"`
+	UserId    string     `gorm:"size:36;not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// EnrollTOTPRequest starts enrolling an authenticator
+type EnrollTOTPRequest struct {
+	Pin string `json:"pin" binding:"required"`
+}
+
+// EnrollTOTPResponse holds the secret to add to the authenticator app
+type EnrollTOTPResponse struct {
+	Secret string `json:"secret"` // Base32, for manual entry
+	URI    string `json:"uri"`    // otpauth:// URI, usually shown as a QR code
+}
+
+// ConfirmTOTPRequest completes enrollment with a code from the authenticator
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ConfirmTOTPResponse returns the backup codes, which are shown only once
+type ConfirmTOTPResponse struct {
+	BackupCodes []string `json:"backup_codes"`
+}
+
+// DisableTOTPRequest removes the authenticator. Code may be a TOTP or a backup code.
+type DisableTOTPRequest struct {
+	Pin  string `json:"pin" binding:"required"`
+	Code string `json:"code" binding:"required"`
+}
+
+// TOTPPolicyRequest changes when transfers need a TOTP code. Omitted fields are unchanged;
+// an empty threshold restores the configured default.
+type TOTPPolicyRequest struct {
+	Code           string  `json:"code" binding:"required"` // TOTP or backup code
+	EthThreshold   *string `json:"eth_threshold"`
+	TokenThreshold *string `json:"token_threshold"`
+	NewRecipients  *bool   `json:"new_recipients"`
+}
+
+// TOTPStatusResponse describes the user's TOTP factor and effective transfer policy
+type TOTPStatusResponse struct {
+	Enabled              bool   `json:"enabled"`
+	EthThreshold         string `json:"eth_threshold,omitempty"`
+	TokenThreshold       string `json:"token_threshold,omitempty"`
+	NewRecipients        bool   `json:"new_recipients"`
+	BackupCodesRemaining int64  `json:"backup_codes_remaining"`
+}
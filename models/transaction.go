@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Transaction statuses
+const (
+	TxStatusPending   = "pending"   // Stored before broadcast, waiting for a receipt
+	TxStatusConfirmed = "confirmed" // Mined and successful
+	TxStatusFailed    = "failed"    // Broadcast rejected or reverted on chain
+	TxStatusDropped   = "dropped"   // Nonce used without this transaction being mined
+	TxStatusReplaced  = "replaced"  // Nonce used by a speed-up or cancel replacement
+)
+
+// Transaction is a transfer sent from one of the user's wallets
+type Transaction struct {
+	Id                   string    `gorm:"size:36;primaryKey" json:"id"`
+	UserId               string    `gorm:"size:36;not null;index" json:"user_id"`
+	ChainId              uint64    `gorm:"not null" json:"chain_id"`
+	Hash                 string    `gorm:"type:varchar(66);not null;uniqueIndex" json:"hash"`
+	FromAddress          string    `gorm:"type:varchar(42);not null;index" json:"from_address"`
+	ToAddress            string    `gorm:"type:varchar(42);not null" json:"to_address"` // Recipient of the ETH or tokens
+	Value                string    `gorm:"type:varchar(78);not null" json:"value"`      // Amount in the token's smallest unit
+	TokenSymbol          string    `gorm:"type:varchar(32);not null" json:"token_symbol"`
+	TokenAddress         string    `gorm:"type:varchar(42)" json:"token_address,omitempty"` // Empty for ETH transfers
+	Nonce                uint64    `gorm:"not null" json:"nonce"`
+	GasLimit             uint64    `gorm:"not null" json:"gas_limit"`
+	GasPrice             string    `gorm:"type:varchar(78)" json:"gas_price,omitempty"` // Legacy transactions only
+	MaxFeePerGas         string    `gorm:"type:varchar(78)" json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string    `gorm:"type:varchar(78)" json:"max_priority_fee_per_gas,omitempty"`
+	Type                 uint8     `gorm:"not null" json:"type"`
+	Data                 string    `gorm:"type:text" json:"data,omitempty"` // Hex-encoded calldata
+	Status               string    `gorm:"type:varchar(16);not null;index" json:"status"`
+	Error                string    `gorm:"type:text" json:"error,omitempty"`
+	BlockNumber          *uint64   `json:"block_number,omitempty"`
+	ReplacesHash         string    `gorm:"type:varchar(66)" json:"replaces_hash,omitempty"`    // Original transaction of a speed-up or cancel
+	ReplacedByHash       string    `gorm:"type:varchar(66)" json:"replaced_by_hash,omitempty"` // Latest replacement sent for this transaction
+	CreatedAt            time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TransactionFilter selects a page of a user's transaction history
+type TransactionFilter struct {
+	UserId   string `form:"-"`
+	Status   string `form:"status" binding:"omitempty,oneof=pending confirmed failed dropped replaced"`
+	Token    string `form:"token"`   // Token symbol, e.g. ETH or USDC
+	Address  string `form:"address"` // Matches either the sender or the recipient
+	Chain    string `form:"chain"`   // Chain name or ID; all chains when empty
+	ChainId  uint64 `form:"-"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+// TransactionListResponse is a page of transaction history
+type TransactionListResponse struct {
+	Transactions []Transaction `json:"transactions"`
+	Page         int           `json:"page"`
+	PageSize     int           `json:"page_size"`
+	Total        int64         `json:"total"`
+}
+
+// ReplaceTransactionRequest re-signs a pending transaction's nonce to speed it up or cancel it
+type ReplaceTransactionRequest struct {
+	Pin     string `json:"pin" binding:"required"`                              // User's PIN for decrypting mnemonic
+	FeeTier string `json:"fee_tier" binding:"omitempty,oneof=slow normal fast"` // Minimum tier for the new fees, defaults to fast
+}
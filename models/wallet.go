@@ -1,16 +1,28 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
+// Wallet types
+const (
+	WalletTypeMnemonic   = "mnemonic"    // BIP-39 mnemonic, supports multiple derived accounts
+	WalletTypePrivateKey = "private_key" // Single imported private key
+)
+
 type Wallet struct {
-	Id        string    `gorm:"type:char(36);primaryKey" json:"id"`
-	UserId    string    `gorm:"type:char(36);not null" json:"user_id"` // Must be the same type and unique
+	Id        string    `gorm:"size:36;primaryKey" json:"id"`
+	UserId    string    `gorm:"size:36;not null" json:"user_id"` // Must be the same type and unique
+	Type      string    `gorm:"type:varchar(16);not null;default:mnemonic" json:"type"`
 	Address   string    `gorm:"type:text;not null" json:"address"`
-	Mnemonic  string    `gorm:"type:text;not null" json:"mnemonic"`
+	Mnemonic  string    `gorm:"type:text;not null" json:"mnemonic"` // Encrypted mnemonic, or private key for private_key wallets
+	DataKey   string    `gorm:"type:text" json:"-"`                 // Per-wallet data key wrapped by the master key; empty for wallets not yet wrapped
+	KeyId     string    `gorm:"type:varchar(64);index" json:"-"`    // ID of the master key that wrapped DataKey
 	QRCode    string    `gorm:"type:text" json:"qr_code"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	// Has Many relationship: addresses derived from the mnemonic
+	Accounts []Account `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"accounts"`
 }
 
 // CreateWalletResponse defines the structure of the response when a new wallet is created.
@@ -24,18 +36,69 @@ type CreateWalletResponse struct {
 // SendETHRequest defines the structure of the request to send ETH from one address to another.
 // It contains details such as the sender's address, private key, recipient's address, and the amount to be sent.
 type SendETHRequest struct {
-	ToAddress   string `json:"to_address"`    // The recipient's Ethereum address
-	AmountInETH string `json:"amount_in_eth"` // The amount of ETH to send, represented as a string
-	Pin         string `json:"pin"`           // User's PIN for decrypting mnemonic
+	ToAddress   string `json:"to_address"`                                          // The recipient's Ethereum address
+	AmountInETH string `json:"amount_in_eth"`                                       // The amount of ETH to send, represented as a string
+	Pin         string `json:"pin"`                                                 // User's PIN for decrypting mnemonic
+	FeeTier     string `json:"fee_tier" binding:"omitempty,oneof=slow normal fast"` // slow, normal (default) or fast
+	FromAccount string `json:"from_account"`                                        // Account name or address, defaults to the first account
+	TotpCode    string `json:"totp_code"`                                           // TOTP or backup code, when the user's policy requires one
+	Chain       string `json:"chain"`                                               // Chain name or ID, defaults to DEFAULT_CHAIN
 }
 
 type SendERC20Request struct {
-	ToAddress   string `json:"to_address"`    // The recipient's Ethereum address
-	AmountInUSD string `json:"amount_in_usd"` // The amount of ETH to send, represented as a string
-	Pin         string `json:"pin"`           // User's PIN for decrypting mnemonic
+	ToAddress   string `json:"to_address"`                                          // The recipient's Ethereum address
+	Token       string `json:"token"`                                               // Token symbol or contract address, defaults to USDC
+	Amount      string `json:"amount"`                                              // The amount of tokens to send, in whole token units
+	AmountInUSD string `json:"amount_in_usd"`                                       // Deprecated: use Amount
+	Pin         string `json:"pin"`                                                 // User's PIN for decrypting mnemonic
+	FeeTier     string `json:"fee_tier" binding:"omitempty,oneof=slow normal fast"` // slow, normal (default) or fast
+	FromAccount string `json:"from_account"`                                        // Account name or address, defaults to the first account
+	TotpCode    string `json:"totp_code"`                                           // TOTP or backup code, when the user's policy requires one
+	Chain       string `json:"chain"`                                               // Chain name or ID, defaults to DEFAULT_CHAIN
 }
 
+// RecoverWalletRequest re-attaches a wallet to the authenticated user from its mnemonic.
+// Accounts are derived from the standard path m/44'/60'/0'/0/{index}.
 type RecoverWalletRequest struct {
-	Mnemonic       string `json:"mnemonic" binding:"required"`
-	DerivationPath string `json:"derivation_path" binding:"required"`
+	Mnemonic        string `json:"mnemonic" binding:"required"`
+	Pin             string `json:"pin" binding:"required"` // User's PIN, used to encrypt the recovered mnemonic
+	ReplaceExisting bool   `json:"replace_existing"`       // Required when the mnemonic is not the current wallet's
+}
+
+// ExportWalletRequest exports one account's private key as a Keystore V3 file
+type ExportWalletRequest struct {
+	Pin         string `json:"pin" binding:"required"`            // User's PIN for decrypting mnemonic
+	Password    string `json:"password" binding:"required,min=8"` // Password the keystore file is encrypted with
+	Confirm     bool   `json:"confirm"`                           // Must be true: the caller acknowledges the key leaves the service
+	FromAccount string `json:"from_account"`                      // Account name or address, defaults to the first account
+	TotpCode    string `json:"totp_code"`                         // TOTP or backup code, required once the user has an authenticator
+}
+
+// ExportWalletResponse carries an encrypted Keystore V3 file
+type ExportWalletResponse struct {
+	Address  string          `json:"address"`
+	Keystore json.RawMessage `json:"keystore"`
+}
+
+// WalletImport carries an existing wallet to import. At most one of Mnemonic,
+// PrivateKey and Keystore may be set.
+type WalletImport struct {
+	Mnemonic         string          `json:"mnemonic"`          // BIP-39 mnemonic of 12, 15, 18, 21 or 24 words
+	PrivateKey       string          `json:"private_key"`       // Hex-encoded private key
+	Keystore         json.RawMessage `json:"keystore"`          // Keystore V3 JSON, as an object or a string
+	KeystorePassword string          `json:"keystore_password"` // Password of the keystore
+}
+
+// ImportWalletRequest replaces the authenticated user's wallet with an imported one
+type ImportWalletRequest struct {
+	WalletImport
+	Pin             string `json:"pin" binding:"required"` // User's PIN, used to encrypt the imported secret
+	ReplaceExisting bool   `json:"replace_existing"`       // Must be true: the current wallet is detached
+}
+
+// ImportWalletResponse describes the wallet attached by an import
+type ImportWalletResponse struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Address string `json:"address"`
 }
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"gorm.io/gorm"
+)
+
+type AccountRepository struct {
+	db *gorm.DB
+}
+
+func NewAccountRepository(conn *gorm.DB) *AccountRepository {
+	return &AccountRepository{
+		db: conn,
+	}
+}
+
+// CreateAccount stores a newly derived account
+func (r *AccountRepository) CreateAccount(account *models.Account) error {
+	if err := r.db.Create(account).Error; err != nil {
+		utils.LogError(err, "Failed to create account", map[string]interface{}{
+			"wallet_id": account.WalletId,
+			"index":     account.Index,
+		})
+		return fmt.Errorf("failed to create account: %w", err)
+	}
+
+	utils.LogInfo("Account created", map[string]interface{}{
+		"wallet_id": account.WalletId,
+		"index":     account.Index,
+		"address":   account.Address,
+	})
+
+	return nil
+}
+
+// ListAccounts returns a wallet's accounts ordered by address index
+func (r *AccountRepository) ListAccounts(walletID string) ([]models.Account, error) {
+	var accounts []models.Account
+	if err := r.db.Where("wallet_id = ?", walletID).Order("address_index").Find(&accounts).Error; err != nil {
+		utils.LogError(err, "Failed to list accounts", map[string]interface{}{
+			"wallet_id": walletID,
+		})
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+
+	return accounts, nil
+}
+
+// NextAccountIndex returns the address index after the highest one used by the wallet
+func (r *AccountRepository) NextAccountIndex(walletID string) (uint32, error) {
+	var highest *uint32
+	err := r.db.Model(&models.Account{}).Where("wallet_id = ?", walletID).
+		Select("MAX(address_index)").Scan(&highest).Error
+	if err != nil {
+		utils.LogError(err, "Failed to get highest account index", map[string]interface{}{
+			"wallet_id": walletID,
+		})
+		return 0, fmt.Errorf("failed to get highest account index: %w", err)
+	}
+
+	if highest == nil {
+		return 0, nil
+	}
+	return *highest + 1, nil
+}
+
+// FindUserIDsByAddress returns the IDs of the users with an account or wallet at the address
+func (r *AccountRepository) FindUserIDsByAddress(address string) ([]string, error) {
+	var accountUsers, walletUsers []string
+	err := r.db.Model(&models.Account{}).Where("LOWER(address) = ?", strings.ToLower(address)).
+		Distinct().Pluck("user_id", &accountUsers).Error
+	if err == nil {
+		err = r.db.Model(&models.Wallet{}).Where("LOWER(address) = ?", strings.ToLower(address)).
+			Distinct().Pluck("user_id", &walletUsers).Error
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to find users by address", map[string]interface{}{
+			"address": address,
+		})
+		return nil, fmt.Errorf("failed to find users by address: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var userIDs []string
+	for _, id := range append(accountUsers, walletUsers...) {
+		if !seen[id] {
+			seen[id] = true
+			userIDs = append(userIDs, id)
+		}
+	}
+	return userIDs, nil
+}
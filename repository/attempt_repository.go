@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"fmt"
+	"test-wallet/db"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewAttemptRepository(conn *gorm.DB) *AttemptRepository {
+	return &AttemptRepository{
+		db: conn,
+	}
+}
+
+// FindAttempt returns the attempt state of key, or a zero state if none is stored
+func (r *AttemptRepository) FindAttempt(key string) (*models.PinAttempt, error) {
+	var attempt models.PinAttempt
+	err := r.db.Where("attempt_key = ?", key).Limit(1).Find(&attempt).Error
+	if err != nil {
+		utils.LogError(err, "Failed to get PIN attempts", map[string]interface{}{
+			"key": key,
+		})
+		return nil, fmt.Errorf("failed to get PIN attempts: %w", err)
+	}
+	attempt.Key = key
+	return &attempt, nil
+}
+
+// WithAttemptLock locks the attempt row of key for the duration of fn, creating it if needed.
+// fn may modify the state, which is saved on success; a state without failures or a block is
+// deleted instead, so only keys with failures keep a row.
+func (r *AttemptRepository) WithAttemptLock(key string, fn func(attempt *models.PinAttempt) error) error {
+	tx, err := db.BeginTransaction(r.db)
+	if err != nil {
+		utils.LogError(err, "Failed to begin transaction", nil)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	rollback := func() {
+		if err := db.EndTransaction(tx, false); err != nil {
+			utils.LogError(err, "Failed to rollback transaction", nil)
+		}
+	}
+
+	attempt := models.PinAttempt{Key: key}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&attempt).Error; err != nil {
+		rollback()
+		utils.LogError(err, "Failed to create PIN attempts", map[string]interface{}{
+			"key": key,
+		})
+		return fmt.Errorf("failed to create PIN attempts: %w", err)
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("attempt_key = ?", key).First(&attempt).Error; err != nil {
+		rollback()
+		utils.LogError(err, "Failed to lock PIN attempts", map[string]interface{}{
+			"key": key,
+		})
+		return fmt.Errorf("failed to lock PIN attempts: %w", err)
+	}
+
+	if err := fn(&attempt); err != nil {
+		rollback()
+		return err
+	}
+
+	if attempt.Failures == 0 && attempt.BlockedUntil == nil {
+		err = tx.Delete(&attempt).Error
+	} else {
+		err = tx.Save(&attempt).Error
+	}
+	if err != nil {
+		rollback()
+		utils.LogError(err, "Failed to save PIN attempts", map[string]interface{}{
+			"key": key,
+		})
+		return fmt.Errorf("failed to save PIN attempts: %w", err)
+	}
+
+	if err := db.EndTransaction(tx, true); err != nil {
+		utils.LogError(err, "Failed to commit transaction", nil)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAttempt clears the failures, backoff and lockout of key. It reports whether a row existed.
+func (r *AttemptRepository) DeleteAttempt(key string) (bool, error) {
+	result := r.db.Where("attempt_key = ?", key).Delete(&models.PinAttempt{})
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to clear PIN attempts", map[string]interface{}{
+			"key": key,
+		})
+		return false, fmt.Errorf("failed to clear PIN attempts: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"fmt"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"gorm.io/gorm"
+)
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(conn *gorm.DB) *AuditRepository {
+	return &AuditRepository{
+		db: conn,
+	}
+}
+
+// CreateAuditEvent appends an event to the audit log
+func (r *AuditRepository) CreateAuditEvent(event *models.AuditEvent) error {
+	if err := r.db.Create(event).Error; err != nil {
+		utils.LogError(err, "Failed to create audit event", map[string]interface{}{
+			"type":    event.Type,
+			"user_id": event.UserId,
+		})
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+	return nil
+}
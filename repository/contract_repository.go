@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"gorm.io/gorm"
+)
+
+type ContractRepository struct {
+	db *gorm.DB
+}
+
+func NewContractRepository(conn *gorm.DB) *ContractRepository {
+	return &ContractRepository{
+		db: conn,
+	}
+}
+
+// SaveAbi creates the ABI or replaces the address and definition of the user's ABI with the same name
+func (r *ContractRepository) SaveAbi(contractAbi *models.ContractAbi) error {
+	var existing models.ContractAbi
+	err := r.db.Where("user_id = ? AND chain_id = ? AND LOWER(name) = ?", contractAbi.UserId, contractAbi.ChainId, strings.ToLower(contractAbi.Name)).
+		First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogError(err, "Failed to look up contract ABI", map[string]interface{}{
+			"user_id": contractAbi.UserId,
+			"name":    contractAbi.Name,
+		})
+		return fmt.Errorf("failed to look up contract ABI: %w", err)
+	}
+
+	if err == nil {
+		contractAbi.Id = existing.Id
+		contractAbi.CreatedAt = existing.CreatedAt
+		err = r.db.Model(&existing).Updates(map[string]interface{}{
+			"address": contractAbi.Address,
+			"abi":     contractAbi.Abi,
+		}).Error
+	} else {
+		err = r.db.Create(contractAbi).Error
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to save contract ABI", map[string]interface{}{
+			"user_id": contractAbi.UserId,
+			"name":    contractAbi.Name,
+		})
+		return fmt.Errorf("failed to save contract ABI: %w", err)
+	}
+
+	return nil
+}
+
+// FindAbi returns one of the user's ABIs on a chain, or ErrNotFound
+func (r *ContractRepository) FindAbi(userID string, chainID uint64, id string) (*models.ContractAbi, error) {
+	var contractAbi models.ContractAbi
+	err := r.db.Where("id = ? AND user_id = ? AND chain_id = ?", id, userID, chainID).First(&contractAbi).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to get contract ABI", map[string]interface{}{
+			"user_id": userID,
+			"id":      id,
+		})
+		return nil, fmt.Errorf("failed to get contract ABI: %w", err)
+	}
+	return &contractAbi, nil
+}
+
+// ListAbis returns the user's ABIs on a chain ordered by name
+func (r *ContractRepository) ListAbis(userID string, chainID uint64) ([]models.ContractAbi, error) {
+	var abis []models.ContractAbi
+	if err := r.db.Where("user_id = ? AND chain_id = ?", userID, chainID).Order("name").Find(&abis).Error; err != nil {
+		utils.LogError(err, "Failed to list contract ABIs", map[string]interface{}{
+			"user_id": userID,
+		})
+		return nil, fmt.Errorf("failed to list contract ABIs: %w", err)
+	}
+	return abis, nil
+}
+
+// DeleteAbi removes one of the user's ABIs. It reports false if no ABI matched.
+func (r *ContractRepository) DeleteAbi(userID string, chainID uint64, id string) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ? AND chain_id = ?", id, userID, chainID).Delete(&models.ContractAbi{})
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to delete contract ABI", map[string]interface{}{
+			"user_id": userID,
+			"id":      id,
+		})
+		return false, fmt.Errorf("failed to delete contract ABI: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"fmt"
+	"test-wallet/db"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NonceRepository struct {
+	db *gorm.DB
+}
+
+func NewNonceRepository(conn *gorm.DB) *NonceRepository {
+	return &NonceRepository{
+		db: conn,
+	}
+}
+
+// WithNonceLock locks the nonce row of an address for the duration of fn, creating it if needed.
+// fn runs inside the database transaction and may modify state, which is saved on success.
+func (r *NonceRepository) WithNonceLock(chainID uint64, address string, fn func(tx *gorm.DB, state *models.NonceState) error) error {
+	tx, err := db.BeginTransaction(r.db)
+	if err != nil {
+		utils.LogError(err, "Failed to begin transaction", nil)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	rollback := func() {
+		if err := db.EndTransaction(tx, false); err != nil {
+			utils.LogError(err, "Failed to rollback transaction", nil)
+		}
+	}
+
+	state := models.NonceState{ChainId: chainID, Address: address}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&state).Error; err != nil {
+		rollback()
+		utils.LogError(err, "Failed to create nonce state", map[string]interface{}{
+			"chain_id": chainID,
+			"address":  address,
+		})
+		return fmt.Errorf("failed to create nonce state: %w", err)
+	}
+
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("chain_id = ? AND address = ?", chainID, address).
+		First(&state).Error
+	if err != nil {
+		rollback()
+		utils.LogError(err, "Failed to lock nonce state", map[string]interface{}{
+			"chain_id": chainID,
+			"address":  address,
+		})
+		return fmt.Errorf("failed to lock nonce state: %w", err)
+	}
+
+	if err := fn(tx, &state); err != nil {
+		rollback()
+		return err
+	}
+
+	if err := tx.Save(&state).Error; err != nil {
+		rollback()
+		utils.LogError(err, "Failed to save nonce state", map[string]interface{}{
+			"chain_id": chainID,
+			"address":  address,
+		})
+		return fmt.Errorf("failed to save nonce state: %w", err)
+	}
+
+	if err := db.EndTransaction(tx, true); err != nil {
+		utils.LogError(err, "Failed to commit transaction", nil)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// PendingNonces returns the nonces of pending transactions from address at or above minNonce
+func (r *NonceRepository) PendingNonces(tx *gorm.DB, chainID uint64, address string, minNonce uint64) (map[uint64]bool, error) {
+	var nonces []uint64
+	err := tx.Model(&models.Transaction{}).
+		Where("chain_id = ? AND from_address = ? AND status = ? AND nonce >= ?",
+			chainID, address, models.TxStatusPending, minNonce).
+		Pluck("nonce", &nonces).Error
+	if err != nil {
+		utils.LogError(err, "Failed to list pending nonces", map[string]interface{}{
+			"chain_id": chainID,
+			"address":  address,
+		})
+		return nil, fmt.Errorf("failed to list pending nonces: %w", err)
+	}
+
+	used := make(map[uint64]bool, len(nonces))
+	for _, nonce := range nonces {
+		used[nonce] = true
+	}
+	return used, nil
+}
+
+// ListNonceStates returns every tracked address
+func (r *NonceRepository) ListNonceStates() ([]models.NonceState, error) {
+	var states []models.NonceState
+	if err := r.db.Find(&states).Error; err != nil {
+		utils.LogError(err, "Failed to list nonce states", nil)
+		return nil, fmt.Errorf("failed to list nonce states: %w", err)
+	}
+	return states, nil
+}
+
+// SetNextNonce overwrites the next nonce of an address, used when resyncing from chain
+func (r *NonceRepository) SetNextNonce(chainID uint64, address string, nonce uint64) error {
+	state := models.NonceState{ChainId: chainID, Address: address, NextNonce: nonce}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}, {Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"next_nonce", "updated_at"}),
+	}).Create(&state).Error
+	if err != nil {
+		utils.LogError(err, "Failed to set next nonce", map[string]interface{}{
+			"chain_id": chainID,
+			"address":  address,
+		})
+		return fmt.Errorf("failed to set next nonce: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"test-wallet/db"
+	"test-wallet/models"
+	"test-wallet/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OtpRepository struct {
+	db *gorm.DB
+}
+
+func NewOtpRepository(conn *gorm.DB) *OtpRepository {
+	return &OtpRepository{
+		db: conn,
+	}
+}
+
+// CreateChallenge stores a new challenge and supersedes the open challenges for the same
+// phone number and purpose
+func (r *OtpRepository) CreateChallenge(challenge *models.OtpChallenge) error {
+	tx, err := db.BeginTransaction(r.db)
+	if err != nil {
+		utils.LogError(err, "Failed to begin transaction", nil)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	rollback := func() {
+		if err := db.EndTransaction(tx, false); err != nil {
+			utils.LogError(err, "Failed to rollback transaction", nil)
+		}
+	}
+
+	if err := tx.Model(&models.OtpChallenge{}).
+		Where("phone_number = ? AND purpose = ? AND consumed_at IS NULL", challenge.PhoneNumber, challenge.Purpose).
+		Update("consumed_at", time.Now()).Error; err != nil {
+		rollback()
+		utils.LogError(err, "Failed to supersede OTP challenges", map[string]interface{}{
+			"phone_number": challenge.PhoneNumber,
+		})
+		return fmt.Errorf("failed to supersede OTP challenges: %w", err)
+	}
+
+	if err := tx.Create(challenge).Error; err != nil {
+		rollback()
+		utils.LogError(err, "Failed to create OTP challenge", map[string]interface{}{
+			"phone_number": challenge.PhoneNumber,
+		})
+		return fmt.Errorf("failed to create OTP challenge: %w", err)
+	}
+
+	if err := db.EndTransaction(tx, true); err != nil {
+		utils.LogError(err, "Failed to commit transaction", nil)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// FindLatestChallenge returns the most recent challenge for the phone number and purpose,
+// consumed or not, or ErrNotFound
+func (r *OtpRepository) FindLatestChallenge(phoneNumber, purpose string) (*models.OtpChallenge, error) {
+	var challenge models.OtpChallenge
+	err := r.db.Where("phone_number = ? AND purpose = ?", phoneNumber, purpose).
+		Order("created_at DESC").First(&challenge).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to get OTP challenge", map[string]interface{}{
+			"phone_number": phoneNumber,
+		})
+		return nil, fmt.Errorf("failed to get OTP challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+// RecordAttempt counts a verification attempt against an open challenge. It reports false
+// when the challenge is consumed or already has maxAttempts attempts.
+func (r *OtpRepository) RecordAttempt(challengeID string, maxAttempts int) (bool, error) {
+	result := r.db.Model(&models.OtpChallenge{}).
+		Where("id = ? AND consumed_at IS NULL AND attempts < ?", challengeID, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to record OTP attempt", map[string]interface{}{
+			"challenge_id": challengeID,
+		})
+		return false, fmt.Errorf("failed to record OTP attempt: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ConsumeChallenge marks an open challenge as used. It reports false if it was already consumed.
+func (r *OtpRepository) ConsumeChallenge(challengeID string) (bool, error) {
+	result := r.db.Model(&models.OtpChallenge{}).
+		Where("id = ? AND consumed_at IS NULL", challengeID).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to consume OTP challenge", map[string]interface{}{
+			"challenge_id": challengeID,
+		})
+		return false, fmt.Errorf("failed to consume OTP challenge: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
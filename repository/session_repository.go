@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"test-wallet/models"
+	"test-wallet/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(conn *gorm.DB) *SessionRepository {
+	return &SessionRepository{
+		db: conn,
+	}
+}
+
+// CreateSession stores a new session
+func (r *SessionRepository) CreateSession(session *models.Session) error {
+	if err := r.db.Create(session).Error; err != nil {
+		utils.LogError(err, "Failed to create session", map[string]interface{}{
+			"user_id": session.UserId,
+		})
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// FindSessionByRefreshHash returns the session whose current or previous refresh token has
+// the given hash, or ErrNotFound
+func (r *SessionRepository) FindSessionByRefreshHash(hash string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Where("refresh_token_hash = ? OR previous_refresh_hash = ?", hash, hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to get session", nil)
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &session, nil
+}
+
+// RotateRefreshToken replaces the session's refresh token hash if it still equals previous,
+// so two concurrent refreshes with the same token cannot both succeed
+func (r *SessionRepository) RotateRefreshToken(sessionID, previous, next string, client models.ClientInfo) (bool, error) {
+	result := r.db.Model(&models.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", sessionID, previous).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":    next,
+			"previous_refresh_hash": previous,
+			"user_agent":            client.UserAgent,
+			"ip":                    client.IP,
+			"last_used_at":          time.Now(),
+		})
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to rotate refresh token", map[string]interface{}{
+			"session_id": sessionID,
+		})
+		return false, fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// IsSessionActive reports whether the session exists, is not revoked and has not expired
+func (r *SessionRepository) IsSessionActive(sessionID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ListActiveSessions returns the user's sessions that are neither revoked nor expired
+func (r *SessionRepository) ListActiveSessions(userID string) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error
+	if err != nil {
+		utils.LogError(err, "Failed to list sessions", map[string]interface{}{
+			"user_id": userID,
+		})
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes one of the user's sessions. It returns ErrNotFound if the user has
+// no such active session.
+func (r *SessionRepository) RevokeSession(userID, sessionID string) error {
+	result := r.db.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to revoke session", map[string]interface{}{
+			"session_id": sessionID,
+		})
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of the user
+func (r *SessionRepository) RevokeUserSessions(userID string) error {
+	err := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		utils.LogError(err, "Failed to revoke sessions", map[string]interface{}{
+			"user_id": userID,
+		})
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"fmt"
+	"test-wallet/models"
+	"test-wallet/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SiweRepository struct {
+	db *gorm.DB
+}
+
+func NewSiweRepository(conn *gorm.DB) *SiweRepository {
+	return &SiweRepository{
+		db: conn,
+	}
+}
+
+// CreateNonce stores a new nonce and prunes expired ones
+func (r *SiweRepository) CreateNonce(nonce *models.SiweNonce) error {
+	if err := r.db.Where("expires_at < ?", time.Now()).Delete(&models.SiweNonce{}).Error; err != nil {
+		utils.LogError(err, "Failed to prune SIWE nonces", nil)
+	}
+
+	if err := r.db.Create(nonce).Error; err != nil {
+		utils.LogError(err, "Failed to create SIWE nonce", nil)
+		return fmt.Errorf("failed to create SIWE nonce: %w", err)
+	}
+	return nil
+}
+
+// ConsumeNonce marks an unexpired, unused nonce as used. It reports false if the nonce is
+// unknown, expired or already used.
+func (r *SiweRepository) ConsumeNonce(nonce string) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&models.SiweNonce{}).
+		Where("nonce = ? AND used_at IS NULL AND expires_at > ?", nonce, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to consume SIWE nonce", nil)
+		return false, fmt.Errorf("failed to consume SIWE nonce: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
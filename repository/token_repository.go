@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"gorm.io/gorm"
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(conn *gorm.DB) *TokenRepository {
+	return &TokenRepository{
+		db: conn,
+	}
+}
+
+// UpsertToken creates the token or refreshes the symbol and decimals of an existing entry
+func (r *TokenRepository) UpsertToken(token *models.Token) error {
+	var existing models.Token
+	err := r.db.Where("chain_id = ? AND address = ?", token.ChainId, token.Address).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogError(err, "Failed to look up token", map[string]interface{}{
+			"chain_id": token.ChainId,
+			"address":  token.Address,
+		})
+		return fmt.Errorf("failed to look up token: %w", err)
+	}
+
+	if err == nil {
+		token.Id = existing.Id
+		err = r.db.Model(&existing).Updates(map[string]interface{}{
+			"symbol":   token.Symbol,
+			"decimals": token.Decimals,
+		}).Error
+	} else {
+		err = r.db.Create(token).Error
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to save token", map[string]interface{}{
+			"chain_id": token.ChainId,
+			"address":  token.Address,
+		})
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+
+	utils.LogDebug("Token saved", map[string]interface{}{
+		"chain_id": token.ChainId,
+		"symbol":   token.Symbol,
+		"address":  token.Address,
+	})
+
+	return nil
+}
+
+// FindTokenBySymbol finds a token on a chain by its symbol (case-insensitive)
+func (r *TokenRepository) FindTokenBySymbol(chainID uint64, symbol string) (*models.Token, error) {
+	var token models.Token
+	err := r.db.Where("chain_id = ? AND symbol = ?", chainID, strings.ToUpper(symbol)).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("token not found")
+		}
+		utils.LogError(err, "Failed to find token by symbol", map[string]interface{}{
+			"chain_id": chainID,
+			"symbol":   symbol,
+		})
+		return nil, fmt.Errorf("failed to find token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// FindTokenByAddress finds a token on a chain by its contract address
+func (r *TokenRepository) FindTokenByAddress(chainID uint64, address string) (*models.Token, error) {
+	var token models.Token
+	err := r.db.Where("chain_id = ? AND address = ?", chainID, address).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("token not found")
+		}
+		utils.LogError(err, "Failed to find token by address", map[string]interface{}{
+			"chain_id": chainID,
+			"address":  address,
+		})
+		return nil, fmt.Errorf("failed to find token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// ListTokens returns all supported tokens on a chain ordered by symbol
+func (r *TokenRepository) ListTokens(chainID uint64) ([]models.Token, error) {
+	var tokens []models.Token
+	if err := r.db.Where("chain_id = ?", chainID).Order("symbol").Find(&tokens).Error; err != nil {
+		utils.LogError(err, "Failed to list tokens", map[string]interface{}{
+			"chain_id": chainID,
+		})
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	return tokens, nil
+}
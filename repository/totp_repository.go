@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"test-wallet/db"
+	"test-wallet/models"
+	"test-wallet/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TotpRepository struct {
+	db *gorm.DB
+}
+
+func NewTotpRepository(conn *gorm.DB) *TotpRepository {
+	return &TotpRepository{
+		db: conn,
+	}
+}
+
+// FindFactor returns the user's TOTP factor, confirmed or pending, or ErrNotFound
+func (r *TotpRepository) FindFactor(userID string) (*models.TotpFactor, error) {
+	var factor models.TotpFactor
+	err := r.db.Where("user_id = ?", userID).First(&factor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to get TOTP factor", map[string]interface{}{
+			"user_id": userID,
+		})
+		return nil, fmt.Errorf("failed to get TOTP factor: %w", err)
+	}
+	return &factor, nil
+}
+
+// SaveFactor creates or replaces the user's TOTP factor
+func (r *TotpRepository) SaveFactor(factor *models.TotpFactor) error {
+	if err := r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(factor).Error; err != nil {
+		utils.LogError(err, "Failed to save TOTP factor", map[string]interface{}{
+			"user_id": factor.UserId,
+		})
+		return fmt.Errorf("failed to save TOTP factor: %w", err)
+	}
+	return nil
+}
+
+// ConfirmFactor activates a pending factor at the given time step and replaces its backup
+// codes in a single transaction. It reports false if the factor was not pending.
+func (r *TotpRepository) ConfirmFactor(userID string, step int64, codes []models.TotpBackupCode) (bool, error) {
+	tx, err := db.BeginTransaction(r.db)
+	if err != nil {
+		utils.LogError(err, "Failed to begin transaction", nil)
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	rollback := func() {
+		if err := db.EndTransaction(tx, false); err != nil {
+			utils.LogError(err, "Failed to rollback transaction", nil)
+		}
+	}
+
+	result := tx.Model(&models.TotpFactor{}).
+		Where("user_id = ? AND confirmed_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"confirmed_at":   time.Now(),
+			"last_used_step": step,
+		})
+	if result.Error == nil && result.RowsAffected == 0 {
+		rollback()
+		return false, nil
+	}
+
+	err = result.Error
+	if err == nil {
+		err = tx.Where("user_id = ?", userID).Delete(&models.TotpBackupCode{}).Error
+	}
+	if err == nil {
+		err = tx.Create(&codes).Error
+	}
+	if err != nil {
+		rollback()
+		utils.LogError(err, "Failed to confirm TOTP factor", map[string]interface{}{
+			"user_id": userID,
+		})
+		return false, fmt.Errorf("failed to confirm TOTP factor: %w", err)
+	}
+
+	if err := db.EndTransaction(tx, true); err != nil {
+		utils.LogError(err, "Failed to commit transaction", nil)
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// UseStep records a TOTP code accepted at the given time step. It reports false if a code
+// from this or a later step was already used, so each code works once.
+func (r *TotpRepository) UseStep(userID string, step int64) (bool, error) {
+	result := r.db.Model(&models.TotpFactor{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to record TOTP code use", map[string]interface{}{
+			"user_id": userID,
+		})
+		return false, fmt.Errorf("failed to record TOTP code use: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UseBackupCode consumes an unused backup code. It reports false if no unused code matched.
+func (r *TotpRepository) UseBackupCode(userID, codeHash string) (bool, error) {
+	result := r.db.Model(&models.TotpBackupCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to use backup code", map[string]interface{}{
+			"user_id": userID,
+		})
+		return false, fmt.Errorf("failed to use backup code: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountUnusedBackupCodes returns how many backup codes the user has left
+func (r *TotpRepository) CountUnusedBackupCodes(userID string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TotpBackupCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	if err != nil {
+		utils.LogError(err, "Failed to count backup codes", map[string]interface{}{
+			"user_id": userID,
+		})
+		return 0, fmt.Errorf("failed to count backup codes: %w", err)
+	}
+	return count, nil
+}
+
+// UpdatePolicy changes the transfer policy columns given in updates
+func (r *TotpRepository) UpdatePolicy(userID string, updates map[string]interface{}) error {
+	if err := r.db.Model(&models.TotpFactor{}).Where("user_id = ?", userID).Updates(updates).Error; err != nil {
+		utils.LogError(err, "Failed to update TOTP policy", map[string]interface{}{
+			"user_id": userID,
+		})
+		return fmt.Errorf("failed to update TOTP policy: %w", err)
+	}
+	return nil
+}
+
+// DeleteFactor removes the user's TOTP factor and backup codes
+func (r *TotpRepository) DeleteFactor(userID string) error {
+	tx, err := db.BeginTransaction(r.db)
+	if err != nil {
+		utils.LogError(err, "Failed to begin transaction", nil)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	err = tx.Where("user_id = ?", userID).Delete(&models.TotpBackupCode{}).Error
+	if err == nil {
+		err = tx.Where("user_id = ?", userID).Delete(&models.TotpFactor{}).Error
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to delete TOTP factor", map[string]interface{}{
+			"user_id": userID,
+		})
+		if err := db.EndTransaction(tx, false); err != nil {
+			utils.LogError(err, "Failed to rollback transaction", nil)
+		}
+		return fmt.Errorf("failed to delete TOTP factor: %w", err)
+	}
+
+	if err := db.EndTransaction(tx, true); err != nil {
+		utils.LogError(err, "Failed to commit transaction", nil)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListFactorsNotWrappedBy returns up to limit factors, ordered by user ID after afterID,
+// whose data key is wrapped by a master key other than keyID
+func (r *TotpRepository) ListFactorsNotWrappedBy(keyID, afterID string, limit int) ([]models.TotpFactor, error) {
+	var factors []models.TotpFactor
+	err := r.db.Where("user_id > ? AND (key_id IS NULL OR key_id <> ?)", afterID, keyID).
+		Order("user_id").Limit(limit).Find(&factors).Error
+	if err != nil {
+		utils.LogError(err, "Failed to list TOTP factors", nil)
+		return nil, fmt.Errorf("failed to list TOTP factors: %w", err)
+	}
+	return factors, nil
+}
+
+// UpdateFactorKey stores a rewrapped data key if the stored one still equals previous.
+// It reports whether a row changed.
+func (r *TotpRepository) UpdateFactorKey(factor *models.TotpFactor, previous string) (bool, error) {
+	result := r.db.Model(&models.TotpFactor{}).
+		Where("user_id = ? AND data_key = ?", factor.UserId, previous).
+		Updates(map[string]interface{}{
+			"data_key": factor.DataKey,
+			"key_id":   factor.KeyId,
+		})
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to update TOTP data key", map[string]interface{}{
+			"user_id": factor.UserId,
+		})
+		return false, fmt.Errorf("failed to update TOTP data key: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"gorm.io/gorm"
+)
+
+type TransactionRepository struct {
+	db *gorm.DB
+}
+
+func NewTransactionRepository(conn *gorm.DB) *TransactionRepository {
+	return &TransactionRepository{
+		db: conn,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries inside the given database transaction
+func (r *TransactionRepository) WithTx(tx *gorm.DB) *TransactionRepository {
+	return &TransactionRepository{db: tx}
+}
+
+// CreateTransaction stores a new transaction record
+func (r *TransactionRepository) CreateTransaction(tx *models.Transaction) error {
+	if err := r.db.Create(tx).Error; err != nil {
+		utils.LogError(err, "Failed to create transaction", map[string]interface{}{
+			"tx_hash": tx.Hash,
+		})
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	utils.LogDebug("Transaction stored", map[string]interface{}{
+		"tx_hash": tx.Hash,
+		"status":  tx.Status,
+	})
+
+	return nil
+}
+
+// UpdateTransactionStatus changes the status of a transaction, recording an error message if any
+func (r *TransactionRepository) UpdateTransactionStatus(id, status, errMsg string) error {
+	err := r.db.Model(&models.Transaction{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status": status,
+		"error":  errMsg,
+	}).Error
+	if err != nil {
+		utils.LogError(err, "Failed to update transaction status", map[string]interface{}{
+			"id":     id,
+			"status": status,
+		})
+		return fmt.Errorf("failed to update transaction status: %w", err)
+	}
+
+	return nil
+}
+
+// ListTransactions returns a page of a user's transactions, newest first, and the total match count
+func (r *TransactionRepository) ListTransactions(filter *models.TransactionFilter) ([]models.Transaction, int64, error) {
+	query := r.db.Model(&models.Transaction{}).Where("user_id = ?", filter.UserId)
+	if filter.ChainId != 0 {
+		query = query.Where("chain_id = ?", filter.ChainId)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Token != "" {
+		query = query.Where("token_symbol = ?", strings.ToUpper(filter.Token))
+	}
+	if filter.Address != "" {
+		query = query.Where("(LOWER(from_address) = ? OR LOWER(to_address) = ?)",
+			strings.ToLower(filter.Address), strings.ToLower(filter.Address))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		utils.LogError(err, "Failed to count transactions", map[string]interface{}{
+			"user_id": filter.UserId,
+		})
+		return nil, 0, fmt.Errorf("failed to count transactions: %w", err)
+	}
+
+	var transactions []models.Transaction
+	err := query.Order("created_at DESC").
+		Offset((filter.Page - 1) * filter.PageSize).
+		Limit(filter.PageSize).
+		Find(&transactions).Error
+	if err != nil {
+		utils.LogError(err, "Failed to list transactions", map[string]interface{}{
+			"user_id": filter.UserId,
+		})
+		return nil, 0, fmt.Errorf("failed to list transactions: %w", err)
+	}
+
+	return transactions, total, nil
+}
+
+// ListPendingTransactions returns up to limit pending transactions on the given chains, ordered
+// by ID and starting after afterID, so callers can page through all of them and wrap around
+func (r *TransactionRepository) ListPendingTransactions(chainIDs []uint64, afterID string, limit int) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	query := r.db.Where("status = ? AND chain_id IN ?", models.TxStatusPending, chainIDs)
+	if afterID != "" {
+		query = query.Where("id > ?", afterID)
+	}
+	err := query.Order("id").
+		Limit(limit).
+		Find(&transactions).Error
+	if err != nil {
+		utils.LogError(err, "Failed to list pending transactions", nil)
+		return nil, fmt.Errorf("failed to list pending transactions: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// UpdateTransactionReceipt records the block a transaction was mined in along with its status
+func (r *TransactionRepository) UpdateTransactionReceipt(id, status string, blockNumber uint64, errMsg string) error {
+	err := r.db.Model(&models.Transaction{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       status,
+		"block_number": blockNumber,
+		"error":        errMsg,
+	}).Error
+	if err != nil {
+		utils.LogError(err, "Failed to update transaction receipt", map[string]interface{}{
+			"id":     id,
+			"status": status,
+		})
+		return fmt.Errorf("failed to update transaction receipt: %w", err)
+	}
+
+	return nil
+}
+
+// FindUserTransactionByHash finds one of the user's transactions by its hash
+func (r *TransactionRepository) FindUserTransactionByHash(userID, hash string) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := r.db.Where("user_id = ? AND LOWER(hash) = ?", userID, strings.ToLower(hash)).First(&transaction).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		utils.LogError(err, "Failed to find transaction", map[string]interface{}{
+			"user_id": userID,
+			"tx_hash": hash,
+		})
+		return nil, fmt.Errorf("failed to find transaction: %w", err)
+	}
+
+	return &transaction, nil
+}
+
+// MarkTransactionReplaced links a transaction to the replacement sent at its nonce
+func (r *TransactionRepository) MarkTransactionReplaced(id, replacementHash string) error {
+	err := r.db.Model(&models.Transaction{}).Where("id = ?", id).
+		Update("replaced_by_hash", replacementHash).Error
+	if err != nil {
+		utils.LogError(err, "Failed to link replacement transaction", map[string]interface{}{
+			"id":          id,
+			"replacement": replacementHash,
+		})
+		return fmt.Errorf("failed to link replacement transaction: %w", err)
+	}
+
+	return nil
+}
+
+// HasSentTo reports whether the user has a confirmed transfer to the recipient address on the chain
+func (r *TransactionRepository) HasSentTo(userID string, chainID uint64, recipient string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Transaction{}).
+		Where("user_id = ? AND chain_id = ? AND LOWER(to_address) = ? AND status = ?", userID, chainID, strings.ToLower(recipient), models.TxStatusConfirmed).
+		Limit(1).Count(&count).Error
+	if err != nil {
+		utils.LogError(err, "Failed to check previous recipients", map[string]interface{}{
+			"user_id":  userID,
+			"chain_id": chainID,
+		})
+		return false, fmt.Errorf("failed to check previous recipients: %w", err)
+	}
+	return count > 0, nil
+}
@@ -6,6 +6,7 @@ import (
 	"test-wallet/db"
 	"test-wallet/models"
 	"test-wallet/utils"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,12 +15,17 @@ type UserRepository struct {
 	db *gorm.DB
 }
 
-func NewUserRepository() *UserRepository {
+func NewUserRepository(conn *gorm.DB) *UserRepository {
 	return &UserRepository{
-		db: db.GetDB(),
+		db: conn,
 	}
 }
 
+// orderAccounts sorts preloaded wallet accounts by address index
+func orderAccounts(db *gorm.DB) *gorm.DB {
+	return db.Order("address_index")
+}
+
 // PhoneNumberExists checks if a phone number is already registered
 func (r *UserRepository) PhoneNumberExists(phoneNumber string) (bool, error) {
 	var count int64
@@ -55,7 +61,7 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 	}
 
 	// Start transaction
-	tx, err := db.BeginTransaction()
+	tx, err := db.BeginTransaction(r.db)
 	if err != nil {
 		utils.LogError(err, "Failed to begin transaction", nil)
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -88,7 +94,7 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 // FindUserByPhoneNumber finds a user by their phone number
 func (r *UserRepository) FindUserByPhoneNumber(phoneNumber string) (*models.User, error) {
 	var user models.User
-	err := r.db.Preload("Wallet").Where("phone_number = ?", phoneNumber).First(&user).Error
+	err := r.db.Preload("Wallet.Accounts", orderAccounts).Where("phone_number = ?", phoneNumber).First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			utils.LogInfo("User not found", map[string]interface{}{
@@ -113,7 +119,7 @@ func (r *UserRepository) FindUserByPhoneNumber(phoneNumber string) (*models.User
 // FindUserByID finds a user by their ID
 func (r *UserRepository) FindUserByID(userID string) (*models.User, error) {
 	var user models.User
-	err := r.db.Preload("Wallet").First(&user, "id = ?", userID).Error
+	err := r.db.Preload("Wallet.Accounts", orderAccounts).First(&user, "id = ?", userID).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			utils.LogInfo("User not found", map[string]interface{}{
@@ -133,3 +139,184 @@ func (r *UserRepository) FindUserByID(userID string) (*models.User, error) {
 
 	return &user, nil
 }
+
+// UpdatePinAndMnemonic stores a new PIN hash, salt and re-encrypted wallet mnemonic
+// in a single transaction so the wallet is never left encrypted under an unknown PIN
+func (r *UserRepository) UpdatePinAndMnemonic(user *models.User) error {
+	tx, err := db.BeginTransaction(r.db)
+	if err != nil {
+		utils.LogError(err, "Failed to begin transaction", nil)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	err = tx.Model(&models.User{}).Where("id = ?", user.Id).Updates(map[string]interface{}{
+		"pin":  user.Pin,
+		"salt": user.Salt,
+	}).Error
+	if err == nil {
+		err = tx.Model(&models.Wallet{}).Where("id = ?", user.Wallet.Id).Updates(map[string]interface{}{
+			"mnemonic": user.Wallet.Mnemonic,
+			"data_key": user.Wallet.DataKey,
+			"key_id":   user.Wallet.KeyId,
+		}).Error
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to update PIN and mnemonic", map[string]interface{}{
+			"user_id": user.Id,
+		})
+		if err := db.EndTransaction(tx, false); err != nil {
+			utils.LogError(err, "Failed to rollback transaction", nil)
+		}
+		return fmt.Errorf("failed to update PIN: %w", err)
+	}
+
+	if err := db.EndTransaction(tx, true); err != nil {
+		utils.LogError(err, "Failed to commit transaction", nil)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	utils.LogInfo("PIN and mnemonic updated", map[string]interface{}{
+		"user_id": user.Id,
+	})
+
+	return nil
+}
+
+// ReplaceWallet overwrites the wallet's secret, address and QR code and replaces its
+// accounts with wallet.Accounts in a single transaction
+func (r *UserRepository) ReplaceWallet(wallet *models.Wallet) error {
+	tx, err := db.BeginTransaction(r.db)
+	if err != nil {
+		utils.LogError(err, "Failed to begin transaction", nil)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	err = tx.Model(&models.Wallet{}).Where("id = ?", wallet.Id).Updates(map[string]interface{}{
+		"type":     wallet.Type,
+		"address":  wallet.Address,
+		"mnemonic": wallet.Mnemonic,
+		"data_key": wallet.DataKey,
+		"key_id":   wallet.KeyId,
+		"qr_code":  wallet.QRCode,
+	}).Error
+	if err == nil {
+		err = tx.Where("wallet_id = ?", wallet.Id).Delete(&models.Account{}).Error
+	}
+	if err == nil && len(wallet.Accounts) > 0 {
+		err = tx.Create(&wallet.Accounts).Error
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to replace wallet", map[string]interface{}{
+			"wallet_id": wallet.Id,
+		})
+		if err := db.EndTransaction(tx, false); err != nil {
+			utils.LogError(err, "Failed to rollback transaction", nil)
+		}
+		return fmt.Errorf("failed to replace wallet: %w", err)
+	}
+
+	if err := db.EndTransaction(tx, true); err != nil {
+		utils.LogError(err, "Failed to commit transaction", nil)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	utils.LogInfo("Wallet replaced", map[string]interface{}{
+		"wallet_id": wallet.Id,
+		"address":   wallet.Address,
+	})
+
+	return nil
+}
+
+// UpdateWalletEncryption stores the wallet's re-encrypted mnemonic and data key if the
+// mnemonic still equals previous, so a concurrent PIN reset or import is never overwritten.
+// It reports whether a row changed.
+func (r *UserRepository) UpdateWalletEncryption(wallet *models.Wallet, previous string) (bool, error) {
+	result := r.db.Model(&models.Wallet{}).
+		Where("id = ? AND mnemonic = ?", wallet.Id, previous).
+		Updates(map[string]interface{}{
+			"mnemonic": wallet.Mnemonic,
+			"data_key": wallet.DataKey,
+			"key_id":   wallet.KeyId,
+		})
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to update wallet encryption", map[string]interface{}{
+			"wallet_id": wallet.Id,
+		})
+		return false, fmt.Errorf("failed to update wallet encryption: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ListWalletsNotWrappedBy returns up to limit wallets, ordered by ID after afterID, whose data
+// key is missing or wrapped by a master key other than keyID
+func (r *UserRepository) ListWalletsNotWrappedBy(keyID, afterID string, limit int) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	err := r.db.Where("id > ? AND (key_id IS NULL OR key_id <> ?)", afterID, keyID).
+		Order("id").Limit(limit).Find(&wallets).Error
+	if err != nil {
+		utils.LogError(err, "Failed to list wallets", nil)
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	return wallets, nil
+}
+
+// ActivateUser marks a pending user's phone number as verified. It reports false if the
+// user was not pending.
+func (r *UserRepository) ActivateUser(userID string) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND status = ?", userID, models.UserStatusPending).
+		Updates(map[string]interface{}{
+			"status":            models.UserStatusActive,
+			"phone_verified_at": time.Now(),
+		})
+	if result.Error != nil {
+		utils.LogError(result.Error, "Failed to activate user", map[string]interface{}{
+			"user_id": userID,
+		})
+		return false, fmt.Errorf("failed to activate user: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeletePendingUser removes a user that never verified their phone number, together with
+// their wallet and accounts, so the number can be registered again
+func (r *UserRepository) DeletePendingUser(userID string) error {
+	tx, err := db.BeginTransaction(r.db)
+	if err != nil {
+		utils.LogError(err, "Failed to begin transaction", nil)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	err = tx.Where("user_id = ?", userID).Delete(&models.Account{}).Error
+	if err == nil {
+		err = tx.Where("user_id = ?", userID).Delete(&models.Wallet{}).Error
+	}
+	if err == nil {
+		result := tx.Where("id = ? AND status = ?", userID, models.UserStatusPending).Delete(&models.User{})
+		err = result.Error
+		if err == nil && result.RowsAffected == 0 {
+			err = errors.New("user is not pending")
+		}
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to delete pending user", map[string]interface{}{
+			"user_id": userID,
+		})
+		if err := db.EndTransaction(tx, false); err != nil {
+			utils.LogError(err, "Failed to rollback transaction", nil)
+		}
+		return fmt.Errorf("failed to delete pending user: %w", err)
+	}
+
+	if err := db.EndTransaction(tx, true); err != nil {
+		utils.LogError(err, "Failed to commit transaction", nil)
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	utils.LogInfo("Pending user deleted", map[string]interface{}{
+		"user_id": userID,
+	})
+
+	return nil
+}
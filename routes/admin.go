@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"test-wallet/handlers"
+	"test-wallet/middleware"
+	"test-wallet/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func RegisterAdminRoutes(r *gin.Engine, conn *gorm.DB, chains *services.ChainRegistry) {
+	adminHandler := handlers.NewAdminHandler(conn, chains)
+
+	admin := r.Group("/admin")
+	admin.Use(middleware.AdminMiddleware())
+	{
+		admin.POST("/users/:id/unlock", adminHandler.UnlockUser)
+		admin.POST("/ips/:ip/unlock", adminHandler.UnlockIP)
+		admin.GET("/chains/health", adminHandler.ChainsHealth)
+	}
+}
@@ -2,20 +2,38 @@ package routes
 
 import (
 	"test-wallet/handlers"
+	"test-wallet/middleware"
+	"test-wallet/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func RegisterAuthRoutes(r *gin.Engine) {
-	authHandler, err := handlers.NewAuthHandler()
+func RegisterAuthRoutes(r *gin.Engine, users services.UserStore, conn *gorm.DB, chains *services.ChainRegistry) {
+	authHandler, err := handlers.NewAuthHandler(users, conn, chains)
 	if err != nil {
 		panic(err) // Handle error appropriately in production
 	}
 
+	pinAttempts := middleware.PinAttemptLimit(services.NewPinGuard(conn))
+
 	auth := r.Group("/auth")
 	{
 		auth.POST("/register", authHandler.RegisterUser)
-		auth.POST("/login", authHandler.LoginUser)
-		auth.POST("/reset-pin" /*handlers.ResetPin*/)
+		auth.POST("/verify-phone", pinAttempts, authHandler.VerifyPhone)
+		auth.POST("/resend-otp", authHandler.ResendOTP)
+		auth.POST("/login", pinAttempts, authHandler.LoginUser)
+		auth.POST("/reset-pin", pinAttempts, authHandler.ResetPin)
+		auth.POST("/refresh", authHandler.RefreshToken)
+		auth.GET("/siwe/nonce", authHandler.SIWENonce)
+		auth.POST("/siwe/verify", pinAttempts, authHandler.SIWEVerify)
+	}
+
+	sessions := r.Group("/auth")
+	sessions.Use(middleware.AuthMiddleware(conn))
+	{
+		sessions.POST("/logout", authHandler.Logout)
+		sessions.GET("/sessions", authHandler.ListSessions)
+		sessions.DELETE("/sessions/:id", authHandler.RevokeSession)
 	}
 }
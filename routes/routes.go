@@ -1,10 +1,21 @@
 package routes
 
 import (
+	"test-wallet/handlers"
+	"test-wallet/repository"
+	"test-wallet/services"
+
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func RegisterRoutes(r *gin.Engine) {
-	RegisterAuthRoutes(r)
-	RegisterWalletRoutes(r)
+// RegisterRoutes registers every route on handlers backed by the given database and chains
+func RegisterRoutes(r *gin.Engine, conn *gorm.DB, chains *services.ChainRegistry) {
+	users := repository.NewUserRepository(conn)
+
+	r.GET("/.well-known/jwks.json", handlers.JWKS)
+	r.GET("/chains", handlers.ListChains(chains))
+	RegisterAuthRoutes(r, users, conn, chains)
+	RegisterWalletRoutes(r, users, conn, chains)
+	RegisterAdminRoutes(r, conn, chains)
 }
@@ -1,26 +1,52 @@
 package routes
 
 import (
+	"test-wallet/config"
 	"test-wallet/handlers"
 	"test-wallet/middleware"
+	"test-wallet/services"
 	"test-wallet/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func RegisterWalletRoutes(r *gin.Engine) {
-	walletHandler, err := handlers.NewWalletHandler()
+func RegisterWalletRoutes(r *gin.Engine, users services.UserStore, conn *gorm.DB, chains *services.ChainRegistry) {
+	walletHandler, err := handlers.NewWalletHandler(users, conn, chains)
 	if err != nil {
 		utils.LogFatal(err, "Failed to create wallet handler", nil)
 	}
 
+	pinAttempts := middleware.PinAttemptLimit(services.NewPinGuard(conn))
+	exportLimit := middleware.UserRateLimit(config.AppConfig.SecurityConfig.ExportRateLimit, config.AppConfig.SecurityConfig.ExportRateWindow)
+
 	wallet := r.Group("/wallet")
-	wallet.Use(middleware.AuthMiddleware())
+	wallet.Use(middleware.AuthMiddleware(conn))
 	{
 		wallet.GET("/balance/:address", walletHandler.GetBalance)
-		wallet.POST("/send-eth", walletHandler.SendETH)
-		wallet.POST("/send-erc20", walletHandler.SendERC20Token)
-		wallet.POST("/recover", walletHandler.RecoverWalletHandler)
+		wallet.POST("/send-eth", pinAttempts, walletHandler.SendETH)
+		wallet.POST("/send-erc20", pinAttempts, walletHandler.SendERC20Token)
+		wallet.GET("/tokens", walletHandler.ListTokens)
+		wallet.GET("/accounts", walletHandler.ListAccounts)
+		wallet.POST("/accounts", pinAttempts, walletHandler.CreateAccount)
+		wallet.GET("/transactions", walletHandler.ListTransactions)
+		wallet.POST("/transactions/:hash/speed-up", pinAttempts, walletHandler.SpeedUpTransaction)
+		wallet.POST("/transactions/:hash/cancel", pinAttempts, walletHandler.CancelTransaction)
+		wallet.POST("/import", pinAttempts, walletHandler.ImportWallet)
+		wallet.POST("/recover", pinAttempts, walletHandler.RecoverWalletHandler)
+		wallet.POST("/export", exportLimit, pinAttempts, walletHandler.ExportWallet)
 		wallet.GET("/qr", walletHandler.GetWalletQR)
+		wallet.GET("/totp", walletHandler.GetTOTPStatus)
+		wallet.POST("/totp/enroll", pinAttempts, walletHandler.EnrollTOTP)
+		wallet.POST("/totp/verify", pinAttempts, walletHandler.ConfirmTOTP)
+		wallet.POST("/totp/disable", pinAttempts, walletHandler.DisableTOTP)
+		wallet.PUT("/totp/policy", pinAttempts, walletHandler.UpdateTOTPPolicy)
+		wallet.POST("/sign/message", pinAttempts, walletHandler.SignMessage)
+		wallet.POST("/sign/typed-data", pinAttempts, walletHandler.SignTypedData)
+		wallet.POST("/contract/call", walletHandler.CallContract)
+		wallet.POST("/contract/transact", pinAttempts, walletHandler.TransactContract)
+		wallet.GET("/contract/abis", walletHandler.ListContractAbis)
+		wallet.POST("/contract/abis", walletHandler.SaveContractAbi)
+		wallet.DELETE("/contract/abis/:id", walletHandler.DeleteContractAbi)
 	}
 }
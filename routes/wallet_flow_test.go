@@ -0,0 +1,530 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"test-wallet/config"
+	"test-wallet/db"
+	"test-wallet/middleware"
+	"test-wallet/models"
+	"test-wallet/routes"
+	"test-wallet/services"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testChainName = "simulated"
+	testPin       = "482913"
+	tokenSymbol   = "TKN"
+	tokenDecimals = 6
+)
+
+var ether = big.NewInt(1e18)
+
+// testEnv is the API running against a simulated chain and an in-memory SQLite database
+type testEnv struct {
+	t       *testing.T
+	router  *gin.Engine
+	conn    *gorm.DB
+	chains  *services.ChainRegistry
+	backend *simulated.Backend
+	faucet  *ecdsa.PrivateKey
+	token   common.Address
+	smsLog  string
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	faucet, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(faucet.PublicKey): {Balance: new(big.Int).Mul(big.NewInt(1000), ether)},
+	})
+	t.Cleanup(func() { backend.Close() })
+
+	env := &testEnv{
+		t:       t,
+		backend: backend,
+		faucet:  faucet,
+		smsLog:  filepath.Join(t.TempDir(), "sms.log"),
+	}
+	env.token = env.deployToken()
+
+	masterKey := make([]byte, 32)
+	config.AppConfig = config.Config{
+		ServerConfig: config.ServerConfig{Environment: "test"},
+		JWTConfig: config.JWTConfig{
+			Secret:            "test-secret",
+			Algorithm:         "HS256",
+			Expiration:        15 * time.Minute,
+			RefreshExpiration: 24 * time.Hour,
+		},
+		EthConfig: config.EthConfig{
+			DefaultChain:        testChainName,
+			Confirmations:       1,
+			ReceiptPollInterval: 20 * time.Millisecond,
+		},
+		SecurityConfig: config.SecurityConfig{
+			ExportRateLimit:    3,
+			ExportRateWindow:   time.Hour,
+			PinMaxAttempts:     5,
+			IPPinMaxAttempts:   20,
+			PinBackoff:         time.Millisecond,
+			PinLockout:         time.Minute,
+			OTPExpiration:      10 * time.Minute,
+			OTPMaxAttempts:     5,
+			TOTPEthThreshold:   "100",
+			TOTPTokenThreshold: "1000000",
+			TOTPIssuer:         "Test Wallet",
+		},
+		KeyConfig: config.KeyConfig{
+			Provider:    "local",
+			MasterKeyID: "test",
+			MasterKeys:  "test:" + base64.StdEncoding.EncodeToString(masterKey),
+		},
+		SMSConfig: config.SMSConfig{Provider: "file", FilePath: env.smsLog},
+	}
+	if err := services.InitKeyProvider(); err != nil {
+		t.Fatal(err)
+	}
+	if err := middleware.InitJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if err := services.InitSMSSender(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A named shared-cache database lives as long as its one pooled connection
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	conn, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard, TranslateError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	env.conn = conn
+
+	chain := services.NewChain(config.ChainConfig{
+		ID:           1337,
+		Name:         testChainName,
+		NativeSymbol: "ETH",
+		EIP1559:      true,
+		Tokens:       []config.TokenSeed{{Symbol: tokenSymbol, Address: env.token.Hex()}},
+	}, backend.Client())
+	env.chains, err = services.NewChainRegistryFrom([]*services.Chain{chain}, testChainName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := services.SeedTokenRegistry(context.Background(), conn, env.chains); err != nil {
+		t.Fatal(err)
+	}
+
+	env.router = gin.New()
+	routes.RegisterRoutes(env.router, conn, env.chains)
+	return env
+}
+
+// readTestdata reads a file from the testdata directory
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.TrimSpace(content)
+}
+
+// deployToken deploys a minimal ERC-20 token holding the whole supply at the faucet
+func (e *testEnv) deployToken() common.Address {
+	tokenABI, err := abi.JSON(bytes.NewReader(readTestdata(e.t, "token.abi")))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	args, err := tokenABI.Pack("", new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(1_000_000)), "Test Token", uint8(tokenDecimals), tokenSymbol)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	code := common.FromHex(string(readTestdata(e.t, "token.bin")))
+
+	receipt := e.faucetSend(nil, nil, append(code, args...))
+	return receipt.ContractAddress
+}
+
+// faucetSend sends a transaction from the faucet, mines it and checks that it succeeded
+func (e *testEnv) faucetSend(to *common.Address, value *big.Int, data []byte) *types.Receipt {
+	e.t.Helper()
+
+	ctx := context.Background()
+	client := e.backend.Client()
+	from := crypto.PubkeyToAddress(e.faucet.PublicKey)
+
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	tip := big.NewInt(1e9)
+	gas, err := client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: to, Value: value, Data: data})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+
+	tx, err := types.SignNewTx(e.faucet, types.LatestSignerForChainID(big.NewInt(1337)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(1337),
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tip),
+		Gas:       gas,
+		To:        to,
+		Value:     value,
+		Data:      data,
+	})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if err := client.SendTransaction(ctx, tx); err != nil {
+		e.t.Fatal(err)
+	}
+	e.backend.Commit()
+
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		e.t.Fatalf("faucet transaction %s failed", tx.Hash().Hex())
+	}
+	return receipt
+}
+
+// fund sends ETH and tokens from the faucet to the address
+func (e *testEnv) fund(address common.Address, eth, tokens int64) {
+	e.faucetSend(&address, new(big.Int).Mul(big.NewInt(eth), ether), nil)
+
+	data := append(crypto.Keccak256([]byte("transfer(address,uint256)"))[:4], common.LeftPadBytes(address.Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(tokenUnits(tokens).Bytes(), 32)...)
+	e.faucetSend(&e.token, nil, data)
+}
+
+// tokenUnits converts whole tokens to the token's smallest unit
+func tokenUnits(tokens int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(tokens), new(big.Int).Exp(big.NewInt(10), big.NewInt(tokenDecimals), nil))
+}
+
+// tokenBalance reads the owner's token balance from the chain
+func (e *testEnv) tokenBalance(owner common.Address) *big.Int {
+	e.t.Helper()
+
+	data := append(crypto.Keccak256([]byte("balanceOf(address)"))[:4], common.LeftPadBytes(owner.Bytes(), 32)...)
+	output, err := e.backend.Client().CallContract(context.Background(), ethereum.CallMsg{To: &e.token, Data: data}, nil)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return new(big.Int).SetBytes(output)
+}
+
+// do sends a JSON request to the API and decodes the response into out
+func (e *testEnv) do(method, path, token string, body, out interface{}) int {
+	e.t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			e.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	e.router.ServeHTTP(rec, req)
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			e.t.Fatalf("%s %s: invalid response %q", method, path, rec.Body.String())
+		}
+	}
+	return rec.Code
+}
+
+var otpPattern = regexp.MustCompile(`code is (\d+)`)
+
+// lastOTP returns the latest verification code texted to the phone number
+func (e *testEnv) lastOTP(phoneNumber string) string {
+	e.t.Helper()
+
+	content, err := os.ReadFile(e.smsLog)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	code := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.Contains(line, "\t"+phoneNumber+"\t") {
+			if match := otpPattern.FindStringSubmatch(line); match != nil {
+				code = match[1]
+			}
+		}
+	}
+	if code == "" {
+		e.t.Fatalf("no verification code sent to %s", phoneNumber)
+	}
+	return code
+}
+
+// signUp registers, verifies and logs a user in, returning the login response
+func (e *testEnv) signUp(name, phoneNumber string) *models.LoginResponse {
+	e.t.Helper()
+
+	var registered models.RegisterResponse
+	if code := e.do(http.MethodPost, "/auth/register", "", models.RegisterUserRequest{
+		Name:        name,
+		PhoneNumber: phoneNumber,
+		Pin:         testPin,
+	}, &registered); code != http.StatusCreated {
+		e.t.Fatalf("register: status %d", code)
+	}
+
+	if code := e.do(http.MethodPost, "/auth/verify-phone", "", models.VerifyPhoneRequest{
+		PhoneNumber: phoneNumber,
+		Code:        e.lastOTP(phoneNumber),
+	}, nil); code != http.StatusOK {
+		e.t.Fatalf("verify phone: status %d", code)
+	}
+
+	var login models.LoginResponse
+	if code := e.do(http.MethodPost, "/auth/login", "", models.LoginRequest{
+		PhoneNumber: phoneNumber,
+		Pin:         testPin,
+	}, &login); code != http.StatusOK {
+		e.t.Fatalf("login: status %d", code)
+	}
+	if login.User.Wallet.Address != registered.WalletAddress {
+		e.t.Fatalf("login returned wallet %s, registered %s", login.User.Wallet.Address, registered.WalletAddress)
+	}
+	return &login
+}
+
+func TestWalletFlow(t *testing.T) {
+	env := newTestEnv(t)
+	login := env.signUp("Alice", "+15550000001")
+
+	sender := common.HexToAddress(login.User.Wallet.Address)
+	recipient := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	env.fund(sender, 2, 100)
+
+	var sentETH struct {
+		TransactionHash string `json:"transaction_hash"`
+	}
+	if code := env.do(http.MethodPost, "/wallet/send-eth", login.Token, models.SendETHRequest{
+		ToAddress:   recipient.Hex(),
+		AmountInETH: "0.5",
+		Pin:         testPin,
+	}, &sentETH); code != http.StatusOK {
+		t.Fatalf("send-eth: status %d", code)
+	}
+	env.backend.Commit()
+
+	var sentToken struct {
+		TransactionHash string `json:"transaction_hash"`
+	}
+	if code := env.do(http.MethodPost, "/wallet/send-erc20", login.Token, models.SendERC20Request{
+		ToAddress: recipient.Hex(),
+		Token:     tokenSymbol,
+		Amount:    "25",
+		Pin:       testPin,
+	}, &sentToken); code != http.StatusOK {
+		t.Fatalf("send-erc20: status %d", code)
+	}
+	env.backend.Commit()
+
+	balance, err := env.backend.Client().BalanceAt(context.Background(), recipient, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := new(big.Int).Div(ether, big.NewInt(2)); balance.Cmp(want) != 0 {
+		t.Errorf("recipient ETH balance = %s, want %s", balance, want)
+	}
+	if got, want := env.tokenBalance(recipient), tokenUnits(25); got.Cmp(want) != 0 {
+		t.Errorf("recipient token balance = %s, want %s", got, want)
+	}
+	if got, want := env.tokenBalance(sender), tokenUnits(75); got.Cmp(want) != 0 {
+		t.Errorf("sender token balance = %s, want %s", got, want)
+	}
+
+	// The tracker moves both transfers to confirmed once their receipts are mined
+	tracker, err := services.NewTxTracker(env.conn, env.chains)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Start()
+	defer tracker.Stop(context.Background())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var history models.TransactionListResponse
+		if code := env.do(http.MethodGet, "/wallet/transactions?status=confirmed", login.Token, nil, &history); code != http.StatusOK {
+			t.Fatalf("transactions: status %d", code)
+		}
+		if len(history.Transactions) == 2 {
+			hashes := map[string]bool{}
+			for _, tx := range history.Transactions {
+				hashes[tx.Hash] = true
+			}
+			if !hashes[sentETH.TransactionHash] || !hashes[sentToken.TransactionHash] {
+				t.Fatalf("unexpected confirmed transactions %v", hashes)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of 2 transactions confirmed", len(history.Transactions))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSendRejectsWrongPin(t *testing.T) {
+	env := newTestEnv(t)
+	login := env.signUp("Bob", "+15550000002")
+	env.fund(common.HexToAddress(login.User.Wallet.Address), 1, 0)
+
+	code := env.do(http.MethodPost, "/wallet/send-eth", login.Token, models.SendETHRequest{
+		ToAddress:   "0x00000000000000000000000000000000000000bb",
+		AmountInETH: "0.1",
+		Pin:         "000000",
+	}, nil)
+	if code != http.StatusUnauthorized {
+		t.Fatalf("send-eth with wrong PIN: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestLoginRequiresVerifiedPhone(t *testing.T) {
+	env := newTestEnv(t)
+
+	if code := env.do(http.MethodPost, "/auth/register", "", models.RegisterUserRequest{
+		Name:        "Carol",
+		PhoneNumber: "+15550000003",
+		Pin:         testPin,
+	}, nil); code != http.StatusCreated {
+		t.Fatalf("register: status %d", code)
+	}
+
+	code := env.do(http.MethodPost, "/auth/login", "", models.LoginRequest{
+		PhoneNumber: "+15550000003",
+		Pin:         testPin,
+	}, nil)
+	if code != http.StatusForbidden {
+		t.Fatalf("login before verification: status %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestWalletRoutesRequireToken(t *testing.T) {
+	env := newTestEnv(t)
+
+	code := env.do(http.MethodPost, "/wallet/send-eth", "", models.SendETHRequest{
+		ToAddress:   "0x00000000000000000000000000000000000000cc",
+		AmountInETH: "0.1",
+		Pin:         testPin,
+	}, nil)
+	if code != http.StatusUnauthorized {
+		t.Fatalf("send-eth without token: status %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestResetPinWithPrefixedPrivateKey(t *testing.T) {
+	env := newTestEnv(t)
+	login := env.signUp("Dave", "+15550000004")
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := "0x" + common.Bytes2Hex(crypto.FromECDSA(key))
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	var imported models.ImportWalletResponse
+	if code := env.do(http.MethodPost, "/wallet/import", login.Token, models.ImportWalletRequest{
+		WalletImport:    models.WalletImport{PrivateKey: privateKey},
+		Pin:             testPin,
+		ReplaceExisting: true,
+	}, &imported); code != http.StatusOK {
+		t.Fatalf("import: status %d", code)
+	}
+	if imported.Address != address.Hex() {
+		t.Fatalf("imported wallet %s, want %s", imported.Address, address.Hex())
+	}
+
+	// The forgotten-PIN flow accepts the key as it was imported, 0x prefix included
+	const newPin = "739146"
+	if code := env.do(http.MethodPost, "/auth/reset-pin", "", models.ResetPinRequest{
+		PhoneNumber: "+15550000004",
+		Mnemonic:    privateKey,
+		NewPin:      newPin,
+	}, nil); code != http.StatusOK {
+		t.Fatalf("reset-pin: status %d", code)
+	}
+
+	var relogin models.LoginResponse
+	if code := env.do(http.MethodPost, "/auth/login", "", models.LoginRequest{
+		PhoneNumber: "+15550000004",
+		Pin:         newPin,
+	}, &relogin); code != http.StatusOK {
+		t.Fatalf("login with new PIN: status %d", code)
+	}
+
+	// The wallet unlocks with the new PIN
+	env.fund(address, 1, 0)
+	if code := env.do(http.MethodPost, "/wallet/send-eth", relogin.Token, models.SendETHRequest{
+		ToAddress:   "0x00000000000000000000000000000000000000dd",
+		AmountInETH: "0.1",
+		Pin:         newPin,
+	}, nil); code != http.StatusOK {
+		t.Fatalf("send-eth after reset: status %d", code)
+	}
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// parseContractABI parses an ABI JSON array, or a single fragment object
+func parseContractABI(definition json.RawMessage) (abi.ABI, error) {
+	trimmed := bytes.TrimSpace(definition)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		trimmed = append(append([]byte{'['}, trimmed...), ']')
+	}
+
+	parsed, err := abi.JSON(bytes.NewReader(trimmed))
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("invalid ABI: %w", err)
+	}
+	if len(parsed.Methods) == 0 {
+		return abi.ABI{}, errors.New("ABI has no methods")
+	}
+	return parsed, nil
+}
+
+// findABIMethod looks a method up by name, or by signature to pick one of several overloads
+func findABIMethod(parsed *abi.ABI, selector string) (abi.Method, error) {
+	if method, ok := parsed.Methods[selector]; ok {
+		return method, nil
+	}
+
+	signature := strings.ReplaceAll(selector, " ", "")
+	for _, method := range parsed.Methods {
+		if method.Sig == signature {
+			return method, nil
+		}
+	}
+	return abi.Method{}, fmt.Errorf("method %q not found in ABI", selector)
+}
+
+// packABIArguments converts JSON arguments to the method's input types and encodes the calldata
+func packABIArguments(method abi.Method, args []json.RawMessage) ([]byte, error) {
+	if len(args) != len(method.Inputs) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", method.Sig, len(method.Inputs), len(args))
+	}
+
+	values := make([]interface{}, len(args))
+	for i, input := range method.Inputs {
+		value, err := abiValueFromJSON(input.Type, args[i])
+		if err != nil {
+			return nil, fmt.Errorf("argument %d (%s): %w", i, input.Type, err)
+		}
+		values[i] = value.Interface()
+	}
+
+	encoded, err := method.Inputs.Pack(values...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack arguments: %w", err)
+	}
+	return append(append([]byte{}, method.ID...), encoded...), nil
+}
+
+// abiValueFromJSON converts a JSON value to the Go type that abi.Pack expects for t.
+// Integers are JSON numbers or decimal/0x strings, bytes are 0x hex, arrays are JSON
+// arrays and tuples are objects keyed by component name or arrays in component order.
+func abiValueFromJSON(t abi.Type, raw json.RawMessage) (reflect.Value, error) {
+	goType := t.GetType()
+
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		n, err := parseJSONInteger(raw)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if err := checkIntegerRange(t, n); err != nil {
+			return reflect.Value{}, err
+		}
+		switch goType.Kind() {
+		case reflect.Ptr:
+			return reflect.ValueOf(n), nil
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return reflect.ValueOf(n.Uint64()).Convert(goType), nil
+		default:
+			return reflect.ValueOf(n.Int64()).Convert(goType), nil
+		}
+
+	case abi.BoolTy:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return reflect.Value{}, errors.New("expected true or false")
+		}
+		return reflect.ValueOf(b), nil
+
+	case abi.StringTy:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return reflect.Value{}, errors.New("expected a string")
+		}
+		return reflect.ValueOf(s), nil
+
+	case abi.AddressTy:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil || !common.IsHexAddress(s) {
+			return reflect.Value{}, errors.New("expected a hex address")
+		}
+		return reflect.ValueOf(common.HexToAddress(s)), nil
+
+	case abi.BytesTy:
+		b, err := decodeJSONHex(raw)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b), nil
+
+	case abi.FixedBytesTy, abi.FunctionTy:
+		b, err := decodeJSONHex(raw)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		value := reflect.New(goType).Elem()
+		if len(b) != value.Len() {
+			return reflect.Value{}, fmt.Errorf("expected %d bytes, got %d", value.Len(), len(b))
+		}
+		reflect.Copy(value, reflect.ValueOf(b))
+		return value, nil
+
+	case abi.SliceTy, abi.ArrayTy:
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return reflect.Value{}, errors.New("expected an array")
+		}
+		var value reflect.Value
+		if t.T == abi.SliceTy {
+			value = reflect.MakeSlice(goType, len(items), len(items))
+		} else {
+			if len(items) != t.Size {
+				return reflect.Value{}, fmt.Errorf("expected %d items, got %d", t.Size, len(items))
+			}
+			value = reflect.New(goType).Elem()
+		}
+		for i, item := range items {
+			elem, err := abiValueFromJSON(*t.Elem, item)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("item %d: %w", i, err)
+			}
+			value.Index(i).Set(elem)
+		}
+		return value, nil
+
+	case abi.TupleTy:
+		items, err := tupleItems(t, raw)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		value := reflect.New(goType).Elem()
+		for i, elemType := range t.TupleElems {
+			elem, err := abiValueFromJSON(*elemType, items[i])
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("%s: %w", tupleFieldName(t, i), err)
+			}
+			value.Field(i).Set(elem)
+		}
+		return value, nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("unsupported type %s", t)
+}
+
+// tupleItems returns the JSON value of each tuple component in order
+func tupleItems(t abi.Type, raw json.RawMessage) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err == nil {
+		if len(items) != len(t.TupleElems) {
+			return nil, fmt.Errorf("expected %d components, got %d", len(t.TupleElems), len(items))
+		}
+		return items, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, errors.New("expected an object or array")
+	}
+	items = make([]json.RawMessage, len(t.TupleElems))
+	for i := range t.TupleElems {
+		item, ok := fields[t.TupleRawNames[i]]
+		if !ok {
+			return nil, fmt.Errorf("missing component %q", t.TupleRawNames[i])
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// tupleFieldName returns the component's name, or its index when the ABI leaves it unnamed
+func tupleFieldName(t abi.Type, i int) string {
+	if name := t.TupleRawNames[i]; name != "" {
+		return name
+	}
+	return strconv.Itoa(i)
+}
+
+// parseJSONInteger reads a JSON number or a decimal or 0x-prefixed string
+func parseJSONInteger(raw json.RawMessage) (*big.Int, error) {
+	text := strings.TrimSpace(string(raw))
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		text = strings.TrimSpace(s)
+	}
+
+	n, ok := new(big.Int).SetString(text, 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %s", raw)
+	}
+	return n, nil
+}
+
+// checkIntegerRange rejects values that do not fit the ABI integer type
+func checkIntegerRange(t abi.Type, n *big.Int) error {
+	if t.T == abi.UintTy {
+		if n.Sign() < 0 || n.BitLen() > t.Size {
+			return fmt.Errorf("%s out of range for %s", n, t)
+		}
+		return nil
+	}
+
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(t.Size-1))
+	if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+		return fmt.Errorf("%s out of range for %s", n, t)
+	}
+	return nil
+}
+
+// decodeJSONHex reads a 0x-prefixed hex string
+func decodeJSONHex(raw json.RawMessage) ([]byte, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, errors.New("expected a 0x-prefixed hex string")
+	}
+	b, err := hexutil.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex: %w", err)
+	}
+	return b, nil
+}
+
+// abiValueToJSON converts a decoded value to a JSON-friendly form. Integers become decimal
+// strings so large values survive JSON clients, bytes become hex and tuples become objects.
+func abiValueToJSON(t abi.Type, value interface{}) interface{} {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		return fmt.Sprint(value)
+	case abi.AddressTy:
+		return value.(common.Address).Hex()
+	case abi.BytesTy:
+		return hexutil.Encode(value.([]byte))
+	case abi.FixedBytesTy, abi.FunctionTy:
+		array := reflect.ValueOf(value)
+		b := make([]byte, array.Len())
+		reflect.Copy(reflect.ValueOf(b), array)
+		return hexutil.Encode(b)
+	case abi.SliceTy, abi.ArrayTy:
+		list := reflect.ValueOf(value)
+		items := make([]interface{}, list.Len())
+		for i := range items {
+			items[i] = abiValueToJSON(*t.Elem, list.Index(i).Interface())
+		}
+		return items
+	case abi.TupleTy:
+		tuple := reflect.ValueOf(value)
+		fields := make(map[string]interface{}, len(t.TupleElems))
+		for i, elemType := range t.TupleElems {
+			fields[tupleFieldName(t, i)] = abiValueToJSON(*elemType, tuple.Field(i).Interface())
+		}
+		return fields
+	}
+	return value
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"strings"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrAccountNotFound is returned when an account selector matches none of the user's accounts
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrAccountExists is returned when creating an account with a name already in use
+	ErrAccountExists = errors.New("account name already in use")
+	// ErrNotHDWallet is returned when deriving accounts from a wallet imported as a single private key
+	ErrNotHDWallet = errors.New("wallet was imported from a private key and has a single account")
+)
+
+// accountDerivationPath returns the BIP-44 path of the account at the given address index
+func accountDerivationPath(index uint32) string {
+	return fmt.Sprintf("m/44'/60'/0'/0/%d", index)
+}
+
+// walletAccounts returns the wallet's accounts, creating the default account for
+// wallets registered before accounts existed
+func (s *WalletService) walletAccounts(user *models.User) ([]models.Account, error) {
+	if len(user.Wallet.Accounts) > 0 {
+		return user.Wallet.Accounts, nil
+	}
+
+	account := models.Account{
+		Id:       uuid.New().String(),
+		WalletId: user.Wallet.Id,
+		UserId:   user.Id,
+		Name:     models.DefaultAccountName,
+		Index:    0,
+		Address:  user.Wallet.Address,
+	}
+	if err := s.accountRepo.CreateAccount(&account); err != nil {
+		return nil, err
+	}
+
+	user.Wallet.Accounts = []models.Account{account}
+	return user.Wallet.Accounts, nil
+}
+
+// findAccount selects one of the user's accounts by name or address; an empty selector
+// picks the first account
+func (s *WalletService) findAccount(user *models.User, selector string) (*models.Account, error) {
+	accounts, err := s.walletAccounts(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if selector == "" {
+		return &accounts[0], nil
+	}
+	for i := range accounts {
+		if strings.EqualFold(accounts[i].Name, selector) || strings.EqualFold(accounts[i].Address, selector) {
+			return &accounts[i], nil
+		}
+	}
+
+	return nil, ErrAccountNotFound
+}
+
+// authorizeUser loads the user with their wallet and verifies the PIN through the PIN guard
+func (s *WalletService) authorizeUser(userID, pin string) (*models.User, error) {
+	// Get user's wallet
+	user, err := s.userRepo.FindUserByID(userID)
+	if err != nil {
+		utils.LogError(err, "Failed to get user wallet", map[string]interface{}{
+			"user_id": userID,
+		})
+		return nil, fmt.Errorf("failed to get user wallet: %w", err)
+	}
+
+	// Verify PIN
+	if err := s.pinGuard.Verify(user, pin); err != nil {
+		utils.LogError(err, "Invalid PIN", map[string]interface{}{
+			"user_id": userID,
+		})
+		return nil, pinError(err, ErrInvalidPin)
+	}
+
+	return user, nil
+}
+
+// unlockMnemonic verifies the user's PIN and decrypts the wallet mnemonic
+func (s *WalletService) unlockMnemonic(userID, pin string) (*models.User, string, error) {
+	user, err := s.authorizeUser(userID, pin)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Decrypt the mnemonic using the provided PIN
+	mnemonic, err := openWallet(&user.Wallet, pin)
+	if err != nil {
+		utils.LogError(err, "Failed to decrypt mnemonic", nil)
+		return nil, "", fmt.Errorf("failed to decrypt mnemonic: %w", err)
+	}
+	upgradeWalletEncryption(s.userRepo, user, pin, mnemonic)
+
+	return user, mnemonic, nil
+}
+
+// unlockAccount verifies the user's PIN and returns the selected account with its signing key
+func (s *WalletService) unlockAccount(userID, pin, selector string) (*models.Account, *ecdsa.PrivateKey, error) {
+	user, mnemonic, err := s.unlockMnemonic(userID, pin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	account, err := s.findAccount(user, selector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Recover the account's key from the mnemonic, or use the imported key directly
+	var address, privateKey string
+	if user.Wallet.Type == models.WalletTypePrivateKey {
+		if account.Index != 0 {
+			return nil, nil, ErrNotHDWallet
+		}
+		address, err = secretAddress(user.Wallet.Type, mnemonic)
+		privateKey = mnemonic
+	} else {
+		address, privateKey, err = RecoverWalletFromMnemonic(mnemonic, accountDerivationPath(account.Index))
+	}
+	if err != nil {
+		utils.LogError(err, "Failed to recover wallet", nil)
+		return nil, nil, fmt.Errorf("failed to recover wallet: %w", err)
+	}
+	if !strings.EqualFold(address, account.Address) {
+		return nil, nil, fmt.Errorf("derived address does not match account %s", account.Name)
+	}
+
+	// Convert private key from hex to ECDSA
+	privKey, err := crypto.HexToECDSA(privateKey)
+	if err != nil {
+		utils.LogError(err, "Failed to convert private key", nil)
+		return nil, nil, fmt.Errorf("failed to convert private key: %w", err)
+	}
+
+	return account, privKey, nil
+}
+
+// ListAccounts returns the accounts of the user's wallet
+func (s *WalletService) ListAccounts(userID string) ([]models.Account, error) {
+	user, err := s.userRepo.FindUserByID(userID)
+	if err != nil {
+		utils.LogError(err, "Failed to get user wallet", map[string]interface{}{
+			"user_id": userID,
+		})
+		return nil, fmt.Errorf("failed to get user wallet: %w", err)
+	}
+
+	return s.walletAccounts(user)
+}
+
+// CreateAccount derives a new named account at the next unused address index
+func (s *WalletService) CreateAccount(userID string, req *models.CreateAccountRequest) (*models.Account, error) {
+	user, mnemonic, err := s.unlockMnemonic(userID, req.Pin)
+	if err != nil {
+		return nil, err
+	}
+	if user.Wallet.Type == models.WalletTypePrivateKey {
+		return nil, ErrNotHDWallet
+	}
+
+	accounts, err := s.walletAccounts(user)
+	if err != nil {
+		return nil, err
+	}
+	for _, existing := range accounts {
+		if strings.EqualFold(existing.Name, req.Name) {
+			return nil, ErrAccountExists
+		}
+	}
+
+	index, err := s.accountRepo.NextAccountIndex(user.Wallet.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	address, _, err := RecoverWalletFromMnemonic(mnemonic, accountDerivationPath(index))
+	if err != nil {
+		utils.LogError(err, "Failed to derive account", map[string]interface{}{
+			"user_id": userID,
+			"index":   index,
+		})
+		return nil, fmt.Errorf("failed to derive account: %w", err)
+	}
+
+	account := &models.Account{
+		Id:       uuid.New().String(),
+		WalletId: user.Wallet.Id,
+		UserId:   user.Id,
+		Name:     req.Name,
+		Index:    index,
+		Address:  common.HexToAddress(address).Hex(),
+	}
+	if err := s.accountRepo.CreateAccount(account); err != nil {
+		// A concurrent request created the same name first
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrAccountExists
+		}
+		return nil, err
+	}
+
+	return account, nil
+}
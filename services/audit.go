@@ -0,0 +1,51 @@
+package services
+
+import (
+	"encoding/json"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditService appends security-relevant events to the audit log
+type AuditService struct {
+	auditRepo *repository.AuditRepository
+}
+
+func NewAuditService(conn *gorm.DB) *AuditService {
+	return &AuditService{
+		auditRepo: repository.NewAuditRepository(conn),
+	}
+}
+
+// Record stores an audit event. Failures are logged and never fail the audited action.
+func (s *AuditService) Record(eventType, userID, ip string, details map[string]interface{}) {
+	event := &models.AuditEvent{
+		Id:     uuid.New().String(),
+		Type:   eventType,
+		UserId: userID,
+		IP:     ip,
+	}
+	if len(details) > 0 {
+		encoded, err := json.Marshal(details)
+		if err != nil {
+			utils.LogError(err, "Failed to encode audit details", map[string]interface{}{
+				"type": eventType,
+			})
+		}
+		event.Details = string(encoded)
+	}
+
+	if err := s.auditRepo.CreateAuditEvent(event); err != nil {
+		return
+	}
+
+	utils.LogInfo("Audit event recorded", map[string]interface{}{
+		"type":    eventType,
+		"user_id": userID,
+		"ip":      ip,
+	})
+}
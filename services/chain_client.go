@@ -0,0 +1,685 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"test-wallet/config"
+	"test-wallet/models"
+	"test-wallet/utils"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// endpointFailureThreshold is the number of consecutive transient failures that takes an
+	// endpoint out of rotation
+	endpointFailureThreshold = 3
+	// endpointCooldown is how long a failing endpoint is skipped; it doubles with each further
+	// failure up to endpointMaxCooldown
+	endpointCooldown    = 5 * time.Second
+	endpointMaxCooldown = 2 * time.Minute
+	// endpointMaxLag is how many blocks an endpoint may trail the highest head seen before
+	// reads avoid it
+	endpointMaxLag = 5
+	// latencySmoothing weighs each new latency sample in the moving average
+	latencySmoothing = 0.2
+	// unknownLatency scores endpoints that have not answered yet
+	unknownLatency = 100 * time.Millisecond
+)
+
+// errNoEndpoints is returned when every endpoint of a chain is disabled
+var errNoEndpoints = errors.New("no usable RPC endpoint")
+
+// ChainClient sends the RPC calls of one chain over several endpoints. Reads go to a healthy
+// endpoint chosen by latency and recent failures, and are retried on another endpoint with
+// backoff when the error is transient. Broadcasts are sent to every endpoint.
+type ChainClient struct {
+	chainID    uint64
+	chainName  string
+	endpoints  []*rpcEndpoint
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+	// pinned, when set, receives every read; see Pinned
+	pinned *rpcEndpoint
+}
+
+// rpcEndpoint is one RPC URL of a chain with its health statistics
+type rpcEndpoint struct {
+	url     string
+	limiter *tokenBucket // nil when the endpoint is not rate limited
+
+	mu        sync.Mutex
+	client    *ethclient.Client
+	verified  bool // The endpoint reported the expected chain ID
+	disabled  bool // The endpoint serves another chain
+	latency   time.Duration
+	failures  int
+	coolUntil time.Time
+	head      uint64
+	lagging   bool
+}
+
+// NewChainClient creates a client for the chain's RPC URLs. Endpoints are dialed on first use,
+// so an unreachable endpoint does not prevent the client from being created.
+func NewChainClient(chain config.ChainConfig, eth config.EthConfig) *ChainClient {
+	c := &ChainClient{
+		chainID:    chain.ID,
+		chainName:  chain.Name,
+		timeout:    eth.RPCTimeout,
+		maxRetries: eth.RPCMaxRetries,
+		backoff:    eth.RPCRetryBackoff,
+	}
+	if c.timeout <= 0 {
+		c.timeout = 10 * time.Second
+	}
+
+	for _, rawURL := range chain.RPCURLs {
+		endpoint := &rpcEndpoint{url: rawURL}
+		if chain.RPCRateLimit > 0 {
+			endpoint.limiter = newTokenBucket(chain.RPCRateLimit)
+		}
+		c.endpoints = append(c.endpoints, endpoint)
+	}
+	return c
+}
+
+// connect dials the endpoint on first use and checks once that it serves the expected chain
+func (e *rpcEndpoint) connect(ctx context.Context, c *ChainClient) (*ethclient.Client, error) {
+	e.mu.Lock()
+	if e.disabled {
+		e.mu.Unlock()
+		return nil, errNoEndpoints
+	}
+	if e.client == nil {
+		rpcClient, err := rpc.DialContext(ctx, e.url)
+		if err != nil {
+			e.mu.Unlock()
+			return nil, fmt.Errorf("failed to connect to RPC endpoint: %w", err)
+		}
+		e.client = ethclient.NewClient(rpcClient)
+	}
+	client, verified := e.client, e.verified
+	e.mu.Unlock()
+
+	if verified {
+		return client, nil
+	}
+
+	remoteID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if remoteID.Uint64() != c.chainID {
+		e.disabled = true
+		utils.LogError(nil, "RPC endpoint serves another chain", map[string]interface{}{
+			"chain":    c.chainName,
+			"endpoint": endpointHost(e.url),
+			"expected": c.chainID,
+			"actual":   remoteID.String(),
+		})
+		return nil, errNoEndpoints
+	}
+	e.verified = true
+	return client, nil
+}
+
+// do runs one call against the endpoint within the per-call timeout and updates its statistics
+func (e *rpcEndpoint) do(ctx context.Context, c *ChainClient, fn func(ctx context.Context, client *ethclient.Client) error) error {
+	if e.limiter != nil {
+		if err := e.limiter.wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	client, err := e.connect(callCtx, c)
+	if err == nil {
+		err = fn(callCtx, client)
+	}
+
+	// Errors returned by the node itself, such as reverts, still show the endpoint is healthy
+	if err != nil && isTransientRPCError(ctx, err) {
+		e.recordFailure(c, err)
+	} else if !errors.Is(err, errNoEndpoints) {
+		e.recordSuccess(time.Since(start))
+	}
+	return err
+}
+
+// recordSuccess folds a latency sample into the moving average and clears failures
+func (e *rpcEndpoint) recordSuccess(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(e.latency))
+	}
+	e.failures = 0
+	e.coolUntil = time.Time{}
+}
+
+// recordFailure counts a transient failure, taking the endpoint out of rotation for a while
+// once endpointFailureThreshold failures happen in a row
+func (e *rpcEndpoint) recordFailure(c *ChainClient, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures++
+	if e.failures < endpointFailureThreshold {
+		return
+	}
+
+	cooldown := endpointCooldown << min(e.failures-endpointFailureThreshold, 8)
+	cooldown = min(cooldown, endpointMaxCooldown)
+	e.coolUntil = time.Now().Add(cooldown)
+
+	utils.LogError(err, "RPC endpoint marked unhealthy", map[string]interface{}{
+		"chain":    c.chainName,
+		"endpoint": endpointHost(e.url),
+		"failures": e.failures,
+		"cooldown": cooldown.String(),
+	})
+}
+
+// usable reports whether reads may be sent to the endpoint; degraded also admits endpoints
+// that are cooling down or lagging, for when no healthy endpoint is left
+func (e *rpcEndpoint) usable(now time.Time, degraded bool) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.disabled {
+		return false
+	}
+	return degraded || (!now.Before(e.coolUntil) && !e.lagging)
+}
+
+// score ranks endpoints for reads; lower is better
+func (e *rpcEndpoint) score() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	latency := e.latency
+	if latency == 0 {
+		latency = unknownLatency
+	}
+	return float64(latency) * float64(1+e.failures)
+}
+
+// pick chooses the endpoint for the next read attempt among those not yet tried. Of two
+// random candidates the better scored one wins, which spreads reads while favouring fast,
+// reliable endpoints. Endpoints with rate limit capacity left are preferred.
+func (c *ChainClient) pick(tried map[*rpcEndpoint]bool) *rpcEndpoint {
+	now := time.Now()
+
+	var candidates []*rpcEndpoint
+	for _, degraded := range []bool{false, true} {
+		for _, endpoint := range c.endpoints {
+			if !tried[endpoint] && endpoint.usable(now, degraded) {
+				candidates = append(candidates, endpoint)
+			}
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+
+	var ready []*rpcEndpoint
+	for _, endpoint := range candidates {
+		if endpoint.limiter == nil || endpoint.limiter.ready() {
+			ready = append(ready, endpoint)
+		}
+	}
+	if len(ready) > 0 {
+		candidates = ready
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		return candidates[0]
+	}
+
+	i := rand.Intn(len(candidates))
+	j := rand.Intn(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+	if candidates[j].score() < candidates[i].score() {
+		return candidates[j]
+	}
+	return candidates[i]
+}
+
+// Pinned returns a view of the client that sends every read to one endpoint, so that a
+// sequence of reads sees a single node's state instead of mixing nodes at different heights.
+// It picks the usable endpoint with the highest head seen by the health checks. Reads through
+// the view are retried on that endpoint only; a later call to Pinned fails over to another.
+func (c *ChainClient) Pinned() *ChainClient {
+	view := *c
+	view.pinned = c.highest()
+	return &view
+}
+
+// highest returns the usable endpoint with the highest known head, preferring the better
+// scored one on a tie, or nil when every endpoint is disabled
+func (c *ChainClient) highest() *rpcEndpoint {
+	now := time.Now()
+
+	for _, degraded := range []bool{false, true} {
+		var best *rpcEndpoint
+		var bestHead uint64
+		for _, endpoint := range c.endpoints {
+			if !endpoint.usable(now, degraded) {
+				continue
+			}
+			endpoint.mu.Lock()
+			head := endpoint.head
+			endpoint.mu.Unlock()
+
+			if best == nil || head > bestHead || (head == bestHead && endpoint.score() < best.score()) {
+				best, bestHead = endpoint, head
+			}
+		}
+		if best != nil {
+			return best
+		}
+	}
+	return nil
+}
+
+// read runs fn on one endpoint, retrying transient errors on other endpoints with backoff.
+// A pinned client retries on its pinned endpoint instead.
+func (c *ChainClient) read(ctx context.Context, method string, fn func(ctx context.Context, client *ethclient.Client) error) error {
+	tried := make(map[*rpcEndpoint]bool)
+	lastErr := errNoEndpoints
+
+	for attempt := 0; attempt <= c.maxRetries; {
+		if attempt > 0 {
+			delay := c.backoff << (attempt - 1)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		endpoint := c.pinned
+		if endpoint == nil {
+			endpoint = c.pick(tried)
+			if endpoint == nil && len(tried) > 0 {
+				// Every endpoint was tried; go around again
+				tried = make(map[*rpcEndpoint]bool)
+				endpoint = c.pick(tried)
+			}
+		}
+		if endpoint == nil {
+			break
+		}
+		tried[endpoint] = true
+
+		err := endpoint.do(ctx, c, fn)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, errNoEndpoints) {
+			if c.pinned != nil {
+				return err
+			}
+			// The endpoint turned out to serve another chain and is now disabled
+			continue
+		}
+		lastErr = err
+		if !isTransientRPCError(ctx, err) {
+			return err
+		}
+		attempt++
+
+		utils.LogDebug("Retrying RPC call on another endpoint", map[string]interface{}{
+			"chain":    c.chainName,
+			"method":   method,
+			"endpoint": endpointHost(endpoint.url),
+			"attempt":  attempt,
+			"error":    err.Error(),
+		})
+	}
+
+	return lastErr
+}
+
+// SendTransaction broadcasts the transaction through every endpoint at once. It succeeds if
+// any endpoint accepts the transaction or already knows it. Otherwise a rejection by a node,
+// such as "nonce too low", is preferred over connection errors.
+func (c *ChainClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	results := make(chan error, len(c.endpoints))
+	for _, endpoint := range c.endpoints {
+		go func(endpoint *rpcEndpoint) {
+			results <- endpoint.do(ctx, c, func(ctx context.Context, client *ethclient.Client) error {
+				return client.SendTransaction(ctx, tx)
+			})
+		}(endpoint)
+	}
+
+	var accepted bool
+	var rejection, transient error
+	for range c.endpoints {
+		err := <-results
+		switch {
+		case err == nil || isAlreadyKnown(err):
+			accepted = true
+		case errors.Is(err, errNoEndpoints):
+		case isTransientRPCError(ctx, err):
+			transient = err
+		case rejection == nil:
+			rejection = err
+		}
+	}
+
+	switch {
+	case accepted:
+		return nil
+	case rejection != nil:
+		return rejection
+	case transient != nil:
+		return transient
+	}
+	return errNoEndpoints
+}
+
+// isAlreadyKnown reports whether a node rejected a broadcast because it already has the transaction
+func isAlreadyKnown(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
+
+// isTransientRPCError reports whether a failed call may succeed on another endpoint or later:
+// timeouts, connection errors, rate limiting and server errors. Errors returned by the node
+// for the request itself are not transient.
+func isTransientRPCError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == 429 || httpErr.StatusCode >= 500
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		// -32005 is the conventional "limit exceeded" code
+		return rpcErr.ErrorCode() == -32005
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr) ||
+		strings.Contains(err.Error(), "failed to connect to RPC endpoint")
+}
+
+// endpointHost returns the host of an RPC URL, leaving out paths that often contain API keys
+func endpointHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "invalid"
+	}
+	return parsed.Host
+}
+
+// checkHealth probes every endpoint's block height, updating latency and failures, and marks
+// endpoints more than endpointMaxLag blocks behind the highest head as lagging
+func (c *ChainClient) checkHealth(ctx context.Context) {
+	heads := make([]uint64, len(c.endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range c.endpoints {
+		wg.Add(1)
+		go func(i int, endpoint *rpcEndpoint) {
+			defer wg.Done()
+			_ = endpoint.do(ctx, c, func(ctx context.Context, client *ethclient.Client) error {
+				var err error
+				heads[i], err = client.BlockNumber(ctx)
+				return err
+			})
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	var highest uint64
+	for _, head := range heads {
+		highest = max(highest, head)
+	}
+	for i, endpoint := range c.endpoints {
+		// Endpoints that did not answer keep their previous state
+		if heads[i] == 0 {
+			continue
+		}
+
+		endpoint.mu.Lock()
+		lagging := heads[i]+endpointMaxLag < highest
+		if lagging && !endpoint.lagging {
+			utils.LogInfo("RPC endpoint is lagging", map[string]interface{}{
+				"chain":    c.chainName,
+				"endpoint": endpointHost(endpoint.url),
+				"head":     heads[i],
+				"highest":  highest,
+			})
+		}
+		endpoint.head = heads[i]
+		endpoint.lagging = lagging
+		endpoint.mu.Unlock()
+	}
+}
+
+// Status reports the health of each endpoint
+func (c *ChainClient) Status() []models.EndpointStatus {
+	now := time.Now()
+	statuses := make([]models.EndpointStatus, 0, len(c.endpoints))
+	for _, endpoint := range c.endpoints {
+		endpoint.mu.Lock()
+		statuses = append(statuses, models.EndpointStatus{
+			Host:      endpointHost(endpoint.url),
+			Healthy:   !endpoint.disabled && !now.Before(endpoint.coolUntil) && !endpoint.lagging,
+			Disabled:  endpoint.disabled,
+			Lagging:   endpoint.lagging,
+			LatencyMs: endpoint.latency.Milliseconds(),
+			Failures:  endpoint.failures,
+			Head:      endpoint.head,
+		})
+		endpoint.mu.Unlock()
+	}
+	return statuses
+}
+
+// Close closes the connections to every endpoint
+func (c *ChainClient) Close() {
+	for _, endpoint := range c.endpoints {
+		endpoint.mu.Lock()
+		if endpoint.client != nil {
+			endpoint.client.Close()
+			endpoint.client = nil
+		}
+		endpoint.mu.Unlock()
+	}
+}
+
+// BalanceAt returns the wei balance of the account at the given block, or the latest block if nil
+func (c *ChainClient) BalanceAt(ctx context.Context, account common.Address, block *big.Int) (*big.Int, error) {
+	var balance *big.Int
+	err := c.read(ctx, "eth_getBalance", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		balance, err = client.BalanceAt(ctx, account, block)
+		return err
+	})
+	return balance, err
+}
+
+// BlockNumber returns the most recent block number
+func (c *ChainClient) BlockNumber(ctx context.Context) (uint64, error) {
+	var head uint64
+	err := c.read(ctx, "eth_blockNumber", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		head, err = client.BlockNumber(ctx)
+		return err
+	})
+	return head, err
+}
+
+// CallContract executes a message call without creating a transaction
+func (c *ChainClient) CallContract(ctx context.Context, msg ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	var output []byte
+	err := c.read(ctx, "eth_call", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		output, err = client.CallContract(ctx, msg, block)
+		return err
+	})
+	return output, err
+}
+
+// EstimateGas estimates the gas needed to execute the message
+func (c *ChainClient) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	var gas uint64
+	err := c.read(ctx, "eth_estimateGas", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		gas, err = client.EstimateGas(ctx, msg)
+		return err
+	})
+	return gas, err
+}
+
+// HeaderByNumber returns the header of the given block, or the latest header if number is nil
+func (c *ChainClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	var header *types.Header
+	err := c.read(ctx, "eth_getBlockByNumber", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		header, err = client.HeaderByNumber(ctx, number)
+		return err
+	})
+	return header, err
+}
+
+// NonceAt returns the account's nonce at the given block, or the latest block if nil
+func (c *ChainClient) NonceAt(ctx context.Context, account common.Address, block *big.Int) (uint64, error) {
+	var nonce uint64
+	err := c.read(ctx, "eth_getTransactionCount", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		nonce, err = client.NonceAt(ctx, account, block)
+		return err
+	})
+	return nonce, err
+}
+
+// PendingNonceAt returns the account's nonce including pending transactions
+func (c *ChainClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	var nonce uint64
+	err := c.read(ctx, "eth_getTransactionCount", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		nonce, err = client.PendingNonceAt(ctx, account)
+		return err
+	})
+	return nonce, err
+}
+
+// SuggestGasPrice returns the node's suggested legacy gas price
+func (c *ChainClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	var price *big.Int
+	err := c.read(ctx, "eth_gasPrice", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		price, err = client.SuggestGasPrice(ctx)
+		return err
+	})
+	return price, err
+}
+
+// SuggestGasTipCap returns the node's suggested EIP-1559 priority fee
+func (c *ChainClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	var tip *big.Int
+	err := c.read(ctx, "eth_maxPriorityFeePerGas", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		tip, err = client.SuggestGasTipCap(ctx)
+		return err
+	})
+	return tip, err
+}
+
+// TransactionReceipt returns the receipt of a mined transaction, or ethereum.NotFound
+func (c *ChainClient) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	var receipt *types.Receipt
+	err := c.read(ctx, "eth_getTransactionReceipt", func(ctx context.Context, client *ethclient.Client) error {
+		var err error
+		receipt, err = client.TransactionReceipt(ctx, hash)
+		return err
+	})
+	return receipt, err
+}
+
+// tokenBucket limits an endpoint to a number of requests per second, allowing bursts of up
+// to one second's worth
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := math.Max(1, rate)
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// refill adds the tokens earned since the last update; the caller holds mu
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// ready reports whether a request could be sent without waiting
+func (b *tokenBucket) ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	return b.tokens >= 1
+}
+
+// wait blocks until a token is available and takes it
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		b.refill(time.Now())
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
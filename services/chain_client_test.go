@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"test-wallet/config"
+	"test-wallet/db"
+	"test-wallet/models"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/rs/zerolog"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const simulatedChainID = 1337
+
+func TestMain(m *testing.M) {
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// simulatedRPC serves the eth methods used by the tracker and the health checks from a
+// simulated backend
+type simulatedRPC struct {
+	client simulated.Client
+}
+
+func (s *simulatedRPC) ChainId(ctx context.Context) (*hexutil.Big, error) {
+	id, err := s.client.ChainID(ctx)
+	return (*hexutil.Big)(id), err
+}
+
+func (s *simulatedRPC) BlockNumber(ctx context.Context) (hexutil.Uint64, error) {
+	head, err := s.client.BlockNumber(ctx)
+	return hexutil.Uint64(head), err
+}
+
+func (s *simulatedRPC) GetTransactionCount(ctx context.Context, account common.Address, block string) (hexutil.Uint64, error) {
+	nonce, err := s.client.NonceAt(ctx, account, nil)
+	return hexutil.Uint64(nonce), err
+}
+
+func (s *simulatedRPC) GetTransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	receipt, err := s.client.TransactionReceipt(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, nil
+	}
+	return receipt, err
+}
+
+// simulatedNode is a simulated chain reachable over HTTP JSON-RPC
+type simulatedNode struct {
+	backend *simulated.Backend
+	server  *httptest.Server
+}
+
+// newSimulatedNode starts a simulated chain funding key and mines blocks empty blocks on it.
+// Nodes created with the same key share their genesis, like nodes of one network.
+func newSimulatedNode(t *testing.T, key *ecdsa.PrivateKey, blocks int) *simulatedNode {
+	t.Helper()
+
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: big.NewInt(1e18)},
+	})
+	t.Cleanup(func() { backend.Close() })
+	for range blocks {
+		backend.Commit()
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &simulatedRPC{client: backend.Client()}); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	return &simulatedNode{backend: backend, server: httpServer}
+}
+
+// head returns the node's latest block number
+func (n *simulatedNode) head(t *testing.T) uint64 {
+	t.Helper()
+	head, err := n.backend.Client().BlockNumber(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return head
+}
+
+// newTrackerDB opens a migrated in-memory database for the tracker
+func newTrackerDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+
+	conn, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// newTestChain creates a chain whose client reads from the nodes' endpoints
+func newTestChain(nodes ...*simulatedNode) (*Chain, *ChainClient) {
+	cfg := config.ChainConfig{ID: simulatedChainID, Name: "simulated"}
+	for _, node := range nodes {
+		cfg.RPCURLs = append(cfg.RPCURLs, node.server.URL)
+	}
+	client := NewChainClient(cfg, config.EthConfig{
+		RPCTimeout:      time.Second,
+		RPCMaxRetries:   2,
+		RPCRetryBackoff: time.Millisecond,
+	})
+	return NewChain(cfg, client), client
+}
+
+func TestChainClientPinnedFailover(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	low := newSimulatedNode(t, key, 1)
+	high := newSimulatedNode(t, key, 3)
+
+	_, client := newTestChain(low, high)
+	defer client.Close()
+	client.checkHealth(ctx)
+
+	// The lag is within endpointMaxLag, so both endpoints stay in rotation, but a pinned
+	// view reads only from the one with the highest head
+	pinned := client.Pinned()
+	for range 20 {
+		head, err := pinned.BlockNumber(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head != high.head(t) {
+			t.Fatalf("pinned read returned head %d, want %d", head, high.head(t))
+		}
+	}
+
+	// A pinned view does not fall back to another endpoint
+	high.server.Close()
+	if _, err := pinned.BlockNumber(ctx); err == nil {
+		t.Fatal("pinned read succeeded on a stopped endpoint")
+	}
+
+	// The failed endpoint cools down and the next view fails over to the remaining one
+	client.checkHealth(ctx)
+	head, err := client.Pinned().BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != low.head(t) {
+		t.Fatalf("failover read returned head %d, want %d", head, low.head(t))
+	}
+}
+
+func TestTxTrackerReadsOneEndpoint(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+
+	// The transaction is mined only on the node ahead; the lagging node neither has its
+	// receipt nor the increased nonce
+	low := newSimulatedNode(t, key, 1)
+	high := newSimulatedNode(t, key, 0)
+	signed, err := types.SignTx(types.NewTransaction(0, common.HexToAddress("0x000000000000000000000000000000000000bEEF"), big.NewInt(1), 21000, big.NewInt(1e10), nil),
+		types.LatestSignerForChainID(big.NewInt(simulatedChainID)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := high.backend.Client().SendTransaction(ctx, signed); err != nil {
+		t.Fatal(err)
+	}
+	high.backend.Commit()
+	high.backend.Commit()
+
+	conn := newTrackerDB(t, "tracker_pinned")
+	chain, client := newTestChain(low, high)
+	defer client.Close()
+	client.checkHealth(ctx)
+	chains, err := NewChainRegistryFrom([]*Chain{chain}, "simulated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker, err := NewTxTracker(conn, chains)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mixing endpoints could see the nonce used on one node and no receipt on the other
+	for i := range 10 {
+		record := models.Transaction{
+			Id:          fmt.Sprintf("tx-%d", i),
+			UserId:      "user-1",
+			ChainId:     simulatedChainID,
+			Hash:        signed.Hash().Hex(),
+			FromAddress: from.Hex(),
+			ToAddress:   "0x000000000000000000000000000000000000bEEF",
+			Value:       "1",
+			TokenSymbol: "ETH",
+			Status:      models.TxStatusPending,
+		}
+		if err := conn.Where("1 = 1").Delete(&models.Transaction{}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := conn.Create(&record).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		tracker.poll(ctx)
+
+		var tracked models.Transaction
+		if err := conn.First(&tracked, "id = ?", record.Id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if tracked.Status != models.TxStatusConfirmed {
+			t.Fatalf("poll %d: status %q, want %q", i, tracked.Status, models.TxStatusConfirmed)
+		}
+	}
+}
+
+// headlessBackend is a chain backend whose head can't be read
+type headlessBackend struct {
+	ChainBackend
+	calls int
+}
+
+func (b *headlessBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	b.calls++
+	return 0, errors.New("endpoint unavailable")
+}
+
+func TestTxTrackerSkipsChainWithoutHead(t *testing.T) {
+	conn := newTrackerDB(t, "tracker_headless")
+	backend := &headlessBackend{}
+	chains, err := NewChainRegistryFrom([]*Chain{
+		NewChain(config.ChainConfig{ID: simulatedChainID, Name: "simulated"}, backend),
+	}, "simulated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker, err := NewTxTracker(conn, chains)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 5 {
+		record := models.Transaction{
+			Id:          fmt.Sprintf("tx-%d", i),
+			UserId:      "user-1",
+			ChainId:     simulatedChainID,
+			Hash:        common.BigToHash(big.NewInt(int64(i + 1))).Hex(),
+			FromAddress: "0x000000000000000000000000000000000000dEaD",
+			ToAddress:   "0x000000000000000000000000000000000000bEEF",
+			Value:       "1",
+			TokenSymbol: "ETH",
+			Status:      models.TxStatusPending,
+		}
+		if err := conn.Create(&record).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The head is tried once per poll, not once per pending transaction
+	tracker.poll(context.Background())
+	if backend.calls != 1 {
+		t.Fatalf("head read %d times in one poll, want 1", backend.calls)
+	}
+	tracker.poll(context.Background())
+	if backend.calls != 2 {
+		t.Fatalf("head read %d times in two polls, want 2", backend.calls)
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"test-wallet/config"
+	"test-wallet/utils"
+	"time"
+)
+
+// ChainHealthMonitor periodically probes every RPC endpoint so that slow, failing or lagging
+// endpoints are noticed before a user request is sent to them
+type ChainHealthMonitor struct {
+	chains   *ChainRegistry
+	interval time.Duration
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewChainHealthMonitor(chains *ChainRegistry) (*ChainHealthMonitor, error) {
+	if chains == nil {
+		return nil, errors.New("chains are not initialized")
+	}
+
+	interval := config.AppConfig.EthConfig.RPCHealthInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	return &ChainHealthMonitor{chains: chains, interval: interval}, nil
+}
+
+// Start runs the probing loop in a goroutine until Stop is called
+func (m *ChainHealthMonitor) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		utils.LogInfo("Chain health monitor started", map[string]interface{}{
+			"interval": m.interval.String(),
+		})
+
+		for {
+			m.chains.checkHealth(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop signals the probing loop to exit and waits for the current probe to finish
+func (m *ChainHealthMonitor) Stop(ctx context.Context) error {
+	if m.cancel == nil {
+		return nil
+	}
+	m.cancel()
+
+	select {
+	case <-m.done:
+		utils.LogInfo("Chain health monitor stopped", nil)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("chain health monitor did not stop: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"test-wallet/config"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrUnsupportedChain is returned for a chain selector that matches no configured chain
+var ErrUnsupportedChain = errors.New("unsupported chain")
+
+// ChainBackend is the subset of an Ethereum client used by the wallet. ChainClient
+// implements it over the configured RPC endpoints; tests use a simulated backend.
+type ChainBackend interface {
+	BalanceAt(ctx context.Context, account common.Address, block *big.Int) (*big.Int, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, block *big.Int) ([]byte, error)
+	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	NonceAt(ctx context.Context, account common.Address, block *big.Int) (uint64, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+}
+
+// Chain is a configured network with its RPC client
+type Chain struct {
+	config.ChainConfig
+	client ChainBackend
+}
+
+// NewChain pairs a chain's configuration with the backend used to reach it
+func NewChain(cfg config.ChainConfig, backend ChainBackend) *Chain {
+	return &Chain{ChainConfig: cfg, client: backend}
+}
+
+// pinned returns a backend that sends every read to one RPC endpoint, for a sequence of reads
+// that must see a single node's view of the chain. Other backends already have a single view.
+func (c *Chain) pinned() ChainBackend {
+	if client, ok := c.client.(*ChainClient); ok {
+		return client.Pinned()
+	}
+	return c.client
+}
+
+// ChainID returns the chain ID as used for signing
+func (c *Chain) ChainID() *big.Int {
+	return new(big.Int).SetUint64(c.ID)
+}
+
+// ChainRegistry holds a client for each configured chain
+type ChainRegistry struct {
+	chains       []*Chain
+	defaultChain *Chain
+}
+
+var chainRegistry *ChainRegistry
+
+// InitChains creates a client for every chain in EthConfig and selects the default chain
+func InitChains() error {
+	registry, err := NewChainRegistry(config.AppConfig.EthConfig.Chains, config.AppConfig.EthConfig.DefaultChain)
+	if err != nil {
+		return err
+	}
+	chainRegistry = registry
+	return nil
+}
+
+// DefaultChainRegistry returns the registry created by InitChains
+func DefaultChainRegistry() *ChainRegistry {
+	return chainRegistry
+}
+
+// NewChainRegistry creates a client for each chain. Endpoints are dialed and their chain ID
+// checked on first use, so one network being down does not block startup.
+func NewChainRegistry(chains []config.ChainConfig, defaultName string) (*ChainRegistry, error) {
+	clients := make([]*Chain, 0, len(chains))
+	for _, cfg := range chains {
+		clients = append(clients, NewChain(cfg, NewChainClient(cfg, config.AppConfig.EthConfig)))
+	}
+	return NewChainRegistryFrom(clients, defaultName)
+}
+
+// NewChainRegistryFrom creates a registry over chains whose backends are already set up
+func NewChainRegistryFrom(chains []*Chain, defaultName string) (*ChainRegistry, error) {
+	registry := &ChainRegistry{chains: chains}
+
+	defaultChain, err := registry.Resolve(defaultName)
+	if err != nil {
+		registry.Close()
+		return nil, fmt.Errorf("default chain %q is not configured", defaultName)
+	}
+	registry.defaultChain = defaultChain
+
+	utils.LogInfo("Chains initialized", map[string]interface{}{
+		"chains":  len(registry.chains),
+		"default": defaultChain.Name,
+	})
+	return registry, nil
+}
+
+// Resolve finds a chain by name or decimal chain ID. An empty selector picks the default chain.
+func (r *ChainRegistry) Resolve(selector string) (*Chain, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" && r.defaultChain != nil {
+		return r.defaultChain, nil
+	}
+
+	if id, err := strconv.ParseUint(selector, 10, 64); err == nil {
+		return r.ByID(id)
+	}
+	for _, chain := range r.chains {
+		if strings.EqualFold(chain.Name, selector) {
+			return chain, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedChain, selector)
+}
+
+// ByID returns the chain with the given chain ID
+func (r *ChainRegistry) ByID(id uint64) (*Chain, error) {
+	for _, chain := range r.chains {
+		if chain.ID == id {
+			return chain, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnsupportedChain, id)
+}
+
+// List returns the configured chains in configuration order
+func (r *ChainRegistry) List() []*Chain {
+	return r.chains
+}
+
+// Close closes every chain's RPC client
+func (r *ChainRegistry) Close() {
+	for _, chain := range r.chains {
+		if client, ok := chain.client.(*ChainClient); ok {
+			client.Close()
+		}
+	}
+}
+
+// checkHealth probes the RPC endpoints of every chain
+func (r *ChainRegistry) checkHealth(ctx context.Context) {
+	for _, chain := range r.chains {
+		if client, ok := chain.client.(*ChainClient); ok {
+			client.checkHealth(ctx)
+		}
+	}
+}
+
+// Health returns the state of every chain's RPC endpoints
+func (r *ChainRegistry) Health() []models.ChainHealth {
+	health := make([]models.ChainHealth, 0, len(r.chains))
+	for _, chain := range r.chains {
+		status := models.ChainHealth{Name: chain.Name, ChainId: chain.ID}
+		if client, ok := chain.client.(*ChainClient); ok {
+			status.Endpoints = client.Status()
+		}
+		health = append(health, status)
+	}
+	return health
+}
+
+// Info returns the configured chains for display
+func (r *ChainRegistry) Info() []models.ChainInfo {
+	chains := make([]models.ChainInfo, 0, len(r.chains))
+	for _, chain := range r.chains {
+		chains = append(chains, models.ChainInfo{
+			ChainId:      chain.ID,
+			Name:         chain.Name,
+			NativeSymbol: chain.NativeSymbol,
+			ExplorerURL:  chain.ExplorerURL,
+			EIP1559:      chain.EIP1559,
+			Default:      chain == r.defaultChain,
+		})
+	}
+	return chains
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidContractCall is returned for an unusable ABI, method, address or arguments
+	ErrInvalidContractCall = errors.New("invalid contract call")
+	// ErrContractAbiNotFound is returned when the user has no stored ABI with the given ID on the chain
+	ErrContractAbiNotFound = errors.New("contract ABI not found")
+	// ErrContractReverted is returned when the contract rejects a call or gas estimate
+	ErrContractReverted = errors.New("contract call reverted")
+)
+
+// contractCall is a resolved method call ready to send
+type contractCall struct {
+	method   abi.Method
+	contract common.Address
+	data     []byte
+}
+
+// prepareContractCall resolves the ABI, contract and method of a request and packs its arguments
+func (s *WalletService) prepareContractCall(userID string, chainID uint64, req *models.ContractCallRequest) (*contractCall, error) {
+	definition := req.Abi
+	address := req.Contract
+	if req.AbiId != "" {
+		stored, err := s.contractRepo.FindAbi(userID, chainID, req.AbiId)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrContractAbiNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		definition = stored.Abi
+		if address == "" {
+			address = stored.Address
+		}
+	}
+	if len(definition) == 0 {
+		return nil, fmt.Errorf("%w: abi or abi_id is required", ErrInvalidContractCall)
+	}
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("%w: invalid contract address %q", ErrInvalidContractCall, address)
+	}
+
+	parsed, err := parseContractABI(definition)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContractCall, err)
+	}
+	method, err := findABIMethod(&parsed, req.Method)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContractCall, err)
+	}
+	data, err := packABIArguments(method, req.Args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContractCall, err)
+	}
+
+	return &contractCall{method: method, contract: common.HexToAddress(address), data: data}, nil
+}
+
+// checkContractFactor enforces the user's TOTP policy on a contract transaction. An ERC-20
+// transfer, approve or transferFrom of a supported token is checked like a token transfer to
+// the decoded recipient or spender; any other call, or one that also sends ETH, can move funds
+// in ways the thresholds can't measure and needs the second factor.
+func (s *WalletService) checkContractFactor(userID string, chainID uint64, call *contractCall, value *big.Int, code string) error {
+	if recipient, amount, ok := decodeERC20Movement(call.data); ok && value.Sign() == 0 {
+		// Unknown tokens have no trusted decimals to compare the amount against
+		if token, err := s.tokenService.ResolveToken(chainID, call.contract.Hex()); err == nil {
+			return s.checkTransferFactor(userID, chainID, recipient, amount, token.Decimals, false, code)
+		}
+	}
+	return s.checkFactor(userID, code)
+}
+
+// revertError turns a node error carrying revert data into ErrContractReverted with the decoded reason
+func revertError(err error) error {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return err
+	}
+	data, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err
+	}
+
+	if raw, decodeErr := hexutil.Decode(data); decodeErr == nil {
+		if reason, unpackErr := abi.UnpackRevert(raw); unpackErr == nil {
+			return fmt.Errorf("%w: %s", ErrContractReverted, reason)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrContractReverted, data)
+}
+
+// CallContract runs a read-only call of a contract method from one of the user's accounts
+// and decodes its outputs
+func (s *WalletService) CallContract(ctx context.Context, userID string, req *models.ContractCallRequest) (*models.ContractCallResponse, error) {
+	chain, err := s.chains.Resolve(req.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	call, err := s.prepareContractCall(userID, chain.ID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindUserByID(userID)
+	if err != nil {
+		utils.LogError(err, "Failed to get user wallet", map[string]interface{}{
+			"user_id": userID,
+		})
+		return nil, fmt.Errorf("failed to get user wallet: %w", err)
+	}
+	account, err := s.findAccount(user, req.FromAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := chain.client.CallContract(ctx, ethereum.CallMsg{
+		From: common.HexToAddress(account.Address),
+		To:   &call.contract,
+		Data: call.data,
+	}, nil)
+	if err != nil {
+		utils.LogError(err, "Contract call failed", map[string]interface{}{
+			"contract": call.contract.Hex(),
+			"method":   call.method.Sig,
+		})
+		return nil, revertError(err)
+	}
+
+	values, err := call.method.Outputs.Unpack(output)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode outputs: %v", ErrInvalidContractCall, err)
+	}
+
+	outputs := make([]models.ContractValue, len(values))
+	for i, value := range values {
+		argument := call.method.Outputs[i]
+		outputs[i] = models.ContractValue{
+			Name:  argument.Name,
+			Type:  argument.Type.String(),
+			Value: abiValueToJSON(argument.Type, value),
+		}
+	}
+
+	return &models.ContractCallResponse{Method: call.method.Sig, Outputs: outputs}, nil
+}
+
+// TransactContract signs and sends a transaction calling a contract method. The transaction
+// is recorded in the history like any transfer, with the contract as recipient.
+func (s *WalletService) TransactContract(userID string, req *models.ContractTransactRequest) (*models.ContractTransactResponse, error) {
+	chain, err := s.chains.Resolve(req.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	call, err := s.prepareContractCall(userID, chain.ID, &req.ContractCallRequest)
+	if err != nil {
+		return nil, err
+	}
+	if call.method.IsConstant() {
+		return nil, fmt.Errorf("%w: %s is read-only, use contract/call", ErrInvalidContractCall, call.method.Sig)
+	}
+
+	value := big.NewInt(0)
+	if req.Value != "" {
+		value, err = parseAmount(req.Value, 18)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidContractCall, err)
+		}
+	}
+	if value.Sign() > 0 && !call.method.IsPayable() {
+		return nil, fmt.Errorf("%w: %s is not payable", ErrInvalidContractCall, call.method.Sig)
+	}
+
+	// Unlock the sending account with the user's PIN
+	account, privKey, err := s.unlockAccount(userID, req.Pin, req.FromAccount)
+	if err != nil {
+		return nil, err
+	}
+	fromAddress := common.HexToAddress(account.Address)
+
+	// Ask for the second factor when the user's policy requires it
+	if err := s.checkContractFactor(userID, chain.ID, call, value, req.TotpCode); err != nil {
+		return nil, err
+	}
+
+	feeTier, err := parseFeeTier(req.FeeTier)
+	if err != nil {
+		return nil, err
+	}
+
+	// Price the transaction for the requested fee tier
+	fees, err := suggestFees(context.Background(), chain, feeTier)
+	if err != nil {
+		utils.LogError(err, "Failed to get transaction fees", nil)
+		return nil, err
+	}
+
+	msg := ethereum.CallMsg{
+		From:  fromAddress,
+		To:    &call.contract,
+		Value: value,
+		Data:  call.data,
+	}
+	fees.applyTo(&msg)
+
+	gasLimit, err := chain.client.EstimateGas(context.Background(), msg)
+	if err != nil {
+		utils.LogError(err, "Failed to estimate gas", map[string]interface{}{
+			"contract": call.contract.Hex(),
+			"method":   call.method.Sig,
+		})
+		return nil, revertError(err)
+	}
+
+	signedTx, err := s.signAndSend(context.Background(), &outgoingTx{
+		userID:      userID,
+		key:         privKey,
+		from:        fromAddress,
+		chain:       chain,
+		to:          call.contract,
+		value:       value,
+		gasLimit:    gasLimit,
+		fees:        fees,
+		data:        call.data,
+		recipient:   call.contract,
+		amount:      value,
+		tokenSymbol: chain.NativeSymbol,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo("Contract transaction sent", map[string]interface{}{
+		"chain":    chain.Name,
+		"from":     fromAddress.Hex(),
+		"contract": call.contract.Hex(),
+		"method":   call.method.Sig,
+		"tx_hash":  signedTx.Hash().Hex(),
+	})
+
+	return &models.ContractTransactResponse{
+		Method:          call.method.Sig,
+		TransactionHash: signedTx.Hash().Hex(),
+	}, nil
+}
+
+// SaveContractAbi stores an ABI for the selected chain under a name
+func (s *WalletService) SaveContractAbi(userID string, req *models.SaveContractAbiRequest) (*models.ContractAbi, error) {
+	if _, err := parseContractABI(req.Abi); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidContractCall, err)
+	}
+	if req.Address != "" && !common.IsHexAddress(req.Address) {
+		return nil, fmt.Errorf("%w: invalid contract address %q", ErrInvalidContractCall, req.Address)
+	}
+
+	chain, err := s.chains.Resolve(req.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	contractAbi := &models.ContractAbi{
+		Id:      uuid.New().String(),
+		UserId:  userID,
+		ChainId: chain.ID,
+		Name:    req.Name,
+		Abi:     req.Abi,
+	}
+	if req.Address != "" {
+		contractAbi.Address = common.HexToAddress(req.Address).Hex()
+	}
+	if err := s.contractRepo.SaveAbi(contractAbi); err != nil {
+		return nil, err
+	}
+
+	return contractAbi, nil
+}
+
+// ListContractAbis returns the user's stored ABIs for the selected chain
+func (s *WalletService) ListContractAbis(userID, chainSelector string) ([]models.ContractAbi, error) {
+	chain, err := s.chains.Resolve(chainSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.contractRepo.ListAbis(userID, chain.ID)
+}
+
+// DeleteContractAbi removes one of the user's stored ABIs for the selected chain
+func (s *WalletService) DeleteContractAbi(userID, id, chainSelector string) error {
+	chain, err := s.chains.Resolve(chainSelector)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := s.contractRepo.DeleteAbi(userID, chain.ID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrContractAbiNotFound
+	}
+	return nil
+}
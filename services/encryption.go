@@ -5,12 +5,59 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/scrypt"
 )
 
+// Ciphertexts are stored as envelopePrefix followed by the base64 encoding of
+//
+//	version (1) | kdf (1) | params length (1) | params | salt length (1) | salt | nonce (12) | ciphertext
+//
+// Everything before the nonce is authenticated as GCM additional data. Values without the
+// prefix are legacy blobs: base64(nonce | ciphertext) keyed by scrypt with an empty salt.
+// "$" is outside the base64 alphabet, so the two formats cannot be confused.
+const envelopePrefix = "$wenc$"
+
+const envelopeVersion1 byte = 1
+
+// Key derivation functions recorded in the envelope
+const (
+	kdfScrypt   byte = 1
+	kdfArgon2id byte = 2
+)
+
+const (
+	envelopeSaltLen = 16
+	envelopeKeyLen  = 32 // AES-256
+)
+
+// argon2idParams are the Argon2id cost parameters
+type argon2idParams struct {
+	Time    uint32 // Iterations
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+// currentArgon2id is used for every new ciphertext. Raising it makes existing
+// ciphertexts report NeedsReencrypt, so they are upgraded on the next PIN entry.
+var currentArgon2id = argon2idParams{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// envelope is a parsed versioned ciphertext
+type envelope struct {
+	header     []byte // version through salt, used as additional data
+	kdf        byte
+	params     []byte
+	salt       []byte
+	nonce      []byte
+	ciphertext []byte
+}
+
 // GenerateSalt generates a random salt of the specified length.
 func GenerateSalt(length int) (string, error) {
 	saltBytes := make([]byte, length)
@@ -23,6 +70,7 @@ func GenerateSalt(length int) (string, error) {
 }
 
 // DeriveKey derives a suitable encryption key from the user's PIN and salt using scrypt.
+// It is kept for decrypting legacy ciphertexts, which were written with an empty salt.
 func DeriveKey(pin, salt string) ([]byte, error) {
 	N := 16384   // CPU cost parameter
 	r := 8       // Memory cost parameter
@@ -37,20 +85,84 @@ func DeriveKey(pin, salt string) ([]byte, error) {
 	return key, nil
 }
 
-// Encrypt encrypts the plaintext using the provided PIN and salt.
-func Encrypt(pin, plaintext string) (string, error) {
-	// Derive the encryption key from the PIN and salt.
-	key, err := DeriveKey(pin, "") // Pass empty salt, the salt is already in the pin.
-	if err != nil {
-		return "", err
+// encodeArgon2idParams serializes Argon2id parameters for the envelope
+func encodeArgon2idParams(p argon2idParams) []byte {
+	params := make([]byte, 9)
+	binary.BigEndian.PutUint32(params[0:4], p.Time)
+	binary.BigEndian.PutUint32(params[4:8], p.Memory)
+	params[8] = p.Threads
+	return params
+}
+
+// decodeArgon2idParams parses Argon2id parameters from the envelope
+func decodeArgon2idParams(params []byte) (argon2idParams, error) {
+	if len(params) != 9 {
+		return argon2idParams{}, errors.New("invalid argon2id parameters")
+	}
+	p := argon2idParams{
+		Time:    binary.BigEndian.Uint32(params[0:4]),
+		Memory:  binary.BigEndian.Uint32(params[4:8]),
+		Threads: params[8],
+	}
+	if p.Time == 0 || p.Memory == 0 || p.Threads == 0 {
+		return argon2idParams{}, errors.New("invalid argon2id parameters")
 	}
+	return p, nil
+}
 
+// deriveEnvelopeKey derives the AES key for an envelope from the PIN
+func deriveEnvelopeKey(pin string, kdf byte, params, salt []byte) ([]byte, error) {
+	switch kdf {
+	case kdfArgon2id:
+		p, err := decodeArgon2idParams(params)
+		if err != nil {
+			return nil, err
+		}
+		return argon2.IDKey([]byte(pin), salt, p.Time, p.Memory, p.Threads, envelopeKeyLen), nil
+	case kdfScrypt:
+		if len(params) != 9 {
+			return nil, errors.New("invalid scrypt parameters")
+		}
+		n := int(binary.BigEndian.Uint32(params[0:4]))
+		r := int(binary.BigEndian.Uint32(params[4:8]))
+		key, err := scrypt.Key([]byte(pin), salt, n, r, int(params[8]), envelopeKeyLen)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function %d", kdf)
+	}
+}
+
+// newGCM returns AES-GCM keyed with key
+func newGCM(key []byte) (cipher.AEAD, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return cipher.NewGCM(block)
+}
 
-	aesGCM, err := cipher.NewGCM(block)
+// Encrypt encrypts the plaintext under a key derived from the PIN with Argon2id and a
+// random per-record salt, and returns a versioned envelope.
+func Encrypt(pin, plaintext string) (string, error) {
+	salt := make([]byte, envelopeSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	params := encodeArgon2idParams(currentArgon2id)
+	header := []byte{envelopeVersion1, kdfArgon2id, byte(len(params))}
+	header = append(header, params...)
+	header = append(header, byte(len(salt)))
+	header = append(header, salt...)
+
+	key, err := deriveEnvelopeKey(pin, kdfArgon2id, params, salt)
+	if err != nil {
+		return "", err
+	}
+	aesGCM, err := newGCM(key)
 	if err != nil {
 		return "", err
 	}
@@ -60,24 +172,82 @@ func Encrypt(pin, plaintext string) (string, error) {
 		return "", err
 	}
 
-	ciphertext := aesGCM.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	sealed := append(header, nonce...)
+	sealed = aesGCM.Seal(sealed, nonce, []byte(plaintext), header)
+	return envelopePrefix + base64.StdEncoding.EncodeToString(sealed), nil
 }
 
-// Decrypt decrypts the ciphertext using the provided PIN and salt.
+// Decrypt decrypts a versioned envelope, or a legacy ciphertext, using the provided PIN.
 func Decrypt(pin, ciphertext string) (string, error) {
-	// Derive the decryption key from the PIN and salt.
-	key, err := DeriveKey(pin, "") // Pass empty salt, the salt is already in the pin.
+	if !strings.HasPrefix(ciphertext, envelopePrefix) {
+		return decryptLegacy(pin, ciphertext)
+	}
+
+	env, err := parseEnvelope(ciphertext)
 	if err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(key)
+	key, err := deriveEnvelopeKey(pin, env.kdf, env.params, env.salt)
+	if err != nil {
+		return "", err
+	}
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	plaintextBytes, err := aesGCM.Open(nil, env.nonce, env.ciphertext, env.header)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintextBytes), nil
+}
+
+// parseEnvelope splits a versioned ciphertext into its fields
+func parseEnvelope(ciphertext string) (*envelope, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, envelopePrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) < 3 {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	if raw[0] != envelopeVersion1 {
+		return nil, fmt.Errorf("unsupported ciphertext version %d", raw[0])
+	}
+
+	env := &envelope{kdf: raw[1]}
+	offset := 3
+	paramsEnd := offset + int(raw[2])
+	if len(raw) < paramsEnd+1 {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	env.params = raw[offset:paramsEnd]
+
+	saltEnd := paramsEnd + 1 + int(raw[paramsEnd])
+	const nonceSize = 12
+	if len(raw) < saltEnd+nonceSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	env.salt = raw[paramsEnd+1 : saltEnd]
+	env.header = raw[:saltEnd]
+	env.nonce = raw[saltEnd : saltEnd+nonceSize]
+	env.ciphertext = raw[saltEnd+nonceSize:]
+
+	return env, nil
+}
+
+// decryptLegacy decrypts base64(nonce | ciphertext) keyed by scrypt with an empty salt
+func decryptLegacy(pin, ciphertext string) (string, error) {
+	key, err := DeriveKey(pin, "")
 	if err != nil {
 		return "", err
 	}
 
-	aesGCM, err := cipher.NewGCM(block)
+	aesGCM, err := newGCM(key)
 	if err != nil {
 		return "", err
 	}
@@ -101,3 +271,22 @@ func Decrypt(pin, ciphertext string) (string, error) {
 
 	return string(plaintextBytes), nil
 }
+
+// NeedsReencrypt reports whether a ciphertext uses the legacy format, an older KDF or
+// weaker Argon2id parameters than currentArgon2id, and should be re-encrypted
+func NeedsReencrypt(ciphertext string) bool {
+	if !strings.HasPrefix(ciphertext, envelopePrefix) {
+		return true
+	}
+
+	env, err := parseEnvelope(ciphertext)
+	if err != nil || env.kdf != kdfArgon2id {
+		return true
+	}
+
+	p, err := decodeArgon2idParams(env.params)
+	if err != nil {
+		return true
+	}
+	return p.Time < currentArgon2id.Time || p.Memory < currentArgon2id.Memory || p.Threads < currentArgon2id.Threads
+}
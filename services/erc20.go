@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// erc20ABIJSON covers the subset of the ERC-20 interface used by the wallet
+const erc20ABIJSON = `[
+	{"constant":false,"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transfer","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},
+	{"constant":false,"inputs":[{"name":"spender","type":"address"},{"name":"value","type":"uint256"}],"name":"approve","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},
+	{"constant":false,"inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transferFrom","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"},
+	{"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"payable":false,"stateMutability":"view","type":"function"}
+]`
+
+var erc20ABI = mustParseABI(erc20ABIJSON)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("invalid ABI definition: %v", err))
+	}
+	return parsed
+}
+
+// callERC20 performs a read-only call of an ERC-20 method and unpacks its outputs
+func callERC20(ctx context.Context, client ChainBackend, token common.Address, method string, args ...interface{}) ([]interface{}, error) {
+	data, err := erc20ABI.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s call: %w", method, err)
+	}
+
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", method, err)
+	}
+
+	values, err := erc20ABI.Unpack(method, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s result: %w", method, err)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("empty %s result", method)
+	}
+
+	return values, nil
+}
+
+// erc20Decimals reads the token's decimals() from the chain
+func erc20Decimals(ctx context.Context, client ChainBackend, token common.Address) (uint8, error) {
+	values, err := callERC20(ctx, client, token, "decimals")
+	if err != nil {
+		return 0, err
+	}
+
+	decimals, ok := values[0].(uint8)
+	if !ok {
+		return 0, fmt.Errorf("unexpected decimals type %T", values[0])
+	}
+	return decimals, nil
+}
+
+// erc20BalanceOf reads the owner's token balance in the smallest unit
+func erc20BalanceOf(ctx context.Context, client ChainBackend, token, owner common.Address) (*big.Int, error) {
+	values, err := callERC20(ctx, client, token, "balanceOf", owner)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, ok := values[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected balance type %T", values[0])
+	}
+	return balance, nil
+}
+
+// decodeERC20Movement decodes call data of an ERC-20 transfer, approve or transferFrom by its
+// selector and returns the address that receives the tokens, or may spend them, and the amount
+func decodeERC20Movement(data []byte) (common.Address, *big.Int, bool) {
+	if len(data) < 4 {
+		return common.Address{}, nil, false
+	}
+	method, err := erc20ABI.MethodById(data[:4])
+	if err != nil {
+		return common.Address{}, nil, false
+	}
+
+	var recipient string
+	switch method.Name {
+	case "transfer", "transferFrom":
+		recipient = "to"
+	case "approve":
+		recipient = "spender"
+	default:
+		return common.Address{}, nil, false
+	}
+
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, data[4:]); err != nil {
+		return common.Address{}, nil, false
+	}
+	to, ok := args[recipient].(common.Address)
+	if !ok {
+		return common.Address{}, nil, false
+	}
+	amount, ok := args["value"].(*big.Int)
+	if !ok {
+		return common.Address{}, nil, false
+	}
+	return to, amount, true
+}
+
+// parseAmount converts a decimal string such as "1.5" into the smallest unit for the given decimals
+func parseAmount(amount string, decimals uint8) (*big.Int, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	value.Mul(value, new(big.Rat).SetInt(scale))
+	if !value.IsInt() {
+		return nil, fmt.Errorf("amount %q has more than %d decimal places", amount, decimals)
+	}
+
+	return new(big.Int).Set(value.Num()), nil
+}
+
+// formatAmount converts a value in the smallest unit into a decimal string
+func formatAmount(value *big.Int, decimals uint8) string {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	formatted := new(big.Rat).SetFrac(value, scale).FloatString(int(decimals))
+	if strings.Contains(formatted, ".") {
+		formatted = strings.TrimSuffix(strings.TrimRight(formatted, "0"), ".")
+	}
+	return formatted
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"test-wallet/models"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// FeeTier selects how aggressively a transaction is priced
+type FeeTier string
+
+const (
+	FeeTierSlow   FeeTier = "slow"
+	FeeTierNormal FeeTier = "normal"
+	FeeTierFast   FeeTier = "fast"
+)
+
+// feeTierPercents holds the percentages applied to the node's suggestions for each tier
+var feeTierPercents = map[FeeTier]struct {
+	tip      int64 // applied to SuggestGasTipCap
+	baseFee  int64 // applied to the latest base fee when computing maxFeePerGas
+	gasPrice int64 // applied to SuggestGasPrice in legacy mode
+}{
+	FeeTierSlow:   {tip: 100, baseFee: 125, gasPrice: 100},
+	FeeTierNormal: {tip: 110, baseFee: 200, gasPrice: 120},
+	FeeTierFast:   {tip: 150, baseFee: 300, gasPrice: 150},
+}
+
+// txFees holds either a legacy gas price or EIP-1559 fee caps
+type txFees struct {
+	GasPrice  *big.Int // Set only for legacy transactions
+	GasTipCap *big.Int // maxPriorityFeePerGas
+	GasFeeCap *big.Int // maxFeePerGas
+}
+
+// parseFeeTier converts the request value into a FeeTier, defaulting to normal
+func parseFeeTier(tier string) (FeeTier, error) {
+	if tier == "" {
+		return FeeTierNormal, nil
+	}
+	if _, ok := feeTierPercents[FeeTier(tier)]; !ok {
+		return "", fmt.Errorf("unknown fee tier %q", tier)
+	}
+	return FeeTier(tier), nil
+}
+
+// percentOf returns value * percent / 100
+func percentOf(value *big.Int, percent int64) *big.Int {
+	result := new(big.Int).Mul(value, big.NewInt(percent))
+	return result.Div(result, big.NewInt(100))
+}
+
+// suggestFees prices a transaction on the chain for the given tier. EIP-1559 fees are used
+// unless the chain is configured without them or the latest header has no base fee (pre-London).
+func suggestFees(ctx context.Context, chain *Chain, tier FeeTier) (*txFees, error) {
+	percents := feeTierPercents[tier]
+	client := chain.client
+
+	if chain.EIP1559 {
+		head, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest header: %w", err)
+		}
+
+		if head.BaseFee != nil {
+			tip, err := client.SuggestGasTipCap(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get gas tip cap: %w", err)
+			}
+			tip = percentOf(tip, percents.tip)
+			feeCap := new(big.Int).Add(percentOf(head.BaseFee, percents.baseFee), tip)
+
+			return &txFees{GasTipCap: tip, GasFeeCap: feeCap}, nil
+		}
+	}
+
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price: %w", err)
+	}
+
+	return &txFees{GasPrice: percentOf(gasPrice, percents.gasPrice)}, nil
+}
+
+// applyTo sets the fee fields of a call message used for gas estimation
+func (f *txFees) applyTo(msg *ethereum.CallMsg) {
+	if f.GasPrice != nil {
+		msg.GasPrice = f.GasPrice
+		return
+	}
+	msg.GasTipCap = f.GasTipCap
+	msg.GasFeeCap = f.GasFeeCap
+}
+
+// newTransaction builds a type-2 transaction, or a legacy one when the fees carry a gas price
+func newTransaction(chainID *big.Int, nonce uint64, to common.Address, value *big.Int, gasLimit uint64, fees *txFees, data []byte) *types.Transaction {
+	if fees.GasPrice != nil {
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			To:       &to,
+			Value:    value,
+			Gas:      gasLimit,
+			GasPrice: fees.GasPrice,
+			Data:     data,
+		})
+	}
+
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		To:        &to,
+		Value:     value,
+		Gas:       gasLimit,
+		GasTipCap: fees.GasTipCap,
+		GasFeeCap: fees.GasFeeCap,
+		Data:      data,
+	})
+}
+
+// replacementBumpPercent is the minimum fee increase nodes accept for a same-nonce replacement
+// (geth requires 10%); a little extra avoids rounding down to exactly the threshold
+const replacementBumpPercent = 112
+
+// replacementFees raises the suggested fees so that every field is at least
+// replacementBumpPercent of the corresponding field of the original transaction
+func replacementFees(original *models.Transaction, suggested *txFees) *txFees {
+	// A legacy gas price counts as both the tip and the fee cap
+	originalTip, _ := new(big.Int).SetString(original.MaxPriorityFeePerGas, 10)
+	originalCap, _ := new(big.Int).SetString(original.MaxFeePerGas, 10)
+	if original.GasPrice != "" {
+		originalTip, _ = new(big.Int).SetString(original.GasPrice, 10)
+		originalCap = originalTip
+	}
+	if originalTip == nil || originalCap == nil {
+		return suggested
+	}
+
+	bump := func(suggestedValue, originalValue *big.Int) *big.Int {
+		minimum := percentOf(originalValue, replacementBumpPercent)
+		if suggestedValue.Cmp(minimum) < 0 {
+			return minimum
+		}
+		return suggestedValue
+	}
+
+	if suggested.GasPrice != nil {
+		return &txFees{GasPrice: bump(suggested.GasPrice, originalCap)}
+	}
+
+	fees := &txFees{
+		GasTipCap: bump(suggested.GasTipCap, originalTip),
+		GasFeeCap: bump(suggested.GasFeeCap, originalCap),
+	}
+	if fees.GasFeeCap.Cmp(fees.GasTipCap) < 0 {
+		fees.GasFeeCap = new(big.Int).Set(fees.GasTipCap)
+	}
+	return fees
+}
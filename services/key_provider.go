@@ -0,0 +1,258 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"test-wallet/config"
+	"test-wallet/models"
+)
+
+// KeyProvider wraps and unwraps per-wallet data keys with master keys it never exposes
+type KeyProvider interface {
+	// KeyID returns the ID of the master key used by Wrap
+	KeyID() string
+	// Wrap encrypts a data key with the current master key
+	Wrap(dataKey []byte) ([]byte, error)
+	// Unwrap decrypts a data key wrapped by the master key with the given ID
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// dataKeyLen is the size of per-wallet data keys (AES-256)
+const dataKeyLen = 32
+
+var keyProvider KeyProvider
+
+// InitKeyProvider creates the configured KeyProvider. It must run before any wallet is
+// encrypted or decrypted.
+func InitKeyProvider() error {
+	cfg := config.AppConfig.KeyConfig
+
+	var err error
+	switch cfg.Provider {
+	case "", "local":
+		keyProvider, err = newLocalKeyProvider(cfg)
+	case "pkcs11":
+		keyProvider, err = newPKCS11KeyProvider(cfg)
+	default:
+		err = fmt.Errorf("unknown key provider %q", cfg.Provider)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to initialize key provider: %w", err)
+	}
+	return nil
+}
+
+// localKeyProvider keeps master keys in memory, loaded from the environment or a key file
+type localKeyProvider struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+func newLocalKeyProvider(cfg config.KeyConfig) (KeyProvider, error) {
+	p := &localKeyProvider{currentID: cfg.MasterKeyID, keys: make(map[string][]byte)}
+
+	entries := strings.Split(cfg.MasterKeys, ",")
+	if cfg.MasterKeyFile != "" {
+		content, err := os.ReadFile(cfg.MasterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+		entries = append(entries, strings.Split(string(content), "\n")...)
+	}
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, errors.New("master keys must be ID:BASE64KEY pairs")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != dataKeyLen {
+			return nil, fmt.Errorf("master key %q must be %d base64-encoded bytes", id, dataKeyLen)
+		}
+		p.keys[id] = key
+	}
+
+	if _, ok := p.keys[p.currentID]; !ok {
+		return nil, fmt.Errorf("master key %q is not configured", p.currentID)
+	}
+	return p, nil
+}
+
+func (p *localKeyProvider) KeyID() string {
+	return p.currentID
+}
+
+// Wrap seals the data key with AES-GCM under the current master key, bound to its ID
+func (p *localKeyProvider) Wrap(dataKey []byte) ([]byte, error) {
+	aesGCM, err := newGCM(p.keys[p.currentID])
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aesGCM.Seal(nonce, nonce, dataKey, []byte(p.currentID)), nil
+}
+
+func (p *localKeyProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %q is not configured", keyID)
+	}
+
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aesGCM.NonceSize() {
+		return nil, fmt.Errorf("wrapped key too short")
+	}
+
+	nonce, sealed := wrapped[:aesGCM.NonceSize()], wrapped[aesGCM.NonceSize():]
+	return aesGCM.Open(nil, nonce, sealed, []byte(keyID))
+}
+
+// sealWallet encrypts the secret under the PIN, then encrypts that ciphertext under a fresh
+// data key wrapped by the master key. wallet.Id must be set; the ciphertext is bound to it.
+func sealWallet(wallet *models.Wallet, pin, plaintext string) error {
+	inner, err := Encrypt(pin, plaintext)
+	if err != nil {
+		return err
+	}
+	return wrapWallet(wallet, inner)
+}
+
+// wrapWallet encrypts a PIN ciphertext under a fresh data key and stores it on the wallet
+func wrapWallet(wallet *models.Wallet, inner string) error {
+	outer, wrapped, err := sealWithDataKey([]byte(inner), []byte(wallet.Id))
+	if err != nil {
+		return err
+	}
+
+	wallet.Mnemonic = base64.StdEncoding.EncodeToString(outer)
+	wallet.DataKey = base64.StdEncoding.EncodeToString(wrapped)
+	wallet.KeyId = keyProvider.KeyID()
+	return nil
+}
+
+// openWallet reverses sealWallet and returns the plaintext secret
+func openWallet(wallet *models.Wallet, pin string) (string, error) {
+	inner, err := pinCiphertext(wallet)
+	if err != nil {
+		return "", err
+	}
+	return Decrypt(pin, inner)
+}
+
+// pinCiphertext removes the data key layer and returns the PIN-encrypted secret. Wallets
+// without a data key predate envelope encryption and hold the PIN ciphertext directly.
+func pinCiphertext(wallet *models.Wallet) (string, error) {
+	if wallet.DataKey == "" {
+		return wallet.Mnemonic, nil
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(wallet.DataKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid data key: %w", err)
+	}
+	outer, err := base64.StdEncoding.DecodeString(wallet.Mnemonic)
+	if err != nil {
+		return "", err
+	}
+
+	inner, err := openWithDataKey(wallet.KeyId, wrapped, outer, []byte(wallet.Id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt wallet: %w", err)
+	}
+	return string(inner), nil
+}
+
+// rewrapWallet moves the wallet's data key to the current master key without touching
+// the PIN ciphertext. Wallets without a data key get one.
+func rewrapWallet(wallet *models.Wallet) error {
+	if wallet.DataKey == "" {
+		return wrapWallet(wallet, wallet.Mnemonic)
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(wallet.DataKey)
+	if err != nil {
+		return fmt.Errorf("invalid data key: %w", err)
+	}
+
+	rewrapped, err := rewrapDataKey(wallet.KeyId, wrapped)
+	if err != nil {
+		return err
+	}
+	wallet.DataKey = base64.StdEncoding.EncodeToString(rewrapped)
+	wallet.KeyId = keyProvider.KeyID()
+	return nil
+}
+
+// sealWithDataKey encrypts plaintext with AES-GCM under a fresh data key, bound to aad, and
+// wraps the data key with the current master key
+func sealWithDataKey(plaintext, aad []byte) (sealed, wrapped []byte, err error) {
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	aesGCM, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	sealed = aesGCM.Seal(nonce, nonce, plaintext, aad)
+
+	wrapped, err = keyProvider.Wrap(dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return sealed, wrapped, nil
+}
+
+// openWithDataKey unwraps the data key with the master key keyID and decrypts sealed
+func openWithDataKey(keyID string, wrapped, sealed, aad []byte) ([]byte, error) {
+	dataKey, err := keyProvider.Unwrap(keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	aesGCM, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aesGCM.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := sealed[:aesGCM.NonceSize()], sealed[aesGCM.NonceSize():]
+	return aesGCM.Open(nil, nonce, ciphertext, aad)
+}
+
+// rewrapDataKey moves a wrapped data key from the master key keyID to the current one
+func rewrapDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	dataKey, err := keyProvider.Unwrap(keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	rewrapped, err := keyProvider.Wrap(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return rewrapped, nil
+}
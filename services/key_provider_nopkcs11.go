@@ -0,0 +1,13 @@
+//go:build !pkcs11
+
+package services
+
+import (
+	"errors"
+	"test-wallet/config"
+)
+
+// newPKCS11KeyProvider is unavailable unless the binary is built with -tags pkcs11
+func newPKCS11KeyProvider(config.KeyConfig) (KeyProvider, error) {
+	return nil, errors.New("PKCS#11 support is not compiled in; build with -tags pkcs11")
+}
@@ -0,0 +1,166 @@
+//go:build pkcs11
+
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"test-wallet/config"
+
+	"github.com/miekg/pkcs11"
+)
+
+// pkcs11GCMTagBits is the AES-GCM tag length used for wrapping
+const pkcs11GCMTagBits = 128
+
+// pkcs11KeyProvider wraps data keys with AES secret keys held in a PKCS#11 token, such as
+// SoftHSM or a network HSM. Master keys are looked up by CKA_LABEL, which is the key ID.
+type pkcs11KeyProvider struct {
+	mu        sync.Mutex
+	ctx       *pkcs11.Ctx
+	session   pkcs11.SessionHandle
+	currentID string
+	handles   map[string]pkcs11.ObjectHandle
+}
+
+func newPKCS11KeyProvider(cfg config.KeyConfig) (KeyProvider, error) {
+	ctx := pkcs11.New(cfg.PKCS11Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %q", cfg.PKCS11Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %w", err)
+	}
+
+	slot, err := findPKCS11Slot(ctx, cfg.PKCS11TokenLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PKCS#11 session: %w", err)
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, cfg.PKCS11Pin); err != nil {
+		return nil, fmt.Errorf("failed to log in to PKCS#11 token: %w", err)
+	}
+
+	p := &pkcs11KeyProvider{
+		ctx:       ctx,
+		session:   session,
+		currentID: cfg.MasterKeyID,
+		handles:   make(map[string]pkcs11.ObjectHandle),
+	}
+	if _, err := p.findKey(p.currentID); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// findPKCS11Slot returns the slot holding the token with the given label
+func findPKCS11Slot(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list PKCS#11 slots: %w", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err == nil && info.Label == label {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("PKCS#11 token %q not found", label)
+}
+
+// findKey returns the handle of the AES key labelled keyID. Callers other than the
+// constructor must hold p.mu.
+func (p *pkcs11KeyProvider) findKey(keyID string) (pkcs11.ObjectHandle, error) {
+	if handle, ok := p.handles[keyID]; ok {
+		return handle, nil
+	}
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyID),
+	}
+	if err := p.ctx.FindObjectsInit(p.session, template); err != nil {
+		return 0, fmt.Errorf("failed to search PKCS#11 keys: %w", err)
+	}
+	handles, _, err := p.ctx.FindObjects(p.session, 1)
+	if finalErr := p.ctx.FindObjectsFinal(p.session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to search PKCS#11 keys: %w", err)
+	}
+	if len(handles) == 0 {
+		return 0, fmt.Errorf("master key %q not found in PKCS#11 token", keyID)
+	}
+
+	p.handles[keyID] = handles[0]
+	return handles[0], nil
+}
+
+func (p *pkcs11KeyProvider) KeyID() string {
+	return p.currentID
+}
+
+// Wrap encrypts the data key inside the token with CKM_AES_GCM, bound to the key ID
+func (p *pkcs11KeyProvider) Wrap(dataKey []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	handle, err := p.findKey(p.currentID)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, 12)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+	params := pkcs11.NewGCMParams(iv, []byte(p.currentID), pkcs11GCMTagBits)
+	defer params.Free()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}
+	if err := p.ctx.EncryptInit(p.session, mechanism, handle); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 encryption: %w", err)
+	}
+	sealed, err := p.ctx.Encrypt(p.session, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return append(iv, sealed...), nil
+}
+
+func (p *pkcs11KeyProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 12 {
+		return nil, errors.New("wrapped key too short")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	handle, err := p.findKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	params := pkcs11.NewGCMParams(wrapped[:12], []byte(keyID), pkcs11GCMTagBits)
+	defer params.Free()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params)}
+	if err := p.ctx.DecryptInit(p.session, mechanism, handle); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 decryption: %w", err)
+	}
+	dataKey, err := p.ctx.Decrypt(p.session, wrapped[12:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
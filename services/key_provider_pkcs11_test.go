@@ -0,0 +1,257 @@
+//go:build pkcs11
+
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"test-wallet/config"
+	"test-wallet/db"
+	"test-wallet/models"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	softHSMTokenLabel = "wallet-test"
+	softHSMUserPin    = "123456"
+	softHSMSOPin      = "12345678"
+)
+
+// softHSMModule returns the SoftHSM library from PKCS11_TEST_MODULE or a usual install path
+func softHSMModule(t *testing.T) string {
+	t.Helper()
+	candidates := []string{
+		os.Getenv("PKCS11_TEST_MODULE"),
+		"/usr/lib/softhsm/libsofthsm2.so",
+		"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+		"/usr/lib/aarch64-linux-gnu/softhsm/libsofthsm2.so",
+		"/usr/local/lib/softhsm/libsofthsm2.so",
+		"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+	}
+	for _, path := range candidates {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	t.Skip("SoftHSM not found; install softhsm2 or set PKCS11_TEST_MODULE")
+	return ""
+}
+
+// newSoftHSMToken points SoftHSM at a fresh token directory, initializes a token in it and
+// generates an AES-256 key for each label
+func newSoftHSMToken(t *testing.T, module string, labels ...string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	tokens := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokens, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(dir, "softhsm2.conf")
+	if err := os.WriteFile(conf, []byte("directories.tokendir = "+tokens+"\nobjectstore.backend = file\nlog.level = ERROR\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Fatalf("failed to load %s", module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		ctx.Finalize()
+		ctx.Destroy()
+	}()
+
+	slots, err := ctx.GetSlotList(false)
+	if err != nil || len(slots) == 0 {
+		t.Fatalf("no SoftHSM slot: %v", err)
+	}
+	if err := ctx.InitToken(slots[0], softHSMSOPin, softHSMTokenLabel); err != nil {
+		t.Fatal(err)
+	}
+	// SoftHSM moves an initialized token to a new slot
+	slot, err := findPKCS11Slot(ctx, softHSMTokenLabel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.CloseSession(session)
+	if err := ctx.Login(session, pkcs11.CKU_SO, softHSMSOPin); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.InitPIN(session, softHSMUserPin); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Logout(session); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.Login(session, pkcs11.CKU_USER, softHSMUserPin); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, label := range labels {
+		template := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+			pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+		}
+		mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_GEN, nil)}
+		if _, err := ctx.GenerateKey(session, mechanism, template); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// openSoftHSM creates the provider with masterKeyID as the current key and installs it as
+// the package's key provider until the test ends
+func openSoftHSM(t *testing.T, module, masterKeyID string) *pkcs11KeyProvider {
+	t.Helper()
+	provider, err := newPKCS11KeyProvider(config.KeyConfig{
+		Provider:         "pkcs11",
+		MasterKeyID:      masterKeyID,
+		PKCS11Module:     module,
+		PKCS11TokenLabel: softHSMTokenLabel,
+		PKCS11Pin:        softHSMUserPin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := provider.(*pkcs11KeyProvider)
+
+	previous := keyProvider
+	keyProvider = p
+	t.Cleanup(func() {
+		keyProvider = previous
+		closeSoftHSM(p)
+	})
+	return p
+}
+
+// closeSoftHSM releases the module so that another provider can initialize it
+func closeSoftHSM(p *pkcs11KeyProvider) {
+	if p.ctx == nil {
+		return
+	}
+	p.ctx.Logout(p.session)
+	p.ctx.CloseSession(p.session)
+	p.ctx.Finalize()
+	p.ctx.Destroy()
+	p.ctx = nil
+}
+
+func TestPKCS11WrapUnwrap(t *testing.T) {
+	module := softHSMModule(t)
+	newSoftHSMToken(t, module, "master-1")
+	p := openSoftHSM(t, module, "master-1")
+
+	dataKey := make([]byte, dataKeyLen)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := p.Wrap(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(wrapped, dataKey) {
+		t.Fatal("wrapped key contains the plaintext data key")
+	}
+
+	unwrapped, err := p.Unwrap("master-1", wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Fatal("unwrapped data key differs")
+	}
+
+	// GCM rejects modified wrapped keys and keys looked up under another ID
+	tampered := append([]byte(nil), wrapped...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := p.Unwrap("master-1", tampered); err == nil {
+		t.Fatal("tampered wrapped key was accepted")
+	}
+	if _, err := p.Unwrap("missing", wrapped); err == nil {
+		t.Fatal("unwrap with an unknown key ID succeeded")
+	}
+}
+
+func TestPKCS11RotateMasterKey(t *testing.T) {
+	module := softHSMModule(t)
+	newSoftHSMToken(t, module, "master-1", "master-2")
+
+	conn, err := gorm.Open(sqlite.Open("file:pkcs11_rotation?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := conn.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	// Seal a wallet with the first master key
+	first := openSoftHSM(t, module, "master-1")
+	const pin, mnemonic = "1234", "test test test test test test test test test test test junk"
+	user := models.User{Id: "user-1", Name: "Test", PhoneNumber: "+15550000001", Pin: "x", Salt: "x"}
+	user.Wallet = models.Wallet{Id: "wallet-1", UserId: user.Id, Address: "0x0"}
+	if err := sealWallet(&user.Wallet, pin, mnemonic); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	closeSoftHSM(first)
+
+	// Restart with the second key as current and rotate, as rotate-master-key does
+	openSoftHSM(t, module, "master-2")
+	result, err := RewrapWalletKeys(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rewrapped != 1 || result.Failed != 0 {
+		t.Fatalf("rotation result %+v, want 1 rewrapped", result)
+	}
+
+	var wallet models.Wallet
+	if err := conn.First(&wallet, "id = ?", "wallet-1").Error; err != nil {
+		t.Fatal(err)
+	}
+	if wallet.KeyId != "master-2" {
+		t.Fatalf("wallet key ID %q, want master-2", wallet.KeyId)
+	}
+	opened, err := openWallet(&wallet, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != mnemonic {
+		t.Fatal("mnemonic changed by rotation")
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"test-wallet/repository"
+	"test-wallet/utils"
+
+	"gorm.io/gorm"
+)
+
+// rewrapBatchSize caps how many wallets are loaded per rotation query
+const rewrapBatchSize = 100
+
+// RotationResult summarizes a master key rotation
+type RotationResult struct {
+	Rewrapped int
+	Failed    int
+}
+
+// RewrapWalletKeys rewraps the data key of every wallet not yet wrapped by the current
+// master key. Only data keys change, so no user PINs are needed; wallets created before
+// envelope encryption receive a data key. Rows changed concurrently are skipped and picked
+// up by the next run.
+func RewrapWalletKeys(ctx context.Context, conn *gorm.DB) (*RotationResult, error) {
+	userRepo := repository.NewUserRepository(conn)
+	keyID := keyProvider.KeyID()
+	result := &RotationResult{}
+
+	afterID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		wallets, err := userRepo.ListWalletsNotWrappedBy(keyID, afterID, rewrapBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(wallets) == 0 {
+			break
+		}
+
+		for i := range wallets {
+			wallet := wallets[i]
+			previous := wallet.Mnemonic
+			afterID = wallet.Id
+
+			if err := rewrapWallet(&wallet); err != nil {
+				utils.LogError(err, "Failed to rewrap wallet data key", map[string]interface{}{
+					"wallet_id": wallet.Id,
+					"key_id":    wallets[i].KeyId,
+				})
+				result.Failed++
+				continue
+			}
+
+			updated, err := userRepo.UpdateWalletEncryption(&wallet, previous)
+			if err != nil {
+				result.Failed++
+				continue
+			}
+			if updated {
+				result.Rewrapped++
+			}
+		}
+	}
+
+	utils.LogInfo("Master key rotation finished", map[string]interface{}{
+		"key_id":    keyID,
+		"rewrapped": result.Rewrapped,
+		"failed":    result.Failed,
+	})
+
+	return result, nil
+}
+
+// RewrapTOTPSecrets rewraps the data key of every TOTP secret not yet wrapped by the
+// current master key
+func RewrapTOTPSecrets(ctx context.Context, conn *gorm.DB) (*RotationResult, error) {
+	totpRepo := repository.NewTotpRepository(conn)
+	keyID := keyProvider.KeyID()
+	result := &RotationResult{}
+
+	afterID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		factors, err := totpRepo.ListFactorsNotWrappedBy(keyID, afterID, rewrapBatchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(factors) == 0 {
+			break
+		}
+
+		for i := range factors {
+			factor := factors[i]
+			previous := factor.DataKey
+			afterID = factor.UserId
+
+			wrapped, err := base64.StdEncoding.DecodeString(factor.DataKey)
+			if err == nil {
+				wrapped, err = rewrapDataKey(factor.KeyId, wrapped)
+			}
+			if err != nil {
+				utils.LogError(err, "Failed to rewrap TOTP data key", map[string]interface{}{
+					"user_id": factor.UserId,
+					"key_id":  factor.KeyId,
+				})
+				result.Failed++
+				continue
+			}
+			factor.DataKey = base64.StdEncoding.EncodeToString(wrapped)
+			factor.KeyId = keyID
+
+			updated, err := totpRepo.UpdateFactorKey(&factor, previous)
+			if err != nil {
+				result.Failed++
+				continue
+			}
+			if updated {
+				result.Rewrapped++
+			}
+		}
+	}
+
+	return result, nil
+}
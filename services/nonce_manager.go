@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+)
+
+// NonceManager allocates nonces per address. Allocation holds a row lock on the
+// address's nonce state, so concurrent sends from any replica get distinct nonces.
+type NonceManager struct {
+	nonceRepo *repository.NonceRepository
+	txRepo    *repository.TransactionRepository
+	chains    *ChainRegistry
+}
+
+func NewNonceManager(conn *gorm.DB, chains *ChainRegistry) *NonceManager {
+	return &NonceManager{
+		nonceRepo: repository.NewNonceRepository(conn),
+		txRepo:    repository.NewTransactionRepository(conn),
+		chains:    chains,
+	}
+}
+
+// Reserve picks the next usable nonce for the address and calls build with it while the
+// nonce is locked. The record returned by build is stored in the same database transaction,
+// so the nonce is either committed together with its pending transaction or not at all.
+//
+// Nonces below the stored counter that have no pending transaction were left behind by
+// failed broadcasts; the lowest such gap is reused before the counter is advanced.
+func (m *NonceManager) Reserve(ctx context.Context, chain *Chain, address common.Address, build func(nonce uint64) (*models.Transaction, error)) error {
+	return m.nonceRepo.WithNonceLock(chain.ID, address.Hex(), func(tx *gorm.DB, state *models.NonceState) error {
+		chainNonce, err := chain.client.PendingNonceAt(ctx, address)
+		if err != nil {
+			utils.LogError(err, "Failed to get nonce", map[string]interface{}{
+				"address": address.Hex(),
+			})
+			return fmt.Errorf("failed to get nonce: %w", err)
+		}
+
+		next := max(state.NextNonce, chainNonce)
+		used, err := m.nonceRepo.PendingNonces(tx, chain.ID, address.Hex(), chainNonce)
+		if err != nil {
+			return err
+		}
+
+		nonce := next
+		for candidate := chainNonce; candidate < next; candidate++ {
+			if !used[candidate] {
+				utils.LogInfo("Reusing nonce gap", map[string]interface{}{
+					"address": address.Hex(),
+					"nonce":   candidate,
+				})
+				nonce = candidate
+				break
+			}
+		}
+
+		record, err := build(nonce)
+		if err != nil {
+			return err
+		}
+		if err := m.txRepo.WithTx(tx).CreateTransaction(record); err != nil {
+			return err
+		}
+
+		state.NextNonce = max(next, nonce+1)
+		return nil
+	})
+}
+
+// Resync resets the stored counter of an address to the chain's pending nonce
+func (m *NonceManager) Resync(ctx context.Context, chain *Chain, address common.Address) error {
+	chainNonce, err := chain.client.PendingNonceAt(ctx, address)
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %w", err)
+	}
+
+	if err := m.nonceRepo.SetNextNonce(chain.ID, address.Hex(), chainNonce); err != nil {
+		return err
+	}
+
+	utils.LogInfo("Nonce resynced from chain", map[string]interface{}{
+		"chain_id": chain.ID,
+		"address":  address.Hex(),
+		"nonce":    chainNonce,
+	})
+
+	return nil
+}
+
+// ResyncAll resyncs every tracked address on the configured chains
+func (m *NonceManager) ResyncAll(ctx context.Context) error {
+	states, err := m.nonceRepo.ListNonceStates()
+	if err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		chain, err := m.chains.ByID(state.ChainId)
+		if err != nil {
+			continue
+		}
+		if err := m.Resync(ctx, chain, common.HexToAddress(state.Address)); err != nil {
+			utils.LogError(err, "Failed to resync nonce", map[string]interface{}{
+				"address": state.Address,
+			})
+		}
+	}
+
+	return nil
+}
+
+// isNonceTooLow reports whether a broadcast was rejected because the nonce was already used
+func isNonceTooLow(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
+
+// ResyncNonces resyncs all tracked addresses from chain, used at startup
+func ResyncNonces(ctx context.Context, conn *gorm.DB, chains *ChainRegistry) error {
+	return NewNonceManager(conn, chains).ResyncAll(ctx)
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"test-wallet/config"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidOTP is returned for a wrong, expired, used or exhausted verification code
+	ErrInvalidOTP = errors.New("invalid or expired verification code")
+	// ErrOTPRecentlySent is returned when a new code is requested before the resend interval
+	ErrOTPRecentlySent = errors.New("a verification code was sent recently; try again later")
+)
+
+// OTPService sends one-time codes by SMS and checks them. Each challenge expires, accepts
+// a limited number of attempts and is superseded by the next code sent to the number.
+type OTPService struct {
+	otpRepo *repository.OtpRepository
+	sender  SMSSender
+}
+
+func NewOTPService(conn *gorm.DB) *OTPService {
+	return &OTPService{
+		otpRepo: repository.NewOtpRepository(conn),
+		sender:  smsSender,
+	}
+}
+
+// Send creates a challenge for the E.164 phone number and texts its code
+func (s *OTPService) Send(ctx context.Context, phoneNumber, purpose string) error {
+	cfg := config.AppConfig.SecurityConfig
+
+	latest, err := s.otpRepo.FindLatestChallenge(phoneNumber, purpose)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < cfg.OTPResendInterval {
+		return ErrOTPRecentlySent
+	}
+
+	code, err := generateOTP()
+	if err != nil {
+		return fmt.Errorf("failed to generate verification code: %w", err)
+	}
+
+	challenge := &models.OtpChallenge{
+		Id:          uuid.New().String(),
+		PhoneNumber: phoneNumber,
+		Purpose:     purpose,
+		ExpiresAt:   time.Now().Add(cfg.OTPExpiration),
+	}
+	challenge.CodeHash = hashOTP(challenge.Id, code)
+	if err := s.otpRepo.CreateChallenge(challenge); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Your Test Wallet verification code is %s. It expires in %d minutes.", code, int(cfg.OTPExpiration.Minutes()))
+	if err := s.sender.Send(ctx, phoneNumber, message); err != nil {
+		utils.LogError(err, "Failed to send verification code", map[string]interface{}{
+			"phone_number": phoneNumber,
+		})
+		return fmt.Errorf("failed to send verification code: %w", err)
+	}
+
+	utils.LogInfo("Verification code sent", map[string]interface{}{
+		"phone_number": phoneNumber,
+		"purpose":      purpose,
+	})
+	return nil
+}
+
+// Verify checks a code against the latest challenge for the phone number and consumes
+// the challenge on success
+func (s *OTPService) Verify(phoneNumber, purpose, code string) error {
+	challenge, err := s.otpRepo.FindLatestChallenge(phoneNumber, purpose)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidOTP
+	}
+	if err != nil {
+		return err
+	}
+	if challenge.ConsumedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return ErrInvalidOTP
+	}
+
+	// Count the attempt before comparing so concurrent guesses cannot exceed the limit
+	counted, err := s.otpRepo.RecordAttempt(challenge.Id, config.AppConfig.SecurityConfig.OTPMaxAttempts)
+	if err != nil {
+		return err
+	}
+	if !counted {
+		return ErrInvalidOTP
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashOTP(challenge.Id, code)), []byte(challenge.CodeHash)) != 1 {
+		return ErrInvalidOTP
+	}
+
+	consumed, err := s.otpRepo.ConsumeChallenge(challenge.Id)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidOTP
+	}
+	return nil
+}
+
+// generateOTP returns a uniformly random six-digit code
+func generateOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashOTP hashes a code together with its challenge ID
+func hashOTP(challengeID, code string) string {
+	sum := sha256.Sum256([]byte(challengeID + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
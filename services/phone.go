@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidPhoneNumber is returned for phone numbers that are not in international format
+var ErrInvalidPhoneNumber = errors.New("phone number must be in international format, e.g. +14155552671")
+
+// e164Pattern matches a + followed by a country code and at most 15 digits in total
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// normalizePhoneNumber converts a phone number to E.164. Spaces, dashes, dots and
+// parentheses are dropped and a leading 00 international prefix becomes +.
+func normalizePhoneNumber(phoneNumber string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phoneNumber) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", ErrInvalidPhoneNumber
+		}
+	}
+
+	normalized := b.String()
+	if strings.HasPrefix(normalized, "00") {
+		normalized = "+" + normalized[2:]
+	}
+	if !e164Pattern.MatchString(normalized) {
+		return "", ErrInvalidPhoneNumber
+	}
+	return normalized, nil
+}
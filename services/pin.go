@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultDerivationPath is the BIP-44 path of the first Ethereum account
+const DefaultDerivationPath = "m/44'/60'/0'/0/0"
+
+// ErrInvalidCredentials is returned when a phone number, PIN or mnemonic does not match
+var ErrInvalidCredentials = errors.New("invalid phone number or credentials")
+
+// ErrInvalidPin is returned when the PIN supplied for a wallet operation is wrong
+var ErrInvalidPin = errors.New("invalid PIN")
+
+// pinError hides the reason a PIN check failed, except for an active backoff or lockout
+func pinError(err error, invalid error) error {
+	if errors.Is(err, ErrTooManyAttempts) {
+		return err
+	}
+	return invalid
+}
+
+// hashPin generates a fresh salt and returns the bcrypt hash of the salted PIN
+func hashPin(pin string) (string, string, error) {
+	salt, err := GenerateSalt(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	hashedPin, err := bcrypt.GenerateFromPassword([]byte(pin+salt), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to hash PIN: %w", err)
+	}
+
+	return string(hashedPin), salt, nil
+}
+
+// verifyPin checks the PIN against the user's stored bcrypt hash
+func verifyPin(user *models.User, pin string) error {
+	return bcrypt.CompareHashAndPassword([]byte(user.Pin), []byte(pin+user.Salt))
+}
+
+// upgradeWalletEncryption re-encrypts the user's wallet secret with the current envelope
+// format and a wrapped data key after a successful PIN entry. Failures are logged and
+// never block the caller.
+func upgradeWalletEncryption(userRepo UserStore, user *models.User, pin, plaintext string) {
+	inner, err := pinCiphertext(&user.Wallet)
+	if err != nil || (user.Wallet.DataKey != "" && !NeedsReencrypt(inner)) {
+		return
+	}
+
+	if plaintext == "" {
+		if plaintext, err = Decrypt(pin, inner); err != nil {
+			utils.LogError(err, "Failed to decrypt wallet for re-encryption", map[string]interface{}{
+				"user_id": user.Id,
+			})
+			return
+		}
+	}
+
+	wallet := user.Wallet
+	if err := sealWallet(&wallet, pin, plaintext); err != nil {
+		utils.LogError(err, "Failed to re-encrypt wallet", map[string]interface{}{
+			"user_id": user.Id,
+		})
+		return
+	}
+
+	updated, err := userRepo.UpdateWalletEncryption(&wallet, user.Wallet.Mnemonic)
+	if err != nil || !updated {
+		return
+	}
+	user.Wallet = wallet
+
+	utils.LogInfo("Wallet encryption upgraded", map[string]interface{}{
+		"user_id": user.Id,
+	})
+}
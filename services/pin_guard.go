@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"test-wallet/config"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrTooManyAttempts is returned while a user or IP is in a PIN backoff or lockout
+var ErrTooManyAttempts = errors.New("too many failed PIN attempts")
+
+// PinGuard limits PIN guessing per user and per client IP. Each consecutive failure
+// doubles the wait before the next attempt; reaching the configured maximum locks
+// the user or IP for the lockout period and records an audit event.
+type PinGuard struct {
+	attemptRepo *repository.AttemptRepository
+	audit       *AuditService
+}
+
+func NewPinGuard(conn *gorm.DB) *PinGuard {
+	return &PinGuard{
+		attemptRepo: repository.NewAttemptRepository(conn),
+		audit:       NewAuditService(conn),
+	}
+}
+
+func userAttemptKey(userID string) string {
+	return "user:" + userID
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Verify checks the user's PIN unless the user is blocked, and records the outcome. The
+// check, the comparison and the recorded outcome happen under the user's attempt lock, so
+// concurrent guesses are counted one after another. Verify fails closed: if the attempt
+// state can't be read or stored, the PIN is rejected.
+func (g *PinGuard) Verify(user *models.User, pin string) error {
+	maxAttempts := config.AppConfig.SecurityConfig.PinMaxAttempts
+	var pinErr error
+	var locked bool
+	var failures int
+
+	err := g.attemptRepo.WithAttemptLock(userAttemptKey(user.Id), func(attempt *models.PinAttempt) error {
+		if err := blockedError(attempt); err != nil {
+			return err
+		}
+
+		if pinErr = verifyPin(user, pin); pinErr != nil {
+			locked = countFailure(attempt, maxAttempts)
+			failures = attempt.Failures
+			return nil
+		}
+
+		attempt.Failures = 0
+		attempt.BlockedUntil = nil
+		attempt.LockedAt = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		g.reportLockout(user.Id, "", failures)
+	}
+	return pinErr
+}
+
+// Reset clears the user's failed attempts after a successful PIN or ownership proof
+func (g *PinGuard) Reset(userID string) {
+	if _, err := g.attemptRepo.DeleteAttempt(userAttemptKey(userID)); err != nil {
+		utils.LogError(err, "Failed to reset PIN attempts", map[string]interface{}{
+			"user_id": userID,
+		})
+	}
+}
+
+// RecordUserFailure counts a failed second factor against the user's PIN attempts
+func (g *PinGuard) RecordUserFailure(userID string) {
+	g.recordFailure(userAttemptKey(userID), userID, "", config.AppConfig.SecurityConfig.PinMaxAttempts)
+}
+
+// CheckIP returns ErrTooManyAttempts while the client IP is blocked
+func (g *PinGuard) CheckIP(ip string) error {
+	return g.check(ipAttemptKey(ip))
+}
+
+// RecordIPFailure counts a failed PIN or login from the client IP
+func (g *PinGuard) RecordIPFailure(ip string) {
+	g.recordFailure(ipAttemptKey(ip), "", ip, config.AppConfig.SecurityConfig.IPPinMaxAttempts)
+}
+
+// UnlockUser clears a user's lockout. It reports whether the user had failed attempts.
+func (g *PinGuard) UnlockUser(userID string) (bool, error) {
+	cleared, err := g.attemptRepo.DeleteAttempt(userAttemptKey(userID))
+	if err != nil {
+		return false, err
+	}
+	if cleared {
+		g.audit.Record(models.AuditPinUnlock, userID, "", nil)
+	}
+	return cleared, nil
+}
+
+// UnlockIP clears a client IP's lockout. It reports whether the IP had failed attempts.
+func (g *PinGuard) UnlockIP(ip string) (bool, error) {
+	cleared, err := g.attemptRepo.DeleteAttempt(ipAttemptKey(ip))
+	if err != nil {
+		return false, err
+	}
+	if cleared {
+		g.audit.Record(models.AuditPinUnlock, "", ip, nil)
+	}
+	return cleared, nil
+}
+
+// check returns ErrTooManyAttempts while key is in a backoff or lockout
+func (g *PinGuard) check(key string) error {
+	attempt, err := g.attemptRepo.FindAttempt(key)
+	if err != nil {
+		return err
+	}
+	return blockedError(attempt)
+}
+
+// blockedError returns ErrTooManyAttempts while the attempt state is in a backoff or lockout
+func blockedError(attempt *models.PinAttempt) error {
+	if attempt.BlockedUntil != nil {
+		if wait := time.Until(*attempt.BlockedUntil); wait > 0 {
+			return fmt.Errorf("%w, retry in %s", ErrTooManyAttempts, wait.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// recordFailure counts a failure for key and starts its backoff, or a lockout once
+// maxAttempts consecutive failures are reached
+func (g *PinGuard) recordFailure(key, userID, ip string, maxAttempts int) {
+	var locked bool
+	var failures int
+
+	err := g.attemptRepo.WithAttemptLock(key, func(attempt *models.PinAttempt) error {
+		locked = countFailure(attempt, maxAttempts)
+		failures = attempt.Failures
+		return nil
+	})
+	if err != nil {
+		utils.LogError(err, "Failed to record PIN failure", map[string]interface{}{
+			"user_id": userID,
+			"ip":      ip,
+		})
+		return
+	}
+
+	if locked {
+		g.reportLockout(userID, ip, failures)
+	}
+}
+
+// countFailure adds a failure to the locked attempt state and starts its backoff, or a
+// lockout once maxAttempts consecutive failures are reached. It reports a new lockout.
+func countFailure(attempt *models.PinAttempt, maxAttempts int) bool {
+	cfg := config.AppConfig.SecurityConfig
+	now := time.Now()
+
+	// An expired lockout starts a fresh series of attempts
+	if attempt.LockedAt != nil && attempt.BlockedUntil != nil && now.After(*attempt.BlockedUntil) {
+		attempt.Failures = 0
+		attempt.LockedAt = nil
+	}
+
+	attempt.Failures++
+
+	if maxAttempts > 0 && attempt.Failures >= maxAttempts {
+		until := now.Add(cfg.PinLockout)
+		attempt.BlockedUntil = &until
+		attempt.LockedAt = &now
+		return true
+	}
+
+	delay := cfg.PinBackoff << min(attempt.Failures-1, 20)
+	if cfg.PinLockout > 0 && (delay > cfg.PinLockout || delay <= 0) {
+		delay = cfg.PinLockout
+	}
+	until := now.Add(delay)
+	attempt.BlockedUntil = &until
+	return false
+}
+
+// reportLockout logs and audits a user or IP reaching the maximum number of failures
+func (g *PinGuard) reportLockout(userID, ip string, failures int) {
+	utils.LogInfo("PIN lockout", map[string]interface{}{
+		"user_id":  userID,
+		"ip":       ip,
+		"failures": failures,
+	})
+	g.audit.Record(models.AuditPinLockout, userID, ip, map[string]interface{}{
+		"failures":         failures,
+		"lockout_duration": config.AppConfig.SecurityConfig.PinLockout.String(),
+	})
+}
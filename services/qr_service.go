@@ -4,19 +4,18 @@ import (
 	"encoding/base64"
 	"fmt"
 	"test-wallet/models"
-	"test-wallet/repository"
 	"test-wallet/utils"
 
 	"github.com/skip2/go-qrcode"
 )
 
 type QRService struct {
-	userRepo *repository.UserRepository
+	userRepo UserStore
 }
 
-func NewQRService() *QRService {
+func NewQRService(users UserStore) *QRService {
 	return &QRService{
-		userRepo: repository.NewUserRepository(),
+		userRepo: users,
 	}
 }
 
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"test-wallet/config"
+	"test-wallet/middleware"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown, expired, revoked or reused refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrSessionNotFound is returned when revoking a session the user does not have
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// SessionService issues access tokens backed by revocable sessions with rotating refresh tokens
+type SessionService struct {
+	sessionRepo *repository.SessionRepository
+	audit       *AuditService
+}
+
+func NewSessionService(conn *gorm.DB) *SessionService {
+	return &SessionService{
+		sessionRepo: repository.NewSessionRepository(conn),
+		audit:       NewAuditService(conn),
+	}
+}
+
+// newRefreshToken returns a random refresh token and its SHA-256 hash
+func newRefreshToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueAccessToken returns the token pair for a session
+func issueAccessToken(userID, sessionID, refreshToken string) (*models.TokenPair, error) {
+	accessToken, err := middleware.GenerateToken(userID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(config.AppConfig.JWTConfig.Expiration.Seconds()),
+		SessionId:    sessionID,
+	}, nil
+}
+
+// CreateSession starts a session for the user and returns its first token pair
+func (s *SessionService) CreateSession(userID string, client models.ClientInfo) (*models.TokenPair, error) {
+	refreshToken, hash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		Id:               uuid.New().String(),
+		UserId:           userID,
+		RefreshTokenHash: hash,
+		UserAgent:        truncate(client.UserAgent, 255),
+		IP:               client.IP,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(config.AppConfig.JWTConfig.RefreshExpiration),
+	}
+	if err := s.sessionRepo.CreateSession(session); err != nil {
+		return nil, err
+	}
+
+	return issueAccessToken(userID, session.Id, refreshToken)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The refresh token rotates;
+// presenting an already rotated token revokes the whole session, since it means the
+// token was copied.
+func (s *SessionService) Refresh(refreshToken string, client models.ClientInfo) (*models.TokenPair, error) {
+	hash := hashRefreshToken(refreshToken)
+
+	session, err := s.sessionRepo.FindSessionByRefreshHash(hash)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if session.RefreshTokenHash != hash {
+		if err := s.sessionRepo.RevokeSession(session.UserId, session.Id); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+		utils.LogInfo("Refresh token reuse detected, session revoked", map[string]interface{}{
+			"user_id":    session.UserId,
+			"session_id": session.Id,
+		})
+		s.audit.Record(models.AuditRefreshTokenReuse, session.UserId, client.IP, map[string]interface{}{
+			"session_id": session.Id,
+		})
+		return nil, ErrInvalidRefreshToken
+	}
+
+	nextToken, nextHash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	client.UserAgent = truncate(client.UserAgent, 255)
+	rotated, err := s.sessionRepo.RotateRefreshToken(session.Id, hash, nextHash, client)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return issueAccessToken(session.UserId, session.Id, nextToken)
+}
+
+// ListSessions returns the user's active sessions, marking the one of currentSessionID
+func (s *SessionService) ListSessions(userID, currentSessionID string) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.ListActiveSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions; its access and refresh tokens stop working
+func (s *SessionService) RevokeSession(userID, sessionID string) error {
+	err := s.sessionRepo.RevokeSession(userID, sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	utils.LogInfo("Session revoked", map[string]interface{}{
+		"user_id":    userID,
+		"session_id": sessionID,
+	})
+	return nil
+}
+
+// RevokeAllSessions ends every session of the user
+func (s *SessionService) RevokeAllSessions(userID string) error {
+	return s.sessionRepo.RevokeUserSessions(userID)
+}
+
+// truncate cuts s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
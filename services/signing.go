@@ -0,0 +1,167 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"test-wallet/models"
+	"test-wallet/utils"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// ErrInvalidTypedData is returned for EIP-712 data that cannot be hashed or targets another chain
+var ErrInvalidTypedData = errors.New("invalid typed data")
+
+// auditPreviewLen caps how much of a signed message is copied into the audit log
+const auditPreviewLen = 256
+
+// signDigest signs a 32-byte digest and returns the signature with v as 27 or 28
+func signDigest(key *ecdsa.PrivateKey, digest []byte) (string, error) {
+	sig, err := crypto.Sign(digest, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign: %w", err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return hexutil.Encode(sig), nil
+}
+
+// messageBytes decodes 0x-prefixed hex messages and passes text through unchanged
+func messageBytes(message string) []byte {
+	if strings.HasPrefix(message, "0x") {
+		if decoded, err := hexutil.Decode(message); err == nil {
+			return decoded
+		}
+	}
+	return []byte(message)
+}
+
+// SignMessage signs a message with personal_sign (EIP-191) from one of the user's accounts
+func (s *WalletService) SignMessage(userID string, req *models.SignMessageRequest) (*models.SignatureResponse, error) {
+	account, privKey, err := s.unlockAccount(userID, req.Pin, req.FromAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	message := messageBytes(req.Message)
+	preview := models.MessagePreview{Hex: hexutil.Encode(message), Length: len(message)}
+	if utf8.Valid(message) {
+		preview.Text = string(message)
+	}
+
+	digest := accounts.TextHash(message)
+	signature, err := signDigest(privKey, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(models.AuditMessageSigned, userID, "", map[string]interface{}{
+		"address":   account.Address,
+		"hash":      hexutil.Encode(digest),
+		"signature": signature,
+		"message":   truncate(preview.Hex, auditPreviewLen),
+	})
+	utils.LogInfo("Message signed", map[string]interface{}{
+		"user_id": userID,
+		"address": account.Address,
+	})
+
+	return &models.SignatureResponse{
+		Address:   account.Address,
+		Signature: signature,
+		Hash:      hexutil.Encode(digest),
+		Preview:   preview,
+	}, nil
+}
+
+// SignTypedData signs EIP-712 typed data (eth_signTypedData_v4) from one of the user's
+// accounts. Data whose domain names a chain other than the selected one is refused. Typed
+// data can authorize transfers, such as permits, so users with an authenticator must also
+// give a TOTP or backup code.
+func (s *WalletService) SignTypedData(userID string, req *models.SignTypedDataRequest) (*models.SignatureResponse, error) {
+	var typedData apitypes.TypedData
+	if err := json.Unmarshal(req.TypedData, &typedData); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTypedData, err)
+	}
+
+	digest, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTypedData, err)
+	}
+	fields, err := typedData.Format()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTypedData, err)
+	}
+
+	domain := models.TypedDataDomainPreview{
+		Name:              typedData.Domain.Name,
+		Version:           typedData.Domain.Version,
+		VerifyingContract: typedData.Domain.VerifyingContract,
+		Salt:              typedData.Domain.Salt,
+	}
+	if typedData.Domain.ChainId != nil {
+		chain, err := s.chains.Resolve(req.Chain)
+		if err != nil {
+			return nil, err
+		}
+		domainChain := (*big.Int)(typedData.Domain.ChainId)
+		if domainChain.Cmp(chain.ChainID()) != 0 {
+			return nil, fmt.Errorf("%w: domain chain ID %s does not match %s (%d)", ErrInvalidTypedData, domainChain, chain.Name, chain.ID)
+		}
+		domain.ChainId = domainChain.String()
+	}
+
+	account, privKey, err := s.unlockAccount(userID, req.Pin, req.FromAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	// A signed permit or order moves funds without a transaction, so always ask for the second factor
+	if err := s.checkFactor(userID, req.TotpCode); err != nil {
+		return nil, err
+	}
+
+	signature, err := signDigest(privKey, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(models.AuditTypedDataSigned, userID, "", map[string]interface{}{
+		"address":            account.Address,
+		"hash":               hexutil.Encode(digest),
+		"signature":          signature,
+		"domain_name":        domain.Name,
+		"chain_id":           domain.ChainId,
+		"verifying_contract": domain.VerifyingContract,
+		"primary_type":       typedData.PrimaryType,
+	})
+	utils.LogInfo("Typed data signed", map[string]interface{}{
+		"user_id":      userID,
+		"address":      account.Address,
+		"primary_type": typedData.PrimaryType,
+	})
+
+	// Format returns the domain first and the primary type's fields second
+	var message interface{}
+	if len(fields) > 1 {
+		message = fields[1].Value
+	}
+
+	return &models.SignatureResponse{
+		Address:   account.Address,
+		Signature: signature,
+		Hash:      hexutil.Encode(digest),
+		Preview: models.TypedDataPreview{
+			Domain:      domain,
+			PrimaryType: typedData.PrimaryType,
+			Message:     message,
+		},
+	}, nil
+}
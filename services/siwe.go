@@ -0,0 +1,246 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"test-wallet/config"
+	"test-wallet/models"
+	"test-wallet/utils"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidSIWE is returned when a Sign-In with Ethereum message or signature is rejected
+var ErrInvalidSIWE = errors.New("invalid Sign-In with Ethereum message")
+
+// siwePreamble follows the domain on the first line of an EIP-4361 message
+const siwePreamble = " wants you to sign in with your Ethereum account:"
+
+// siweClockSkew tolerates clients whose clocks run slightly ahead of the server
+const siweClockSkew = time.Minute
+
+// siweMessage holds the fields of an EIP-4361 message
+type siweMessage struct {
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        string
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// parseSIWEMessage parses the EIP-4361 text format
+func parseSIWEMessage(message string) (*siweMessage, error) {
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if len(lines) < 3 || !strings.HasSuffix(lines[0], siwePreamble) {
+		return nil, errors.New("missing sign-in preamble")
+	}
+
+	msg := &siweMessage{Domain: strings.TrimSuffix(lines[0], siwePreamble)}
+	if _, host, ok := strings.Cut(msg.Domain, "://"); ok {
+		msg.Domain = host
+	}
+
+	msg.Address = strings.TrimSpace(lines[1])
+	if !strings.HasPrefix(msg.Address, "0x") || !common.IsHexAddress(msg.Address) {
+		return nil, errors.New("invalid address")
+	}
+
+	// The optional statement sits between blank lines before the URI field
+	i := 2
+	var statement []string
+	for ; i < len(lines) && !strings.HasPrefix(lines[i], "URI: "); i++ {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			statement = append(statement, line)
+		}
+	}
+	msg.Statement = strings.Join(statement, "\n")
+
+	var issuedAt string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		if line == "Resources:" {
+			for i++; i < len(lines) && strings.HasPrefix(lines[i], "- "); i++ {
+				msg.Resources = append(msg.Resources, strings.TrimPrefix(lines[i], "- "))
+			}
+			i--
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		switch key {
+		case "URI":
+			msg.URI = value
+		case "Version":
+			msg.Version = value
+		case "Chain ID":
+			msg.ChainID = value
+		case "Nonce":
+			msg.Nonce = value
+		case "Issued At":
+			issuedAt = value
+		case "Expiration Time":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, errors.New("invalid expiration time")
+			}
+			msg.ExpirationTime = &t
+		case "Not Before":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, errors.New("invalid not-before time")
+			}
+			msg.NotBefore = &t
+		case "Request ID":
+			msg.RequestID = value
+		default:
+			return nil, fmt.Errorf("unknown field %q", key)
+		}
+	}
+
+	if msg.URI == "" || msg.Version == "" || msg.ChainID == "" || msg.Nonce == "" || issuedAt == "" {
+		return nil, errors.New("missing required field")
+	}
+	t, err := time.Parse(time.RFC3339, issuedAt)
+	if err != nil {
+		return nil, errors.New("invalid issued-at time")
+	}
+	msg.IssuedAt = t
+
+	return msg, nil
+}
+
+// recoverPersonalSigner returns the address that signed message with personal_sign (EIP-191)
+func recoverPersonalSigner(message, signature string) (common.Address, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signature), "0x"))
+	if err != nil || len(sig) != crypto.SignatureLength {
+		return common.Address{}, errors.New("signature must be 65 bytes of hex")
+	}
+	// Wallets encode the recovery ID as 27/28
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte(message)), sig)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to recover signer: %w", err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// SIWENonce issues a single-use nonce for a Sign-In with Ethereum message
+func (s *UserService) SIWENonce() (*models.SiweNonceResponse, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	cfg := config.AppConfig.SecurityConfig
+	nonce := &models.SiweNonce{
+		Nonce:     hex.EncodeToString(raw),
+		ExpiresAt: time.Now().Add(cfg.SIWENonceExpiration),
+	}
+	if err := s.siweRepo.CreateNonce(nonce); err != nil {
+		return nil, err
+	}
+
+	return &models.SiweNonceResponse{
+		Nonce:     nonce.Nonce,
+		Domain:    cfg.SIWEDomain,
+		URI:       cfg.SIWEURI,
+		ExpiresAt: nonce.ExpiresAt,
+	}, nil
+}
+
+// LoginWithSIWE signs in the user owning the address that signed an EIP-4361 message.
+// The message must be bound to this server's domain and carry an unused nonce.
+func (s *UserService) LoginWithSIWE(req *models.SiweVerifyRequest, client models.ClientInfo) (*models.TokenPair, *models.User, error) {
+	msg, err := parseSIWEMessage(req.Message)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSIWE, err)
+	}
+
+	now := time.Now()
+	switch {
+	case msg.Domain != config.AppConfig.SecurityConfig.SIWEDomain:
+		return nil, nil, fmt.Errorf("%w: domain mismatch", ErrInvalidSIWE)
+	case msg.Version != "1":
+		return nil, nil, fmt.Errorf("%w: unsupported version", ErrInvalidSIWE)
+	case msg.IssuedAt.After(now.Add(siweClockSkew)):
+		return nil, nil, fmt.Errorf("%w: issued in the future", ErrInvalidSIWE)
+	case msg.ExpirationTime != nil && !now.Before(*msg.ExpirationTime):
+		return nil, nil, fmt.Errorf("%w: message expired", ErrInvalidSIWE)
+	case msg.NotBefore != nil && now.Add(siweClockSkew).Before(*msg.NotBefore):
+		return nil, nil, fmt.Errorf("%w: message not yet valid", ErrInvalidSIWE)
+	}
+
+	signer, err := recoverPersonalSigner(req.Message, req.Signature)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSIWE, err)
+	}
+	if signer != common.HexToAddress(msg.Address) {
+		return nil, nil, fmt.Errorf("%w: signature does not match address", ErrInvalidSIWE)
+	}
+
+	consumed, err := s.siweRepo.ConsumeNonce(msg.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !consumed {
+		return nil, nil, fmt.Errorf("%w: unknown, expired or used nonce", ErrInvalidSIWE)
+	}
+
+	// The address must belong to exactly one user's wallet
+	userIDs, err := s.accountRepo.FindUserIDsByAddress(signer.Hex())
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(userIDs) != 1 {
+		utils.LogInfo("SIWE address not linked to a single user", map[string]interface{}{
+			"address": signer.Hex(),
+			"users":   len(userIDs),
+		})
+		return nil, nil, fmt.Errorf("%w: address is not linked to an account", ErrInvalidSIWE)
+	}
+
+	user, err := s.userRepo.FindUserByID(userIDs[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Status == models.UserStatusPending {
+		return nil, nil, ErrPhoneNotVerified
+	}
+
+	tokens, err := s.sessionService.CreateSession(user.Id, client)
+	if err != nil {
+		utils.LogError(err, "Failed to create session", map[string]interface{}{
+			"user_id": user.Id,
+		})
+		return nil, nil, errors.New("failed to generate authentication token")
+	}
+
+	utils.LogInfo("User signed in with Ethereum", map[string]interface{}{
+		"user_id": user.Id,
+		"address": signer.Hex(),
+	})
+
+	return tokens, user, nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"test-wallet/config"
+	"test-wallet/utils"
+	"time"
+)
+
+// SMSSender delivers text messages to E.164 phone numbers
+type SMSSender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+var smsSender SMSSender
+
+// InitSMSSender creates the configured SMSSender. The console and file senders only
+// suit development and are refused in production.
+func InitSMSSender() error {
+	cfg := config.AppConfig.SMSConfig
+	production := config.AppConfig.ServerConfig.Environment == "production"
+
+	switch cfg.Provider {
+	case "", "console":
+		if production {
+			return errors.New("SMS_PROVIDER console must not be used in production")
+		}
+		smsSender = consoleSMSSender{}
+	case "file":
+		if production {
+			return errors.New("SMS_PROVIDER file must not be used in production")
+		}
+		smsSender = &fileSMSSender{path: cfg.FilePath}
+	case "http":
+		if cfg.HTTPURL == "" {
+			return errors.New("SMS_HTTP_URL must be set for the http SMS provider")
+		}
+		smsSender = &httpSMSSender{
+			url:    cfg.HTTPURL,
+			token:  cfg.HTTPToken,
+			from:   cfg.From,
+			client: &http.Client{Timeout: 10 * time.Second},
+		}
+	default:
+		return fmt.Errorf("unknown SMS provider %q", cfg.Provider)
+	}
+	return nil
+}
+
+// consoleSMSSender writes messages to the application log
+type consoleSMSSender struct{}
+
+func (consoleSMSSender) Send(_ context.Context, to, message string) error {
+	utils.LogInfo("SMS", map[string]interface{}{
+		"to":      to,
+		"message": message,
+	})
+	return nil
+}
+
+// fileSMSSender appends messages to a file, one tab-separated line per message
+type fileSMSSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *fileSMSSender) Send(_ context.Context, to, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open SMS file: %w", err)
+	}
+	defer f.Close()
+
+	line := fmt.Sprintf("%s\t%s\t%s\n", time.Now().UTC().Format(time.RFC3339), to, strings.ReplaceAll(message, "\n", " "))
+	if _, err := f.WriteString(line); err != nil {
+		return fmt.Errorf("failed to write SMS file: %w", err)
+	}
+	return nil
+}
+
+// SMSMessage is the JSON body the HTTP sender posts to the SMS provider
+type SMSMessage struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Message string `json:"message"`
+}
+
+// httpSMSSender posts messages to an SMS provider's HTTP API. Any 2xx response is success.
+type httpSMSSender struct {
+	url    string
+	token  string
+	from   string
+	client *http.Client
+}
+
+func (s *httpSMSSender) Send(ctx context.Context, to, message string) error {
+	body, err := json.Marshal(SMSMessage{From: s.from, To: to, Message: message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create SMS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("SMS provider returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// SMSStubHandler accepts the HTTP sender's requests and logs each message, for running
+// the http provider against a local stub during development
+func SMSStubHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var msg SMSMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.To == "" {
+			http.Error(w, "invalid SMS payload", http.StatusBadRequest)
+			return
+		}
+
+		utils.LogInfo("SMS stub received message", map[string]interface{}{
+			"from":    msg.From,
+			"to":      msg.To,
+			"message": msg.Message,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"queued"}`))
+	})
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TokenService struct {
+	tokenRepo *repository.TokenRepository
+	chains    *ChainRegistry
+}
+
+func NewTokenService(conn *gorm.DB, chains *ChainRegistry) *TokenService {
+	return &TokenService{
+		tokenRepo: repository.NewTokenRepository(conn),
+		chains:    chains,
+	}
+}
+
+// SeedTokens registers the configured tokens of every chain
+func (s *TokenService) SeedTokens(ctx context.Context) error {
+	for _, chain := range s.chains.List() {
+		if err := s.seedChainTokens(ctx, chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedChainTokens registers a chain's configured tokens, reading decimals() from each contract
+func (s *TokenService) seedChainTokens(ctx context.Context, chain *Chain) error {
+	for _, seed := range chain.Tokens {
+		if !common.IsHexAddress(seed.Address) {
+			utils.LogError(nil, "Invalid token address in configuration", map[string]interface{}{
+				"symbol":  seed.Symbol,
+				"address": seed.Address,
+			})
+			continue
+		}
+		address := common.HexToAddress(seed.Address)
+
+		decimals, err := erc20Decimals(ctx, chain.client, address)
+		if err != nil {
+			utils.LogError(err, "Failed to read token decimals", map[string]interface{}{
+				"chain":   chain.Name,
+				"symbol":  seed.Symbol,
+				"address": address.Hex(),
+			})
+			continue
+		}
+
+		token := &models.Token{
+			Id:       uuid.New().String(),
+			ChainId:  chain.ID,
+			Address:  address.Hex(),
+			Symbol:   strings.ToUpper(seed.Symbol),
+			Decimals: decimals,
+		}
+		if err := s.tokenRepo.UpsertToken(token); err != nil {
+			return err
+		}
+	}
+
+	utils.LogInfo("Token registry seeded", map[string]interface{}{
+		"chain": chain.Name,
+		"count": len(chain.Tokens),
+	})
+
+	return nil
+}
+
+// ResolveToken finds a supported token by symbol or contract address
+func (s *TokenService) ResolveToken(chainID uint64, selector string) (*models.Token, error) {
+	if common.IsHexAddress(selector) {
+		return s.tokenRepo.FindTokenByAddress(chainID, common.HexToAddress(selector).Hex())
+	}
+	return s.tokenRepo.FindTokenBySymbol(chainID, selector)
+}
+
+// ListTokenBalances returns every supported token on the chain with the owner's balance
+func (s *TokenService) ListTokenBalances(ctx context.Context, chain *Chain, owner common.Address) ([]models.TokenBalance, error) {
+	tokens, err := s.tokenRepo.ListTokens(chain.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]models.TokenBalance, 0, len(tokens))
+	for _, token := range tokens {
+		balance, err := erc20BalanceOf(ctx, chain.client, common.HexToAddress(token.Address), owner)
+		if err != nil {
+			utils.LogError(err, "Failed to get token balance", map[string]interface{}{
+				"symbol": token.Symbol,
+				"owner":  owner.Hex(),
+			})
+			return nil, fmt.Errorf("failed to get %s balance: %w", token.Symbol, err)
+		}
+
+		balances = append(balances, models.TokenBalance{
+			Symbol:   token.Symbol,
+			Address:  token.Address,
+			ChainId:  token.ChainId,
+			Decimals: token.Decimals,
+			Balance:  formatAmount(balance, token.Decimals),
+		})
+	}
+
+	return balances, nil
+}
@@ -0,0 +1,472 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"test-wallet/config"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTOTPRequired is returned when a transfer needs a TOTP code and none was given
+	ErrTOTPRequired = errors.New("a TOTP code is required for this transfer")
+	// ErrInvalidTOTP is returned for a wrong, reused or expired TOTP or backup code
+	ErrInvalidTOTP = errors.New("invalid TOTP code")
+	// ErrTOTPNotEnrolled is returned when the user has no authenticator (or none pending)
+	ErrTOTPNotEnrolled = errors.New("no TOTP authenticator is enrolled")
+	// ErrTOTPAlreadyEnabled is returned when enrolling while an authenticator is active
+	ErrTOTPAlreadyEnabled = errors.New("a TOTP authenticator is already enabled")
+	// ErrInvalidThreshold is returned for a TOTP policy threshold that is not an amount
+	ErrInvalidThreshold = errors.New("invalid TOTP threshold")
+)
+
+// RFC 6238 parameters, the defaults every authenticator app supports
+const (
+	totpPeriod      = 30 // Seconds per time step
+	totpDigits      = 6
+	totpModulo      = 1_000_000 // 10^totpDigits
+	totpSkew        = 1         // Steps accepted either side of the current one, for clock drift
+	totpSecretLen   = 20
+	backupCodeLen   = 5 // Random bytes per backup code, shown as 10 hex characters
+	backupCodeCount = 10
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// totpCode computes the RFC 4226 HOTP value of the secret at the given counter
+func totpCode(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%totpModulo)
+}
+
+// matchTOTP checks a code against the time steps around now and returns the matching step
+func matchTOTP(secret []byte, code string, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// isTOTPCode reports whether code has the shape of a TOTP code rather than a backup code
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// hashBackupCode hashes a backup code, ignoring case, spaces and dashes
+func hashBackupCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateBackupCodes returns fresh backup codes and their rows for the user
+func generateBackupCodes(userID string) ([]string, []models.TotpBackupCode, error) {
+	codes := make([]string, 0, backupCodeCount)
+	rows := make([]models.TotpBackupCode, 0, backupCodeCount)
+	for i := 0; i < backupCodeCount; i++ {
+		raw := make([]byte, backupCodeLen)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		encoded := hex.EncodeToString(raw)
+		code := encoded[:5] + "-" + encoded[5:]
+
+		codes = append(codes, code)
+		rows = append(rows, models.TotpBackupCode{
+			Id:       uuid.New().String(),
+			UserId:   userID,
+			CodeHash: hashBackupCode(code),
+		})
+	}
+	return codes, rows, nil
+}
+
+// totpAAD binds an encrypted TOTP secret to its user
+func totpAAD(userID string) []byte {
+	return []byte("totp:" + userID)
+}
+
+// openTOTPSecret decrypts the factor's secret
+func openTOTPSecret(factor *models.TotpFactor) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(factor.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(factor.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := openWithDataKey(factor.KeyId, wrapped, sealed, totpAAD(factor.UserId))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt TOTP secret: %w", err)
+	}
+	return secret, nil
+}
+
+// findTOTPFactor returns the user's factor, or nil if none is enrolled
+func (s *WalletService) findTOTPFactor(userID string) (*models.TotpFactor, error) {
+	factor, err := s.totpRepo.FindFactor(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	return factor, err
+}
+
+// EnrollTOTP starts enrolling an authenticator. The factor stays pending, and is not
+// enforced, until ConfirmTOTP receives a code from it; enrolling again restarts it.
+func (s *WalletService) EnrollTOTP(userID string, req *models.EnrollTOTPRequest) (*models.EnrollTOTPResponse, error) {
+	user, err := s.authorizeUser(userID, req.Pin)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.findTOTPFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.ConfirmedAt != nil {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	secret := make([]byte, totpSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	sealed, wrapped, err := sealWithDataKey(secret, totpAAD(userID))
+	if err != nil {
+		utils.LogError(err, "Failed to encrypt TOTP secret", map[string]interface{}{
+			"user_id": userID,
+		})
+		return nil, errors.New("failed to encrypt TOTP secret")
+	}
+
+	factor := &models.TotpFactor{
+		UserId:        userID,
+		Secret:        base64.StdEncoding.EncodeToString(sealed),
+		DataKey:       base64.StdEncoding.EncodeToString(wrapped),
+		KeyId:         keyProvider.KeyID(),
+		NewRecipients: true,
+	}
+	if err := s.totpRepo.SaveFactor(factor); err != nil {
+		return nil, err
+	}
+
+	issuer := config.AppConfig.SecurityConfig.TOTPIssuer
+	encoded := base32NoPadding.EncodeToString(secret)
+	params := url.Values{}
+	params.Set("secret", encoded)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+
+	return &models.EnrollTOTPResponse{
+		Secret: encoded,
+		URI:    fmt.Sprintf("otpauth://totp/%s:%s?%s", url.PathEscape(issuer), url.PathEscape(user.PhoneNumber), params.Encode()),
+	}, nil
+}
+
+// ConfirmTOTP activates a pending authenticator with a code from it and returns new
+// backup codes
+func (s *WalletService) ConfirmTOTP(userID string, req *models.ConfirmTOTPRequest) (*models.ConfirmTOTPResponse, error) {
+	factor, err := s.findTOTPFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if factor.ConfirmedAt != nil {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	step, err := s.matchFactorCode(factor, req.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, rows, err := generateBackupCodes(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate backup codes: %w", err)
+	}
+	confirmed, err := s.totpRepo.ConfirmFactor(userID, step, rows)
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	s.audit.Record(models.AuditTOTPEnabled, userID, "", nil)
+	return &models.ConfirmTOTPResponse{BackupCodes: codes}, nil
+}
+
+// DisableTOTP removes the authenticator after checking the PIN and a TOTP or backup code
+func (s *WalletService) DisableTOTP(userID string, req *models.DisableTOTPRequest) error {
+	if _, err := s.authorizeUser(userID, req.Pin); err != nil {
+		return err
+	}
+
+	factor, err := s.findTOTPFactor(userID)
+	if err != nil {
+		return err
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return ErrTOTPNotEnrolled
+	}
+	if err := s.verifySecondFactor(factor, req.Code); err != nil {
+		return err
+	}
+
+	if err := s.totpRepo.DeleteFactor(userID); err != nil {
+		return err
+	}
+	s.audit.Record(models.AuditTOTPDisabled, userID, "", nil)
+	return nil
+}
+
+// UpdateTOTPPolicy changes when transfers need a TOTP code. A TOTP or backup code is
+// required so a stolen session cannot relax the policy.
+func (s *WalletService) UpdateTOTPPolicy(userID string, req *models.TOTPPolicyRequest) (*models.TOTPStatusResponse, error) {
+	factor, err := s.findTOTPFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	updates := map[string]interface{}{}
+	if req.EthThreshold != nil {
+		if err := validateThreshold(*req.EthThreshold); err != nil {
+			return nil, err
+		}
+		updates["eth_threshold"] = strings.TrimSpace(*req.EthThreshold)
+	}
+	if req.TokenThreshold != nil {
+		if err := validateThreshold(*req.TokenThreshold); err != nil {
+			return nil, err
+		}
+		updates["token_threshold"] = strings.TrimSpace(*req.TokenThreshold)
+	}
+	if req.NewRecipients != nil {
+		updates["new_recipients"] = *req.NewRecipients
+	}
+
+	if err := s.verifySecondFactor(factor, req.Code); err != nil {
+		return nil, err
+	}
+	if len(updates) > 0 {
+		if err := s.totpRepo.UpdatePolicy(userID, updates); err != nil {
+			return nil, err
+		}
+		s.audit.Record(models.AuditTOTPPolicyChanged, userID, "", updates)
+	}
+
+	return s.GetTOTPStatus(userID)
+}
+
+// GetTOTPStatus describes the user's authenticator and effective transfer policy
+func (s *WalletService) GetTOTPStatus(userID string) (*models.TOTPStatusResponse, error) {
+	factor, err := s.findTOTPFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return &models.TOTPStatusResponse{}, nil
+	}
+
+	remaining, err := s.totpRepo.CountUnusedBackupCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TOTPStatusResponse{
+		Enabled:              true,
+		EthThreshold:         ethThreshold(factor),
+		TokenThreshold:       tokenThreshold(factor),
+		NewRecipients:        factor.NewRecipients,
+		BackupCodesRemaining: remaining,
+	}, nil
+}
+
+// validateThreshold accepts an empty threshold or a non-negative decimal amount
+func validateThreshold(threshold string) error {
+	if threshold == "" {
+		return nil
+	}
+	if _, err := parseAmount(threshold, 18); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidThreshold, err)
+	}
+	return nil
+}
+
+// ethThreshold returns the factor's ETH threshold or the configured default
+func ethThreshold(factor *models.TotpFactor) string {
+	if factor.EthThreshold != "" {
+		return factor.EthThreshold
+	}
+	return config.AppConfig.SecurityConfig.TOTPEthThreshold
+}
+
+// tokenThreshold returns the factor's token threshold or the configured default
+func tokenThreshold(factor *models.TotpFactor) string {
+	if factor.TokenThreshold != "" {
+		return factor.TokenThreshold
+	}
+	return config.AppConfig.SecurityConfig.TOTPTokenThreshold
+}
+
+// checkTransferFactor enforces the user's TOTP policy on a transfer of amount (in the
+// smallest unit of an asset with the given decimals) on the given chain. Users without an
+// authenticator are not affected.
+func (s *WalletService) checkTransferFactor(userID string, chainID uint64, recipient common.Address, amount *big.Int, decimals uint8, native bool, code string) error {
+	factor, err := s.findTOTPFactor(userID)
+	if err != nil {
+		return err
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return nil
+	}
+
+	threshold := tokenThreshold(factor)
+	if native {
+		threshold = ethThreshold(factor)
+	}
+	required := false
+	if threshold != "" {
+		limit, err := parseAmount(threshold, decimals)
+		if err != nil {
+			// A threshold finer than the token's decimals still means any larger amount
+			limit = big.NewInt(0)
+		}
+		required = amount.Cmp(limit) > 0
+	}
+	if !required && factor.NewRecipients {
+		sent, err := s.txRepo.HasSentTo(userID, chainID, recipient.Hex())
+		if err != nil {
+			return err
+		}
+		required = !sent
+	}
+
+	if !required {
+		return nil
+	}
+	if code == "" {
+		return ErrTOTPRequired
+	}
+	return s.verifySecondFactor(factor, code)
+}
+
+// checkFactor requires a TOTP or backup code from users with an authenticator, for
+// operations whose value the transfer thresholds can't measure
+func (s *WalletService) checkFactor(userID, code string) error {
+	factor, err := s.findTOTPFactor(userID)
+	if err != nil {
+		return err
+	}
+	if factor == nil || factor.ConfirmedAt == nil {
+		return nil
+	}
+	if code == "" {
+		return ErrTOTPRequired
+	}
+	return s.verifySecondFactor(factor, code)
+}
+
+// verifySecondFactor accepts a TOTP code once, or consumes a backup code. Failures count
+// towards the user's PIN lockout.
+func (s *WalletService) verifySecondFactor(factor *models.TotpFactor, code string) error {
+	code = strings.TrimSpace(code)
+	if isTOTPCode(code) {
+		_, err := s.matchFactorCode(factor, code)
+		return err
+	}
+
+	if err := s.pinGuard.check(userAttemptKey(factor.UserId)); err != nil {
+		return err
+	}
+	used, err := s.totpRepo.UseBackupCode(factor.UserId, hashBackupCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		s.pinGuard.RecordUserFailure(factor.UserId)
+		return ErrInvalidTOTP
+	}
+
+	s.audit.Record(models.AuditBackupCodeUsed, factor.UserId, "", nil)
+	return nil
+}
+
+// matchFactorCode checks a TOTP code against the factor and marks its time step used.
+// It returns the matching step.
+func (s *WalletService) matchFactorCode(factor *models.TotpFactor, code string) (int64, error) {
+	if err := s.pinGuard.check(userAttemptKey(factor.UserId)); err != nil {
+		return 0, err
+	}
+
+	secret, err := openTOTPSecret(factor)
+	if err != nil {
+		utils.LogError(err, "Failed to open TOTP secret", map[string]interface{}{
+			"user_id": factor.UserId,
+		})
+		return 0, err
+	}
+
+	step, ok := matchTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok || step <= factor.LastUsedStep {
+		s.pinGuard.RecordUserFailure(factor.UserId)
+		return 0, ErrInvalidTOTP
+	}
+
+	// Pending factors are confirmed at this step by ConfirmFactor instead
+	if factor.ConfirmedAt != nil {
+		fresh, err := s.totpRepo.UseStep(factor.UserId, step)
+		if err != nil {
+			return 0, err
+		}
+		if !fresh {
+			return 0, ErrInvalidTOTP
+		}
+	}
+	return step, nil
+}
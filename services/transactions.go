@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTransactionNotFound is returned when the user has no transaction with the given hash
+	ErrTransactionNotFound = errors.New("transaction not found")
+	// ErrTransactionNotPending is returned when replacing a transaction that is already mined or final
+	ErrTransactionNotPending = errors.New("transaction is no longer pending")
+)
+
+// newTransactionRecord builds a pending history record from a signed transaction.
+// Callers fill in the recipient, value and token fields.
+func newTransactionRecord(userID string, from common.Address, signedTx *types.Transaction) *models.Transaction {
+	record := &models.Transaction{
+		Id:          uuid.New().String(),
+		UserId:      userID,
+		ChainId:     signedTx.ChainId().Uint64(),
+		Hash:        signedTx.Hash().Hex(),
+		FromAddress: from.Hex(),
+		Nonce:       signedTx.Nonce(),
+		GasLimit:    signedTx.Gas(),
+		Type:        signedTx.Type(),
+		Status:      models.TxStatusPending,
+	}
+
+	if signedTx.Type() == types.LegacyTxType {
+		record.GasPrice = signedTx.GasPrice().String()
+	} else {
+		record.MaxFeePerGas = signedTx.GasFeeCap().String()
+		record.MaxPriorityFeePerGas = signedTx.GasTipCap().String()
+	}
+	if len(signedTx.Data()) > 0 {
+		record.Data = hexutil.Encode(signedTx.Data())
+	}
+
+	return record
+}
+
+// outgoingTx describes a transaction to sign and send from a user's wallet
+type outgoingTx struct {
+	userID   string
+	key      *ecdsa.PrivateKey
+	from     common.Address
+	chain    *Chain
+	to       common.Address // Destination of the transaction: the recipient or a contract
+	value    *big.Int
+	gasLimit uint64
+	fees     *txFees
+	data     []byte
+
+	// History fields
+	recipient    common.Address // Recipient of the ETH or tokens
+	amount       *big.Int       // Amount in the token's smallest unit
+	tokenSymbol  string
+	tokenAddress string
+}
+
+// signAndSend reserves a nonce, signs and records the transaction, then broadcasts it.
+// A "nonce too low" rejection resyncs the nonce from chain and retries once with a new nonce.
+func (s *WalletService) signAndSend(ctx context.Context, out *outgoingTx) (*types.Transaction, error) {
+	chainID := out.chain.ChainID()
+	signer := types.LatestSignerForChainID(chainID)
+
+	for attempt := 0; ; attempt++ {
+		var signedTx *types.Transaction
+		var record *models.Transaction
+
+		err := s.nonceManager.Reserve(ctx, out.chain, out.from, func(nonce uint64) (*models.Transaction, error) {
+			tx := newTransaction(chainID, nonce, out.to, out.value, out.gasLimit, out.fees, out.data)
+
+			var err error
+			signedTx, err = types.SignTx(tx, signer, out.key)
+			if err != nil {
+				utils.LogError(err, "Failed to sign transaction", nil)
+				return nil, fmt.Errorf("failed to sign transaction: %w", err)
+			}
+
+			record = newTransactionRecord(out.userID, out.from, signedTx)
+			record.ToAddress = out.recipient.Hex()
+			record.Value = out.amount.String()
+			record.TokenSymbol = out.tokenSymbol
+			record.TokenAddress = out.tokenAddress
+			return record, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.sendRecorded(ctx, out.chain, record, signedTx)
+		if err == nil {
+			return signedTx, nil
+		}
+		if !isNonceTooLow(err) || attempt > 0 {
+			return nil, err
+		}
+
+		if err := s.nonceManager.Resync(ctx, out.chain, out.from); err != nil {
+			utils.LogError(err, "Failed to resync nonce", map[string]interface{}{
+				"address": out.from.Hex(),
+			})
+			return nil, err
+		}
+	}
+}
+
+// broadcastTransaction stores the transaction as pending and then sends it to the network
+func (s *WalletService) broadcastTransaction(ctx context.Context, chain *Chain, record *models.Transaction, signedTx *types.Transaction) error {
+	if err := s.txRepo.CreateTransaction(record); err != nil {
+		return err
+	}
+	return s.sendRecorded(ctx, chain, record, signedTx)
+}
+
+// sendRecorded sends an already stored transaction to the network.
+// A rejected broadcast marks the stored record as failed.
+func (s *WalletService) sendRecorded(ctx context.Context, chain *Chain, record *models.Transaction, signedTx *types.Transaction) error {
+	if err := chain.client.SendTransaction(ctx, signedTx); err != nil {
+		utils.LogError(err, "Failed to send transaction", map[string]interface{}{
+			"tx_hash": record.Hash,
+		})
+		if updateErr := s.txRepo.UpdateTransactionStatus(record.Id, models.TxStatusFailed, err.Error()); updateErr != nil {
+			utils.LogError(updateErr, "Failed to mark transaction as failed", map[string]interface{}{
+				"tx_hash": record.Hash,
+			})
+		}
+		return fmt.Errorf("failed to send transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ListTransactions returns a page of the user's transaction history
+func (s *WalletService) ListTransactions(filter *models.TransactionFilter) (*models.TransactionListResponse, error) {
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize == 0 {
+		filter.PageSize = 20
+	}
+	if filter.Chain != "" {
+		chain, err := s.chains.Resolve(filter.Chain)
+		if err != nil {
+			return nil, err
+		}
+		filter.ChainId = chain.ID
+	}
+
+	transactions, total, err := s.txRepo.ListTransactions(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TransactionListResponse{
+		Transactions: transactions,
+		Page:         filter.Page,
+		PageSize:     filter.PageSize,
+		Total:        total,
+	}, nil
+}
+
+// cancelGasLimit is the gas used by the plain 0-value self-transfer that cancels a transaction
+const cancelGasLimit = uint64(21000)
+
+// ReplaceTransaction re-signs the nonce of a pending transaction with bumped fees. With cancel
+// set the replacement is a 0-value transfer to the sender itself, otherwise it repeats the
+// original call. The replacement is recorded with a link to the original and its hash returned.
+func (s *WalletService) ReplaceTransaction(userID, hash string, req *models.ReplaceTransactionRequest, cancel bool) (string, error) {
+	ctx := context.Background()
+
+	original, err := s.txRepo.FindUserTransactionByHash(userID, hash)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", ErrTransactionNotFound
+		}
+		return "", err
+	}
+	if original.Status != models.TxStatusPending || original.BlockNumber != nil {
+		return "", ErrTransactionNotPending
+	}
+
+	account, privKey, err := s.unlockAccount(userID, req.Pin, original.FromAddress)
+	if err != nil {
+		return "", err
+	}
+	fromAddress := common.HexToAddress(account.Address)
+
+	chain, err := s.chains.ByID(original.ChainId)
+	if err != nil {
+		return "", err
+	}
+	chainID := chain.ChainID()
+
+	// Replacements default to the fast tier and always outbid the original
+	tierName := req.FeeTier
+	if tierName == "" {
+		tierName = string(FeeTierFast)
+	}
+	feeTier, err := parseFeeTier(tierName)
+	if err != nil {
+		return "", err
+	}
+	suggested, err := suggestFees(ctx, chain, feeTier)
+	if err != nil {
+		utils.LogError(err, "Failed to get transaction fees", nil)
+		return "", err
+	}
+	fees := replacementFees(original, suggested)
+
+	// Rebuild the original call, or a 0-value self-transfer to cancel it
+	to, value, gasLimit, data := fromAddress, big.NewInt(0), cancelGasLimit, []byte(nil)
+	if !cancel {
+		gasLimit = original.GasLimit
+		if original.TokenAddress != "" {
+			to = common.HexToAddress(original.TokenAddress)
+		} else {
+			to = common.HexToAddress(original.ToAddress)
+			value, _ = new(big.Int).SetString(original.Value, 10)
+		}
+		if original.Data != "" {
+			if data, err = hexutil.Decode(original.Data); err != nil {
+				return "", fmt.Errorf("failed to decode transaction data: %w", err)
+			}
+		}
+	}
+
+	tx := newTransaction(chainID, original.Nonce, to, value, gasLimit, fees, data)
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), privKey)
+	if err != nil {
+		utils.LogError(err, "Failed to sign transaction", nil)
+		return "", fmt.Errorf("failed to sign transaction: %w", err)
+	}
+
+	record := newTransactionRecord(userID, fromAddress, signedTx)
+	record.ReplacesHash = original.Hash
+	if cancel {
+		record.ToAddress = fromAddress.Hex()
+		record.Value = "0"
+		record.TokenSymbol = chain.NativeSymbol
+	} else {
+		record.ToAddress = original.ToAddress
+		record.Value = original.Value
+		record.TokenSymbol = original.TokenSymbol
+		record.TokenAddress = original.TokenAddress
+	}
+
+	if err := s.broadcastTransaction(ctx, chain, record, signedTx); err != nil {
+		return "", err
+	}
+	if err := s.txRepo.MarkTransactionReplaced(original.Id, record.Hash); err != nil {
+		return "", err
+	}
+
+	utils.LogInfo("Transaction replaced", map[string]interface{}{
+		"original":    original.Hash,
+		"replacement": record.Hash,
+		"nonce":       original.Nonce,
+		"cancel":      cancel,
+	})
+
+	return record.Hash, nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"test-wallet/config"
+	"test-wallet/models"
+	"test-wallet/repository"
+	"test-wallet/utils"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"gorm.io/gorm"
+)
+
+// trackerBatchSize caps how many pending transactions are checked per poll
+const trackerBatchSize = 100
+
+// TxTracker polls receipts of pending transactions and moves them to
+// confirmed, failed, dropped or replaced once the outcome is final
+type TxTracker struct {
+	txRepo        *repository.TransactionRepository
+	chains        *ChainRegistry
+	interval      time.Duration
+	confirmations uint64
+	// cursor is the ID of the last transaction checked; the next poll continues after it
+	cursor string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewTxTracker(conn *gorm.DB, chains *ChainRegistry) (*TxTracker, error) {
+	if chains == nil {
+		return nil, errors.New("chains are not initialized")
+	}
+
+	interval := config.AppConfig.EthConfig.ReceiptPollInterval
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	confirmations := config.AppConfig.EthConfig.Confirmations
+	if confirmations == 0 {
+		confirmations = 1
+	}
+
+	return &TxTracker{
+		txRepo:        repository.NewTransactionRepository(conn),
+		chains:        chains,
+		interval:      interval,
+		confirmations: confirmations,
+	}, nil
+}
+
+// Start runs the polling loop in a goroutine until Stop is called
+func (t *TxTracker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.done = make(chan struct{})
+
+	go func() {
+		defer close(t.done)
+
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+
+		utils.LogInfo("Transaction tracker started", map[string]interface{}{
+			"interval":      t.interval.String(),
+			"confirmations": t.confirmations,
+		})
+
+		for {
+			t.poll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop signals the polling loop to exit and waits for the current poll to finish
+func (t *TxTracker) Stop(ctx context.Context) error {
+	if t.cancel == nil {
+		return nil
+	}
+	t.cancel()
+
+	select {
+	case <-t.done:
+		utils.LogInfo("Transaction tracker stopped", nil)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("transaction tracker did not stop: %w", ctx.Err())
+	}
+}
+
+// poll checks the next batch of pending transactions against their chains. Batches follow
+// each other through all pending transactions, so ones that stay pending can't starve the rest.
+func (t *TxTracker) poll(ctx context.Context) {
+	chains := t.chains.List()
+	chainIDs := make([]uint64, 0, len(chains))
+	for _, chain := range chains {
+		chainIDs = append(chainIDs, chain.ID)
+	}
+
+	pending, err := t.txRepo.ListPendingTransactions(chainIDs, t.cursor, trackerBatchSize)
+	if err != nil {
+		return
+	}
+	if len(pending) < trackerBatchSize {
+		t.cursor = ""
+	} else {
+		t.cursor = pending[len(pending)-1].Id
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	// Each chain is read through one endpoint for the whole poll, so that its head, receipts
+	// and nonces come from the same node; the head is fetched once per poll. A chain whose head
+	// can't be read is skipped until the next poll.
+	clients := make(map[uint64]ChainBackend)
+	heads := make(map[uint64]uint64)
+	failed := make(map[uint64]bool)
+
+	for i := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+
+		chain, err := t.chains.ByID(pending[i].ChainId)
+		if err != nil || failed[chain.ID] {
+			continue
+		}
+		client, ok := clients[chain.ID]
+		if !ok {
+			client = chain.pinned()
+			head, err := client.BlockNumber(ctx)
+			if err != nil {
+				utils.LogError(err, "Failed to get latest block number", map[string]interface{}{
+					"chain": chain.Name,
+				})
+				failed[chain.ID] = true
+				continue
+			}
+			clients[chain.ID] = client
+			heads[chain.ID] = head
+		}
+
+		if err := t.checkTransaction(ctx, client, heads[chain.ID], &pending[i]); err != nil {
+			utils.LogError(err, "Failed to check transaction", map[string]interface{}{
+				"tx_hash": pending[i].Hash,
+			})
+		}
+	}
+}
+
+// checkTransaction updates a single pending transaction if its outcome is known
+func (t *TxTracker) checkTransaction(ctx context.Context, client ChainBackend, head uint64, tx *models.Transaction) error {
+	hash := common.HexToHash(tx.Hash)
+
+	receipt, err := client.TransactionReceipt(ctx, hash)
+	if err != nil && !errors.Is(err, ethereum.NotFound) {
+		return fmt.Errorf("failed to get receipt: %w", err)
+	}
+
+	if receipt == nil {
+		return t.checkDropped(ctx, client, tx)
+	}
+
+	blockNumber := receipt.BlockNumber.Uint64()
+	if head+1 < blockNumber+t.confirmations {
+		// Mined but not deep enough yet; keep the block number for visibility
+		if tx.BlockNumber == nil || *tx.BlockNumber != blockNumber {
+			return t.txRepo.UpdateTransactionReceipt(tx.Id, models.TxStatusPending, blockNumber, "")
+		}
+		return nil
+	}
+
+	status, errMsg := models.TxStatusConfirmed, ""
+	if receipt.Status == types.ReceiptStatusFailed {
+		status, errMsg = models.TxStatusFailed, "execution reverted"
+	}
+
+	utils.LogInfo("Transaction finalized", map[string]interface{}{
+		"tx_hash":      tx.Hash,
+		"status":       status,
+		"block_number": blockNumber,
+	})
+
+	return t.txRepo.UpdateTransactionReceipt(tx.Id, status, blockNumber, errMsg)
+}
+
+// checkDropped marks a transaction without a receipt as dropped once a
+// transaction with the same nonce from the same sender has been mined
+func (t *TxTracker) checkDropped(ctx context.Context, client ChainBackend, tx *models.Transaction) error {
+	minedNonce, err := client.NonceAt(ctx, common.HexToAddress(tx.FromAddress), nil)
+	if err != nil {
+		return fmt.Errorf("failed to get account nonce: %w", err)
+	}
+	if minedNonce <= tx.Nonce {
+		return nil
+	}
+
+	// The receipt may have appeared between the two calls
+	receipt, err := client.TransactionReceipt(ctx, common.HexToHash(tx.Hash))
+	if err != nil && !errors.Is(err, ethereum.NotFound) {
+		return fmt.Errorf("failed to get receipt: %w", err)
+	}
+	if receipt != nil {
+		return nil
+	}
+
+	if tx.ReplacedByHash != "" {
+		utils.LogInfo("Transaction replaced", map[string]interface{}{
+			"tx_hash":     tx.Hash,
+			"replacement": tx.ReplacedByHash,
+		})
+		return t.txRepo.UpdateTransactionStatus(tx.Id, models.TxStatusReplaced, "")
+	}
+
+	utils.LogInfo("Transaction dropped", map[string]interface{}{
+		"tx_hash": tx.Hash,
+		"nonce":   tx.Nonce,
+	})
+
+	return t.txRepo.UpdateTransactionStatus(tx.Id, models.TxStatusDropped, "nonce used by another transaction")
+}
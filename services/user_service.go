@@ -1,94 +1,144 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"test-wallet/middleware"
+	"strings"
+	"test-wallet/config"
 	"test-wallet/models"
 	"test-wallet/repository"
 	"test-wallet/utils"
+	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
+// ErrPhoneNotVerified is returned when a pending user logs in before verifying their phone number
+var ErrPhoneNotVerified = errors.New("phone number is not verified")
+
+// UserStore persists users and their wallets. repository.UserRepository implements it.
+type UserStore interface {
+	PhoneNumberExists(phoneNumber string) (bool, error)
+	CreateUser(user *models.User) error
+	FindUserByPhoneNumber(phoneNumber string) (*models.User, error)
+	FindUserByID(userID string) (*models.User, error)
+	UpdatePinAndMnemonic(user *models.User) error
+	ReplaceWallet(wallet *models.Wallet) error
+	UpdateWalletEncryption(wallet *models.Wallet, previous string) (bool, error)
+	ActivateUser(userID string) (bool, error)
+	DeletePendingUser(userID string) error
+}
+
 type UserService struct {
-	userRepo      *repository.UserRepository
-	qrService     *QRService
-	walletService *WalletService
+	userRepo       UserStore
+	qrService      *QRService
+	walletService  *WalletService
+	sessionService *SessionService
+	pinGuard       *PinGuard
+	otpService     *OTPService
+	accountRepo    *repository.AccountRepository
+	siweRepo       *repository.SiweRepository
 }
 
-func NewUserService() (*UserService, error) {
-	walletService, err := NewWalletService()
+func NewUserService(users UserStore, conn *gorm.DB, chains *ChainRegistry) (*UserService, error) {
+	walletService, err := NewWalletService(users, conn, chains)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create wallet service: %w", err)
 	}
 
 	return &UserService{
-		userRepo:      repository.NewUserRepository(),
-		qrService:     NewQRService(),
-		walletService: walletService,
+		userRepo:       users,
+		qrService:      walletService.qrService,
+		walletService:  walletService,
+		sessionService: NewSessionService(conn),
+		pinGuard:       walletService.pinGuard,
+		otpService:     NewOTPService(conn),
+		accountRepo:    repository.NewAccountRepository(conn),
+		siweRepo:       repository.NewSiweRepository(conn),
 	}, nil
 }
 
+// RegisterUser creates a pending user with a new or imported wallet and texts a code to
+// the phone number. The account becomes active once the code is verified.
 func (s *UserService) RegisterUser(req *models.RegisterUserRequest) (*models.User, error) {
+	phoneNumber, err := normalizePhoneNumber(req.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check if phone number already exists
-	exists, err := s.userRepo.PhoneNumberExists(req.PhoneNumber)
+	exists, err := s.userRepo.PhoneNumberExists(phoneNumber)
 	if err != nil {
 		utils.LogError(err, "Failed to check phone number availability", map[string]interface{}{
-			"phone_number": req.PhoneNumber,
+			"phone_number": phoneNumber,
 		})
 		return nil, errors.New("failed to check phone number availability")
 	}
-	if exists {
+	if exists && !s.releaseUnverifiedNumber(phoneNumber) {
 		utils.LogInfo("Phone number already registered", map[string]interface{}{
-			"phone_number": req.PhoneNumber,
+			"phone_number": phoneNumber,
 		})
 		return nil, errors.New("phone number is already registered")
 	}
 
-	// Generate salt
-	salt, err := GenerateSalt(16)
-	if err != nil {
-		utils.LogError(err, "Failed to generate salt", nil)
-		return nil, errors.New("failed to generate salt")
-	}
-
-	// Hash the PIN with the salt
-	hashedPin, err := bcrypt.GenerateFromPassword([]byte(req.Pin+salt), bcrypt.DefaultCost)
+	// Hash the PIN with a fresh salt
+	hashedPin, salt, err := hashPin(req.Pin)
 	if err != nil {
 		utils.LogError(err, "Failed to hash PIN", nil)
 		return nil, errors.New("failed to hash PIN")
 	}
 
-	// Create a new Ethereum wallet
-	walletResponse, err := s.walletService.CreateWallet()
-	if err != nil {
-		utils.LogError(err, "Failed to create wallet", nil)
-		return nil, errors.New("failed to create wallet")
-	}
-
-	// Encrypt the mnemonic with the user's PIN
-	encryptedMnemonic, err := Encrypt(req.Pin, walletResponse.Mnemonic)
-	if err != nil {
-		utils.LogError(err, "Failed to encrypt wallet mnemonic", nil)
-		return nil, errors.New("failed to encrypt wallet mnemonic")
+	// Import the supplied wallet, or create a new Ethereum wallet
+	var secret *walletSecret
+	if hasWalletImport(&req.WalletImport) {
+		secret, err = parseWalletImport(&req.WalletImport)
+		if err != nil {
+			utils.LogError(err, "Failed to import wallet", nil)
+			return nil, err
+		}
+	} else {
+		walletResponse, err := s.walletService.CreateWallet()
+		if err != nil {
+			utils.LogError(err, "Failed to create wallet", nil)
+			return nil, errors.New("failed to create wallet")
+		}
+		secret = &walletSecret{
+			Type:    models.WalletTypeMnemonic,
+			Secret:  walletResponse.Mnemonic,
+			Address: walletResponse.Address,
+		}
 	}
 
 	// Create a new user record
 	newUser := &models.User{
 		Id:          uuid.New().String(),
 		Name:        req.Name,
-		PhoneNumber: req.PhoneNumber,
-		Pin:         string(hashedPin),
+		PhoneNumber: phoneNumber,
+		Pin:         hashedPin,
 		Salt:        salt,
+		Status:      models.UserStatusPending,
 		Wallet: models.Wallet{
-			Id:       uuid.New().String(),
-			Address:  walletResponse.Address,
-			Mnemonic: encryptedMnemonic,
+			Id:      uuid.New().String(),
+			Type:    secret.Type,
+			Address: secret.Address,
 		},
 	}
 
+	// Encrypt the mnemonic or private key with the user's PIN and a wrapped data key
+	if err := sealWallet(&newUser.Wallet, req.Pin, secret.Secret); err != nil {
+		utils.LogError(err, "Failed to encrypt wallet mnemonic", nil)
+		return nil, errors.New("failed to encrypt wallet mnemonic")
+	}
+	newUser.Wallet.Accounts = []models.Account{{
+		Id:      uuid.New().String(),
+		UserId:  newUser.Id,
+		Name:    models.DefaultAccountName,
+		Index:   0,
+		Address: secret.Address,
+	}}
+
 	// Generate and store QR code
 	if err := s.qrService.GenerateAndStoreQR(&newUser.Wallet); err != nil {
 		utils.LogError(err, "Failed to generate QR code", map[string]interface{}{
@@ -110,40 +160,191 @@ func (s *UserService) RegisterUser(req *models.RegisterUserRequest) (*models.Use
 		"wallet":  newUser.Wallet.Address,
 	})
 
+	// A failed SMS does not undo the registration; the user can ask for another code
+	if err := s.otpService.Send(context.Background(), phoneNumber, models.OTPPurposeVerifyPhone); err != nil {
+		utils.LogError(err, "Failed to send verification code", map[string]interface{}{
+			"user_id": newUser.Id,
+		})
+	}
+
 	return newUser, nil
 }
 
-func (s *UserService) LoginUser(req *models.LoginRequest) (string, *models.User, error) {
+// releaseUnverifiedNumber deletes the pending user holding the phone number once their
+// verification code has expired, so an unproven registration cannot squat the number.
+// It reports whether the number is free.
+func (s *UserService) releaseUnverifiedNumber(phoneNumber string) bool {
+	user, err := s.userRepo.FindUserByPhoneNumber(phoneNumber)
+	if err != nil || user.Status != models.UserStatusPending {
+		return false
+	}
+	if time.Since(user.CreatedAt) < config.AppConfig.SecurityConfig.OTPExpiration {
+		return false
+	}
+	return s.userRepo.DeletePendingUser(user.Id) == nil
+}
+
+// findUserByPhone looks a user up by the E.164 form of the phone number, falling back to
+// the number as given for users registered before numbers were normalized
+func (s *UserService) findUserByPhone(phoneNumber string) (*models.User, error) {
+	normalized, err := normalizePhoneNumber(phoneNumber)
+	if err == nil {
+		user, err := s.userRepo.FindUserByPhoneNumber(normalized)
+		if err == nil || normalized == phoneNumber {
+			return user, err
+		}
+	}
+	return s.userRepo.FindUserByPhoneNumber(phoneNumber)
+}
+
+// VerifyPhone checks the code sent at registration and activates the account
+func (s *UserService) VerifyPhone(req *models.VerifyPhoneRequest) error {
+	phoneNumber, err := normalizePhoneNumber(req.PhoneNumber)
+	if err != nil {
+		return err
+	}
+
+	if err := s.otpService.Verify(phoneNumber, models.OTPPurposeVerifyPhone, req.Code); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindUserByPhoneNumber(phoneNumber)
+	if err != nil {
+		return ErrInvalidOTP
+	}
+	if _, err := s.userRepo.ActivateUser(user.Id); err != nil {
+		return err
+	}
+
+	utils.LogInfo("Phone number verified", map[string]interface{}{
+		"user_id": user.Id,
+	})
+	return nil
+}
+
+// ResendVerification texts a new code to a pending account. Unknown and already verified
+// numbers are ignored so the endpoint does not reveal which numbers are registered.
+func (s *UserService) ResendVerification(req *models.ResendOTPRequest) error {
+	phoneNumber, err := normalizePhoneNumber(req.PhoneNumber)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindUserByPhoneNumber(phoneNumber)
+	if err != nil || user.Status != models.UserStatusPending {
+		return nil
+	}
+
+	return s.otpService.Send(context.Background(), phoneNumber, models.OTPPurposeVerifyPhone)
+}
+
+func (s *UserService) LoginUser(req *models.LoginRequest, client models.ClientInfo) (*models.TokenPair, *models.User, error) {
 	// Find user by phone number
-	user, err := s.userRepo.FindUserByPhoneNumber(req.PhoneNumber)
+	user, err := s.findUserByPhone(req.PhoneNumber)
 	if err != nil {
 		utils.LogError(err, "User not found", map[string]interface{}{
 			"phone_number": req.PhoneNumber,
 		})
-		return "", nil, errors.New("invalid phone number or PIN")
+		return nil, nil, errors.New("invalid phone number or PIN")
 	}
 
 	// Verify PIN
-	err = bcrypt.CompareHashAndPassword([]byte(user.Pin), []byte(req.Pin+user.Salt))
-	if err != nil {
+	if err := s.pinGuard.Verify(user, req.Pin); err != nil {
 		utils.LogError(err, "Invalid PIN", map[string]interface{}{
 			"user_id": user.Id,
 		})
-		return "", nil, errors.New("invalid phone number or PIN")
+		return nil, nil, pinError(err, errors.New("invalid phone number or PIN"))
+	}
+	if user.Status == models.UserStatusPending {
+		return nil, nil, ErrPhoneNotVerified
 	}
+	upgradeWalletEncryption(s.userRepo, user, req.Pin, "")
 
-	// Generate JWT token
-	token, err := middleware.GenerateToken(user.Id)
+	// Start a session with an access token and a refresh token
+	tokens, err := s.sessionService.CreateSession(user.Id, client)
 	if err != nil {
-		utils.LogError(err, "Failed to generate JWT token", map[string]interface{}{
+		utils.LogError(err, "Failed to create session", map[string]interface{}{
 			"user_id": user.Id,
 		})
-		return "", nil, errors.New("failed to generate authentication token")
+		return nil, nil, errors.New("failed to generate authentication token")
 	}
 
 	utils.LogInfo("User logged in successfully", map[string]interface{}{
 		"user_id": user.Id,
 	})
 
-	return token, user, nil
+	return tokens, user, nil
+}
+
+// ResetPin replaces the user's PIN and re-encrypts the wallet mnemonic under the new PIN.
+// Ownership is proven either with the current PIN or with the wallet mnemonic itself.
+func (s *UserService) ResetPin(req *models.ResetPinRequest) error {
+	user, err := s.findUserByPhone(req.PhoneNumber)
+	if err != nil {
+		utils.LogError(err, "User not found", map[string]interface{}{
+			"phone_number": req.PhoneNumber,
+		})
+		return ErrInvalidCredentials
+	}
+
+	var mnemonic string
+	if req.OldPin != "" {
+		// Change flow: the current PIN unlocks the stored mnemonic
+		if err := s.pinGuard.Verify(user, req.OldPin); err != nil {
+			utils.LogError(err, "Invalid PIN", map[string]interface{}{
+				"user_id": user.Id,
+			})
+			return pinError(err, ErrInvalidCredentials)
+		}
+
+		mnemonic, err = openWallet(&user.Wallet, req.OldPin)
+		if err != nil {
+			utils.LogError(err, "Failed to decrypt mnemonic", map[string]interface{}{
+				"user_id": user.Id,
+			})
+			return fmt.Errorf("failed to decrypt mnemonic: %w", err)
+		}
+	} else {
+		// Forgotten flow: the mnemonic (or imported key) must control the stored wallet. The
+		// normalized secret is sealed, e.g. a private key without its 0x prefix.
+		secret, err := parseWalletSecret(user.Wallet.Type, req.Mnemonic)
+		if err != nil || !strings.EqualFold(secret.Address, user.Wallet.Address) {
+			utils.LogError(err, "Mnemonic does not match wallet", map[string]interface{}{
+				"user_id": user.Id,
+			})
+			return ErrInvalidCredentials
+		}
+		mnemonic = secret.Secret
+	}
+
+	hashedPin, salt, err := hashPin(req.NewPin)
+	if err != nil {
+		utils.LogError(err, "Failed to hash PIN", nil)
+		return errors.New("failed to hash PIN")
+	}
+
+	if err := sealWallet(&user.Wallet, req.NewPin, mnemonic); err != nil {
+		utils.LogError(err, "Failed to encrypt wallet mnemonic", nil)
+		return errors.New("failed to encrypt wallet mnemonic")
+	}
+
+	user.Pin = hashedPin
+	user.Salt = salt
+
+	if err := s.userRepo.UpdatePinAndMnemonic(user); err != nil {
+		return fmt.Errorf("failed to reset PIN: %w", err)
+	}
+	// A new PIN ends any backoff or lockout on the old one, and signs out every device
+	s.pinGuard.Reset(user.Id)
+	if err := s.sessionService.RevokeAllSessions(user.Id); err != nil {
+		utils.LogError(err, "Failed to revoke sessions after PIN reset", map[string]interface{}{
+			"user_id": user.Id,
+		})
+	}
+
+	utils.LogInfo("PIN reset successfully", map[string]interface{}{
+		"user_id": user.Id,
+	})
+
+	return nil
 }
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// ErrExportNotConfirmed is returned when a key export is requested without confirm=true
+var ErrExportNotConfirmed = errors.New("key export must be explicitly confirmed")
+
+// ExportKeystore returns the private key of one of the user's accounts as a Keystore V3
+// file encrypted with the caller's password. The raw key is never returned.
+func (s *WalletService) ExportKeystore(userID string, req *models.ExportWalletRequest) (*models.ExportWalletResponse, error) {
+	if !req.Confirm {
+		return nil, ErrExportNotConfirmed
+	}
+
+	account, privKey, err := s.unlockAccount(userID, req.Pin, req.FromAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	// The exported key controls the account outright, so always ask for the second factor
+	if err := s.checkFactor(userID, req.TotpCode); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate keystore id: %w", err)
+	}
+	key := &keystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(privKey.PublicKey),
+		PrivateKey: privKey,
+	}
+
+	keyJSON, err := keystore.EncryptKey(key, req.Password, keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		utils.LogError(err, "Failed to encrypt keystore", map[string]interface{}{
+			"user_id": userID,
+		})
+		return nil, fmt.Errorf("failed to encrypt keystore: %w", err)
+	}
+
+	utils.LogInfo("Wallet key exported", map[string]interface{}{
+		"user_id": userID,
+		"address": account.Address,
+	})
+
+	return &models.ExportWalletResponse{
+		Address:  account.Address,
+		Keystore: keyJSON,
+	}, nil
+}
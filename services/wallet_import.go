@@ -0,0 +1,222 @@
+package services
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"test-wallet/models"
+	"test-wallet/utils"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/tyler-smith/go-bip39"
+)
+
+var (
+	// ErrInvalidWalletImport is returned when an imported mnemonic, key or keystore cannot be used
+	ErrInvalidWalletImport = errors.New("invalid wallet import")
+	// ErrWalletExists is returned when recovery would replace a different wallet without consent
+	ErrWalletExists = errors.New("a different wallet is attached; set replace_existing to replace it")
+)
+
+// mnemonicWordCounts are the BIP-39 mnemonic lengths accepted for import
+var mnemonicWordCounts = map[int]bool{12: true, 15: true, 18: true, 21: true, 24: true}
+
+// walletSecret is a decrypted wallet secret together with the address it controls
+type walletSecret struct {
+	Type    string // models.WalletTypeMnemonic or models.WalletTypePrivateKey
+	Secret  string // Normalized mnemonic or hex private key without 0x prefix
+	Address string // Address of account index 0
+}
+
+// hasWalletImport reports whether any import field is set
+func hasWalletImport(imp *models.WalletImport) bool {
+	return imp.Mnemonic != "" || imp.PrivateKey != "" || hasKeystore(imp)
+}
+
+// hasKeystore reports whether a keystore was supplied
+func hasKeystore(imp *models.WalletImport) bool {
+	return len(imp.Keystore) > 0 && string(imp.Keystore) != "null"
+}
+
+// parseWalletImport validates an imported mnemonic, private key or keystore
+func parseWalletImport(imp *models.WalletImport) (*walletSecret, error) {
+	provided := 0
+	for _, set := range []bool{imp.Mnemonic != "", imp.PrivateKey != "", hasKeystore(imp)} {
+		if set {
+			provided++
+		}
+	}
+	if provided != 1 {
+		return nil, fmt.Errorf("%w: provide exactly one of mnemonic, private_key or keystore", ErrInvalidWalletImport)
+	}
+
+	switch {
+	case imp.Mnemonic != "":
+		return parseMnemonic(imp.Mnemonic)
+	case imp.PrivateKey != "":
+		return parsePrivateKey(imp.PrivateKey)
+	default:
+		return parseKeystore(imp.Keystore, imp.KeystorePassword)
+	}
+}
+
+// parseMnemonic normalizes a mnemonic and validates its length and checksum
+func parseMnemonic(mnemonic string) (*walletSecret, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if !mnemonicWordCounts[len(words)] {
+		return nil, fmt.Errorf("%w: mnemonic must have 12, 15, 18, 21 or 24 words", ErrInvalidWalletImport)
+	}
+
+	normalized := strings.Join(words, " ")
+	if !bip39.IsMnemonicValid(normalized) {
+		return nil, fmt.Errorf("%w: mnemonic checksum is invalid", ErrInvalidWalletImport)
+	}
+
+	address, _, err := RecoverWalletFromMnemonic(normalized, DefaultDerivationPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWalletImport, err)
+	}
+
+	return &walletSecret{Type: models.WalletTypeMnemonic, Secret: normalized, Address: address}, nil
+}
+
+// parsePrivateKey validates a hex-encoded secp256k1 private key
+func parsePrivateKey(privateKey string) (*walletSecret, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(privateKey), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid private key", ErrInvalidWalletImport)
+	}
+
+	return &walletSecret{
+		Type:    models.WalletTypePrivateKey,
+		Secret:  hex.EncodeToString(crypto.FromECDSA(key)),
+		Address: crypto.PubkeyToAddress(key.PublicKey).Hex(),
+	}, nil
+}
+
+// parseKeystore decrypts a Keystore V3 JSON file, given either as an object or a JSON string
+func parseKeystore(raw json.RawMessage, password string) (*walletSecret, error) {
+	keyJSON := []byte(raw)
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		keyJSON = []byte(encoded)
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decrypt keystore: %v", ErrInvalidWalletImport, err)
+	}
+
+	return &walletSecret{
+		Type:    models.WalletTypePrivateKey,
+		Secret:  hex.EncodeToString(crypto.FromECDSA(key.PrivateKey)),
+		Address: key.Address.Hex(),
+	}, nil
+}
+
+// parseWalletSecret validates and normalizes a mnemonic or private key given for a wallet of
+// the given type, so that it is stored in the form unlockAccount expects
+func parseWalletSecret(walletType, secret string) (*walletSecret, error) {
+	if walletType == models.WalletTypePrivateKey {
+		return parsePrivateKey(secret)
+	}
+	return parseMnemonic(secret)
+}
+
+// secretAddress returns the address of account index 0 for a decrypted wallet secret
+func secretAddress(walletType, secret string) (string, error) {
+	if walletType == models.WalletTypePrivateKey {
+		parsed, err := parsePrivateKey(secret)
+		if err != nil {
+			return "", err
+		}
+		return parsed.Address, nil
+	}
+
+	address, _, err := RecoverWalletFromMnemonic(strings.Join(strings.Fields(secret), " "), DefaultDerivationPath)
+	return address, err
+}
+
+// ImportWallet replaces the user's wallet with an imported mnemonic, private key or keystore.
+// The secret is encrypted under the user's PIN and the accounts reset to the default account.
+func (s *WalletService) ImportWallet(userID string, req *models.ImportWalletRequest) (*models.Wallet, error) {
+	if !req.ReplaceExisting {
+		return nil, fmt.Errorf("%w: replace_existing must be true to detach the current wallet", ErrInvalidWalletImport)
+	}
+
+	secret, err := parseWalletImport(&req.WalletImport)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.authorizeUser(userID, req.Pin)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.attachWallet(user, req.Pin, secret)
+}
+
+// RecoverWallet re-attaches a wallet to the user from its mnemonic. The mnemonic is
+// encrypted under the user's PIN; replacing a different wallet requires ReplaceExisting.
+func (s *WalletService) RecoverWallet(userID string, req *models.RecoverWalletRequest) (*models.Wallet, error) {
+	secret, err := parseMnemonic(req.Mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.authorizeUser(userID, req.Pin)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(user.Wallet.Address, secret.Address) && !req.ReplaceExisting {
+		return nil, ErrWalletExists
+	}
+
+	return s.attachWallet(user, req.Pin, secret)
+}
+
+// attachWallet encrypts the secret under the PIN and stores it as the user's wallet.
+// Accounts are kept when the secret belongs to the current wallet, otherwise they
+// are reset to the default account.
+func (s *WalletService) attachWallet(user *models.User, pin string, secret *walletSecret) (*models.Wallet, error) {
+	wallet := user.Wallet
+	if err := sealWallet(&wallet, pin, secret.Secret); err != nil {
+		utils.LogError(err, "Failed to encrypt wallet secret", nil)
+		return nil, errors.New("failed to encrypt wallet secret")
+	}
+
+	sameWallet := wallet.Type == secret.Type && strings.EqualFold(wallet.Address, secret.Address)
+	wallet.Type = secret.Type
+	wallet.Address = secret.Address
+	if !sameWallet || len(wallet.Accounts) == 0 {
+		wallet.Accounts = []models.Account{{
+			Id:       uuid.New().String(),
+			WalletId: wallet.Id,
+			UserId:   user.Id,
+			Name:     models.DefaultAccountName,
+			Index:    0,
+			Address:  secret.Address,
+		}}
+	}
+
+	if err := s.qrService.GenerateAndStoreQR(&wallet); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.ReplaceWallet(&wallet); err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo("Wallet attached", map[string]interface{}{
+		"user_id": user.Id,
+		"type":    wallet.Type,
+		"address": wallet.Address,
+	})
+
+	return &wallet, nil
+}
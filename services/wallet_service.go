@@ -2,43 +2,60 @@ package services
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"math/big"
-	"strings"
-	"test-wallet/config"
 	"test-wallet/models"
 	"test-wallet/repository"
 	"test-wallet/utils"
 
 	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gin-gonic/gin"
 	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type WalletService struct {
-	userRepo *repository.UserRepository
-	client   *ethclient.Client
+	userRepo     UserStore
+	chains       *ChainRegistry
+	accountRepo  *repository.AccountRepository
+	qrService    *QRService
+	txRepo       *repository.TransactionRepository
+	tokenService *TokenService
+	nonceManager *NonceManager
+	pinGuard     *PinGuard
+	totpRepo     *repository.TotpRepository
+	contractRepo *repository.ContractRepository
+	audit        *AuditService
 }
 
-func NewWalletService() (*WalletService, error) {
-	client, err := ethclient.Dial(config.AppConfig.EthConfig.InfuraURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ethereum client: %w", err)
+func NewWalletService(users UserStore, conn *gorm.DB, chains *ChainRegistry) (*WalletService, error) {
+	if chains == nil {
+		return nil, errors.New("chains are not initialized")
 	}
 
 	return &WalletService{
-		userRepo: repository.NewUserRepository(),
-		client:   client,
+		userRepo:     users,
+		chains:       chains,
+		accountRepo:  repository.NewAccountRepository(conn),
+		qrService:    NewQRService(users),
+		txRepo:       repository.NewTransactionRepository(conn),
+		tokenService: NewTokenService(conn, chains),
+		nonceManager: NewNonceManager(conn, chains),
+		pinGuard:     NewPinGuard(conn),
+		totpRepo:     repository.NewTotpRepository(conn),
+		contractRepo: repository.NewContractRepository(conn),
+		audit:        NewAuditService(conn),
 	}, nil
 }
 
+// SeedTokenRegistry loads the configured tokens of every chain into the token registry
+func SeedTokenRegistry(ctx context.Context, conn *gorm.DB, chains *ChainRegistry) error {
+	return NewTokenService(conn, chains).SeedTokens(ctx)
+}
+
 func (s *WalletService) CreateWallet() (*models.CreateWalletResponse, error) {
 	// Generate a random mnemonic (12-word by default)
 	mnemonic, err := hdwallet.NewMnemonic(128)
@@ -53,7 +70,7 @@ func (s *WalletService) CreateWallet() (*models.CreateWalletResponse, error) {
 	}
 
 	// Derive a path (you can customize this for multiple accounts)
-	path := hdwallet.MustParseDerivationPath("m/44'/60'/0'/0/0")
+	path := hdwallet.MustParseDerivationPath(DefaultDerivationPath)
 	account, err := wallet.Derive(path, false)
 	if err != nil {
 		return nil, err
@@ -106,10 +123,15 @@ func (s *WalletService) GetUserWallet(userID string) (*models.User, error) {
 	return user, nil
 }
 
-// GetBalance retrieves the ETH balance of a given address
-func (s *WalletService) GetBalance(c *gin.Context, address string) (string, error) {
+// GetBalance retrieves the native balance of a given address on the selected chain
+func (s *WalletService) GetBalance(c *gin.Context, address, chainSelector string) (string, error) {
+	chain, err := s.chains.Resolve(chainSelector)
+	if err != nil {
+		return "", err
+	}
+
 	addr := common.HexToAddress(address)
-	balance, err := s.client.BalanceAt(c, addr, nil)
+	balance, err := chain.client.BalanceAt(c, addr, nil)
 	if err != nil {
 		utils.LogError(err, "Failed to get balance", map[string]interface{}{
 			"address": address,
@@ -128,119 +150,102 @@ func (s *WalletService) GetBalance(c *gin.Context, address string) (string, erro
 	return ethBalance.String(), nil
 }
 
-// SendETH sends ETH from one address to another
-func (s *WalletService) SendETH(userID string, req *models.SendETHRequest) (string, error) {
-	// Get user's wallet
+// ListTokenBalances returns the supported tokens with the account's balance of each
+func (s *WalletService) ListTokenBalances(ctx context.Context, userID, accountSelector, chainSelector string) ([]models.TokenBalance, error) {
+	chain, err := s.chains.Resolve(chainSelector)
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindUserByID(userID)
 	if err != nil {
 		utils.LogError(err, "Failed to get user wallet", map[string]interface{}{
 			"user_id": userID,
 		})
-		return "", fmt.Errorf("failed to get user wallet: %w", err)
+		return nil, fmt.Errorf("failed to get user wallet: %w", err)
 	}
 
-	// Verify PIN
-	err = bcrypt.CompareHashAndPassword([]byte(user.Pin), []byte(req.Pin+user.Salt))
+	account, err := s.findAccount(user, accountSelector)
 	if err != nil {
-		utils.LogError(err, "Invalid PIN", map[string]interface{}{
-			"user_id": userID,
-		})
-		return "", fmt.Errorf("invalid PIN")
+		return nil, err
 	}
 
-	// Decrypt the mnemonic using the provided PIN
-	mnemonic, err := Decrypt(req.Pin, user.Wallet.Mnemonic)
+	return s.tokenService.ListTokenBalances(ctx, chain, common.HexToAddress(account.Address))
+}
+
+// SendETH sends ETH from one address to another
+func (s *WalletService) SendETH(userID string, req *models.SendETHRequest) (string, error) {
+	chain, err := s.chains.Resolve(req.Chain)
 	if err != nil {
-		utils.LogError(err, "Failed to decrypt mnemonic", nil)
-		return "", fmt.Errorf("failed to decrypt mnemonic: %w", err)
+		return "", err
 	}
 
-	// Recover wallet from mnemonic
-	address, privateKey, err := RecoverWalletFromMnemonic(mnemonic, "m/44'/60'/0'/0/0")
+	// Unlock the sending account with the user's PIN
+	account, privKey, err := s.unlockAccount(userID, req.Pin, req.FromAccount)
 	if err != nil {
-		utils.LogError(err, "Failed to recover wallet", nil)
-		return "", fmt.Errorf("failed to recover wallet: %w", err)
+		return "", err
 	}
+	fromAddress := common.HexToAddress(account.Address)
+	toAddress := common.HexToAddress(req.ToAddress)
 
-	// Convert private key from hex to ECDSA
-	privKey, err := crypto.HexToECDSA(privateKey)
+	// Convert amount from ETH to Wei
+	amountInWei, err := parseAmount(req.AmountInETH, 18)
 	if err != nil {
-		utils.LogError(err, "Failed to convert private key", nil)
-		return "", fmt.Errorf("failed to convert private key: %w", err)
+		return "", err
 	}
 
-	// Get the public key and address
-	publicKey := privKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return "", fmt.Errorf("failed to get public key")
+	// Ask for the second factor when the user's policy requires it
+	if err := s.checkTransferFactor(userID, chain.ID, toAddress, amountInWei, 18, true, req.TotpCode); err != nil {
+		return "", err
 	}
 
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-	toAddress := common.HexToAddress(req.ToAddress)
-
-	// Convert amount from ETH to Wei
-	amountInETH, _ := new(big.Float).SetString(req.AmountInETH)
-	amountInWei, _ := amountInETH.Mul(amountInETH, big.NewFloat(1e18)).Int(nil)
-
-	// Get nonce
-	nonce, err := s.client.PendingNonceAt(context.Background(), fromAddress)
+	feeTier, err := parseFeeTier(req.FeeTier)
 	if err != nil {
-		utils.LogError(err, "Failed to get nonce", nil)
-		return "", fmt.Errorf("failed to get nonce: %w", err)
+		return "", err
 	}
 
-	// Get gas price
-	gasPrice, err := s.client.SuggestGasPrice(context.Background())
+	// Price the transaction for the requested fee tier
+	fees, err := suggestFees(context.Background(), chain, feeTier)
 	if err != nil {
-		utils.LogError(err, "Failed to get gas price", nil)
-		return "", fmt.Errorf("failed to get gas price: %w", err)
+		utils.LogError(err, "Failed to get transaction fees", nil)
+		return "", err
 	}
 
-	// Apply a multiplier to the gas price (for faster transactions)
-	gasPrice = new(big.Int).Mul(gasPrice, big.NewInt(120))
-	gasPrice = new(big.Int).Div(gasPrice, big.NewInt(100))
-
 	msg := ethereum.CallMsg{
-		From:     fromAddress,
-		To:       &toAddress,
-		GasPrice: gasPrice,
-		Value:    amountInWei,
-		Data:     nil,
+		From:  fromAddress,
+		To:    &toAddress,
+		Value: amountInWei,
+		Data:  nil,
 	}
+	fees.applyTo(&msg)
 
-	gasLimit, err := s.client.EstimateGas(context.Background(), msg)
+	gasLimit, err := chain.client.EstimateGas(context.Background(), msg)
 	if err != nil {
 		utils.LogError(err, "Failed to estimate gas", nil)
 		return "", fmt.Errorf("failed to estimate gas: %w", err)
 	}
 
-	// Create transaction
-	tx := types.NewTransaction(nonce, toAddress, amountInWei, gasLimit, gasPrice, nil)
-
-	// Get chain ID
-	chainID, err := s.client.NetworkID(context.Background())
-	if err != nil {
-		utils.LogError(err, "Failed to get chain ID", nil)
-		return "", fmt.Errorf("failed to get chain ID: %w", err)
-	}
-
-	// Sign transaction
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privKey)
-	if err != nil {
-		utils.LogError(err, "Failed to sign transaction", nil)
-		return "", fmt.Errorf("failed to sign transaction: %w", err)
-	}
-
-	// Send transaction
-	err = s.client.SendTransaction(context.Background(), signedTx)
+	// Sign, record and send the transaction
+	signedTx, err := s.signAndSend(context.Background(), &outgoingTx{
+		userID:      userID,
+		key:         privKey,
+		from:        fromAddress,
+		chain:       chain,
+		to:          toAddress,
+		value:       amountInWei,
+		gasLimit:    gasLimit,
+		fees:        fees,
+		recipient:   toAddress,
+		amount:      amountInWei,
+		tokenSymbol: chain.NativeSymbol,
+	})
 	if err != nil {
-		utils.LogError(err, "Failed to send transaction", nil)
-		return "", fmt.Errorf("failed to send transaction: %w", err)
+		return "", err
 	}
 
 	utils.LogInfo("ETH sent successfully", map[string]interface{}{
-		"from":    address,
+		"chain":   chain.Name,
+		"from":    fromAddress.Hex(),
 		"to":      req.ToAddress,
 		"amount":  req.AmountInETH,
 		"tx_hash": signedTx.Hash().Hex(),
@@ -251,99 +256,95 @@ func (s *WalletService) SendETH(userID string, req *models.SendETHRequest) (stri
 
 // SendERC20Token sends ERC20 tokens from one address to another
 func (s *WalletService) SendERC20Token(userID string, req *models.SendERC20Request) (string, error) {
-	// Get user's wallet
-	user, err := s.userRepo.FindUserByID(userID)
+	chain, err := s.chains.Resolve(req.Chain)
 	if err != nil {
-		utils.LogError(err, "Failed to get user wallet", map[string]interface{}{
-			"user_id": userID,
-		})
-		return "", fmt.Errorf("failed to get user wallet: %w", err)
+		return "", err
 	}
 
-	// Verify PIN
-	err = bcrypt.CompareHashAndPassword([]byte(user.Pin), []byte(req.Pin+user.Salt))
+	// Unlock the sending account with the user's PIN
+	account, privKey, err := s.unlockAccount(userID, req.Pin, req.FromAccount)
 	if err != nil {
-		utils.LogError(err, "Invalid PIN", map[string]interface{}{
-			"user_id": userID,
-		})
-		return "", fmt.Errorf("invalid PIN")
+		return "", err
 	}
+	fromAddress := common.HexToAddress(account.Address)
+	toAddress := common.HexToAddress(req.ToAddress)
 
-	// Decrypt the mnemonic using the provided PIN
-	mnemonic, err := Decrypt(req.Pin, user.Wallet.Mnemonic)
-	if err != nil {
-		utils.LogError(err, "Failed to decrypt mnemonic", nil)
-		return "", fmt.Errorf("failed to decrypt mnemonic: %w", err)
+	// Look up the token contract, falling back to USDC for older clients
+	tokenSelector := req.Token
+	if tokenSelector == "" {
+		tokenSelector = "USDC"
 	}
-
-	// Recover wallet from mnemonic
-	address, privateKey, err := RecoverWalletFromMnemonic(mnemonic, "m/44'/60'/0'/0/0")
+	token, err := s.tokenService.ResolveToken(chain.ID, tokenSelector)
 	if err != nil {
-		utils.LogError(err, "Failed to recover wallet", nil)
-		return "", fmt.Errorf("failed to recover wallet: %w", err)
+		utils.LogError(err, "Unsupported token", map[string]interface{}{
+			"token": tokenSelector,
+		})
+		return "", fmt.Errorf("unsupported token %q: %w", tokenSelector, err)
 	}
+	tokenAddress := common.HexToAddress(token.Address)
 
-	// Convert private key from hex to ECDSA
-	privKey, err := crypto.HexToECDSA(privateKey)
+	// Convert the amount to the token's smallest unit using its on-chain decimals
+	amount := req.Amount
+	if amount == "" {
+		amount = req.AmountInUSD
+	}
+	amountInUnits, err := parseAmount(amount, token.Decimals)
 	if err != nil {
-		utils.LogError(err, "Failed to convert private key", nil)
-		return "", fmt.Errorf("failed to convert private key: %w", err)
+		return "", err
 	}
 
-	// Get the public key and address
-	publicKey := privKey.Public()
-	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
-	if !ok {
-		return "", fmt.Errorf("failed to get public key")
+	// Ask for the second factor when the user's policy requires it
+	if err := s.checkTransferFactor(userID, chain.ID, toAddress, amountInUnits, token.Decimals, false, req.TotpCode); err != nil {
+		return "", err
 	}
 
-	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
-	toAddress := common.HexToAddress(req.ToAddress)
-
-	// USDC token contract address on Ethereum (change if using different token or network)
-	usdcAddress := common.HexToAddress(config.AppConfig.EthConfig.USDCContractAddr)
-
-	// Convert the USD amount to USDC token amount in smallest units (USDC has 6 decimal places)
-	amountInUSD, _ := new(big.Float).SetString(req.AmountInUSD)
-	amountInWei := new(big.Int)
-	amountInWei, _ = amountInUSD.Mul(amountInUSD, big.NewFloat(1e6)).Int(amountInWei)
-
-	// Define the ERC20 ABI and pack the `transfer` method call with recipient and amount
-	erc20ABI, _ := abi.JSON(strings.NewReader(`[{"constant":false,"inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"name":"transfer","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"}]`))
-	data, _ := erc20ABI.Pack("transfer", toAddress, amountInWei)
-
-	// Get the current nonce for the sender account
-	nonce, _ := s.client.PendingNonceAt(context.Background(), fromAddress)
+	// Pack the `transfer` method call with recipient and amount
+	data, err := erc20ABI.Pack("transfer", toAddress, amountInUnits)
+	if err != nil {
+		return "", fmt.Errorf("failed to pack transfer call: %w", err)
+	}
 
-	// Suggest a gas price for the transaction
-	gasPrice, _ := s.client.SuggestGasPrice(context.Background())
+	feeTier, err := parseFeeTier(req.FeeTier)
+	if err != nil {
+		return "", err
+	}
 
-	// Apply a multiplier to the gas price (for faster transactions)
-	gasPrice = new(big.Int).Mul(gasPrice, big.NewInt(120))
-	gasPrice = new(big.Int).Div(gasPrice, big.NewInt(100))
+	// Price the transaction for the requested fee tier
+	fees, err := suggestFees(context.Background(), chain, feeTier)
+	if err != nil {
+		utils.LogError(err, "Failed to get transaction fees", nil)
+		return "", err
+	}
 
 	// Set a gas limit for the token transfer transaction
 	gasLimit := uint64(100000) // typical for ERC20 token transfers
 
-	// Construct the raw transaction (value is 0 since we're not sending ETH)
-	tx := types.NewTransaction(nonce, usdcAddress, big.NewInt(0), gasLimit, gasPrice, data)
-
-	// Get the chain ID (required for signing the transaction)
-	chainID, _ := s.client.NetworkID(context.Background())
-
-	// Sign the transaction using the sender's private key
-	signedTx, _ := types.SignTx(tx, types.NewEIP155Signer(chainID), privKey)
-
-	// Send the signed transaction to the network
-	err = s.client.SendTransaction(context.Background(), signedTx)
+	// Sign, record and send the transaction (value is 0 since we're not sending ETH)
+	signedTx, err := s.signAndSend(context.Background(), &outgoingTx{
+		userID:       userID,
+		key:          privKey,
+		from:         fromAddress,
+		chain:        chain,
+		to:           tokenAddress,
+		value:        big.NewInt(0),
+		gasLimit:     gasLimit,
+		fees:         fees,
+		data:         data,
+		recipient:    toAddress,
+		amount:       amountInUnits,
+		tokenSymbol:  token.Symbol,
+		tokenAddress: token.Address,
+	})
 	if err != nil {
 		return "", err
 	}
 
 	utils.LogInfo("ERC20 token sent successfully", map[string]interface{}{
-		"from":    address,
+		"chain":   chain.Name,
+		"from":    fromAddress.Hex(),
 		"to":      req.ToAddress,
-		"amount":  req.AmountInUSD,
+		"token":   token.Symbol,
+		"amount":  amount,
 		"tx_hash": signedTx.Hash().Hex(),
 	})
 